@@ -0,0 +1 @@
+match (n:Service {ID: $id}) return n
@@ -50,6 +50,53 @@ const (
 	commandConsoleUse      = "console"
 	commandConsoleUseShort = "Start a console to navigate the graph"
 
+	commandSchemaUse      = "schema"
+	commandSchemaUseShort = "Generate JSON Schema for definition files from the definition format"
+
+	commandLSPUse      = "lsp"
+	commandLSPUseShort = "Start a language server for definition files, communicating over stdio"
+
+	commandRenameUse      = "rename Class/old-id new-id"
+	commandRenameUseShort = "Rename a definition, updating every reference to it"
+
+	commandRenameClassUse      = "rename-class OldClass NewClass"
+	commandRenameClassUseShort = "Rename a class, updating every definition and reference of that class"
+
+	commandDiffUse      = "diff [dirA dirB]"
+	commandDiffUseShort = "Compare the definitions in two directories, or at two git revisions"
+	commandDiffUseLong  = "Compare the definitions in two directories, or in the source directories at two git " +
+		"revisions, reporting added, removed and modified definitions, field changes and relationship changes"
+
+	commandImpactUse      = "impact [Class/ID]"
+	commandImpactUseShort = "Show the definitions which depend on, or are depended on by, a definition"
+	commandImpactUseLong  = "Walk the references to and from a definition, printing the dependency tree and the " +
+		"number of definitions of each class reached; with --git-rev, each definition removed between the " +
+		"revisions is analysed instead"
+
+	commandPathUse      = "path Class/ID Class/ID"
+	commandPathUseShort = "Find the shortest path, or all paths, between two definitions"
+
+	commandNeighboursUse      = "neighbours Class/ID"
+	commandNeighboursUseShort = "List the definitions within a number of relationships of a definition"
+
+	commandQueryUse      = "query selector"
+	commandQueryUseShort = "Select definitions using a compact selector language"
+	commandQueryUseLong  = "Select definitions using a selector such as 'Service[Provider=azure]->TYPE_OF->Category{ID, Name}'; " +
+		"each step names a class, or *, with optional [predicates], and steps are joined by ->, <- or -, optionally " +
+		"naming the relationship as in ->TYPE_OF->"
+
+	commandCypherUse      = "cypher [query]"
+	commandCypherUseShort = "Run a Cypher query against the graph database"
+
+	commandStatsUse      = "stats"
+	commandStatsUseShort = "Summarise definitions, relationships and field fill rates"
+
+	commandDocsUse      = "docs"
+	commandDocsUseShort = "Generate reference documentation from the definition format"
+
+	commandSiteUse      = "site"
+	commandSiteUseShort = "Generate a static site with a page for each definition"
+
 	flagFileExtension          = "ext"
 	flagFileExtensionShorthand = "e"
 	flagFileExtensionDefault   = "yaml"
@@ -89,13 +136,114 @@ const (
 
 	flagReportTemplateFileName      = "template"
 	flagReportTemplateFileShorthand = "t"
-	flagReportTemplateFileUsage     = "report template file (required)"
+	flagReportTemplateFileUsage     = "report template file, or directory of templates, parsed together so they can share definitions (required)"
+
+	flagReportTemplateNameName  = "template-name"
+	flagReportTemplateNameUsage = "name of the template to execute; defaults to the first template file"
+
+	flagReportOutputDirUsage      = "directory to write the report into, rather than standard output"
+	flagReportPerDefinitionName   = "per-definition"
+	flagReportPerDefinitionUsage  = "render the template once for each top level definition, each to its own file in the output directory"
+	flagReportFileNameName        = "filename"
+	flagReportFileNameUsage       = "template for the name of each file written to the output directory, such as '{{ .Class }}/{{ .ID }}.html'"
+	flagReportTemplateEngineName  = "template-engine"
+	flagReportTemplateEngineUsage = "template engine: auto, text or html; auto uses html for .gohtml and .html templates or output files"
 
 	flagDefinitionFormatName      = "format"
 	flagDefinitionFormatShorthand = "f"
 	flagDefinitionFormatUsage     = "Definition format file (required)"
 	flagDefinitionFormatDefault   = "definition/format.yml"
 
+	flagLoadDefinitionFormatUsage = "Definition format file, used to compute fields when loading definitions"
+
+	flagMaxWarningsName    = "max-warnings"
+	flagMaxWarningsDefault = -1
+	flagMaxWarningsUsage   = "maximum number of warnings before validation fails (-1 for no limit)"
+
+	flagSchemaClassName      = "class"
+	flagSchemaClassShorthand = "c"
+	flagSchemaClassUsage     = "only accept definition files for this class"
+
+	flagOutputDirName        = "out"
+	flagOutputDirShorthand   = "o"
+	flagSchemaOutputDirUsage = "directory to write a schema for all classes, and a schema for each class, into"
+
+	flagLSPSourceUsage           = "Source directories to read definitions from; defaults to the editor workspace"
+	flagLSPDefinitionFormatUsage = "Definition format file, used to validate definitions"
+
+	flagDryRunName  = "dry-run"
+	flagDryRunUsage = "print the changes as a diff rather than applying them"
+
+	flagRenameDefinitionFormatUsage = "Definition format files to rename the class within"
+
+	flagGitRevisionName  = "git-rev"
+	flagGitRevisionUsage = "git revision range A..B to compare; A defaults to HEAD and B to the working tree"
+
+	flagDiffSourceUsage = "Source directories to read definitions from when comparing git revisions"
+
+	flagOutputFormatName      = "output-format"
+	flagDiffOutputFormatUsage = "output format: text, json or markdown"
+
+	flagImpactOutputFormatUsage = "output format: text or json"
+	flagImpactGitRevisionUsage  = "git revision range A..B; analyse each definition removed between the revisions"
+
+	flagDepthName  = "depth"
+	flagDepthUsage = "maximum number of relationships to follow (0 for no limit)"
+
+	flagRelationshipName  = "relationship"
+	flagRelationshipUsage = "only follow these relationships"
+
+	flagFailIfReferencedName  = "fail-if-referenced"
+	flagFailIfReferencedUsage = "fail if any definition analysed is still referenced by another definition"
+
+	flagPathOutputFormatUsage = "output format: table, json or mermaid"
+	flagNeighbourDepthDefault = 1
+
+	flagAllPathsName  = "all"
+	flagAllPathsUsage = "find all simple paths rather than the shortest"
+
+	flagMaxLengthName    = "max-length"
+	flagMaxLengthDefault = 6
+	flagMaxLengthUsage   = "maximum number of relationships in a path (0 for no limit)"
+
+	flagQueryOutputFormatUsage     = "output format: table, csv, json or yaml"
+	flagQueryDefinitionFormatUsage = "Definition format file, used to compute fields and resolve references to base classes before querying"
+
+	flagCypherOutputFormatUsage = "output format: table, csv or json"
+
+	flagCypherFileName  = "file"
+	flagCypherFileUsage = "file to read the query from"
+
+	flagCypherParamName  = "param"
+	flagCypherParamUsage = "query parameter in the form name=value or name:type=value where type is int, float or bool; may be repeated"
+	flagReportParamUsage = "parameter referred to by Where predicates in the report fields file, in the form name=value or name:type=value where type is int, float or bool; may be repeated"
+
+	flagStatsDefinitionFormatUsage = "Definition format file, used to compute fields and report fill rates of declared fields"
+	flagStatsOutputFormatUsage     = "output format: text or json"
+
+	flagTopName    = "top"
+	flagTopDefault = 10
+	flagTopUsage   = "number of most connected and largest definitions to list (-1 for all)"
+
+	flagDocsOutputDirDefault      = "docs"
+	flagDocsOutputDirUsage        = "directory to write the index and a page for each class into"
+	flagDocsOutputFormatUsage     = "output format: markdown or html"
+	flagDocsExamplesName          = "examples"
+	flagDocsExamplesDefault       = 3
+	flagDocsExamplesUsage         = "maximum number of example definitions to include for each class"
+	flagDocsDefinitionFormatUsage = "Definition format file to document (required)"
+
+	flagSiteOutputDirDefault      = "site"
+	flagSiteOutputDirUsage        = "directory to write the site into"
+	flagSiteTemplateDirName       = "templates"
+	flagSiteTemplateDirShorthand  = "t"
+	flagSiteTemplateDirUsage      = "directory of templates to use in place of the defaults: layout.html, index.html, class.html or definition.html"
+	flagSiteDefinitionFormatUsage = "Definition format file, used to compute fields before generating the site"
+
+	flagReportDefinitionFormatUsage = "Definition format file, used to compute fields when loading definitions and to determine the class of related definitions"
+
+	flagGraphDefinitionFormatUsage = "Definition format file, used to resolve references to base classes"
+
 	flagLoadDefinitionsName  = "load"
 	flagLoadDefinitionsUsage = "load definitions"
 
@@ -104,6 +252,17 @@ const (
 	exitCodeValidateCmdFailed = 3
 	exitCodeJSONCmdFailed     = 4
 	exitCodeTemplateCmdFailed = 5
+	exitCodeSchemaCmdFailed   = 6
+	exitCodeLSPCmdFailed      = 7
+	exitCodeRenameCmdFailed   = 8
+	exitCodeDiffCmdFailed     = 9
+	exitCodeImpactCmdFailed   = 10
+	exitCodePathCmdFailed     = 11
+	exitCodeQueryCmdFailed    = 12
+	exitCodeCypherCmdFailed   = 13
+	exitCodeStatsCmdFailed    = 14
+	exitCodeDocsCmdFailed     = 15
+	exitCodeSiteCmdFailed     = 16
 )
 
 var (
@@ -133,8 +292,20 @@ var (
 	logLevel int8
 
 	// variable for flagReportTemplateFileName parameter
+	templateFiles []string
+
+	// variable for flagReportTemplateNameName parameter
 	templateName string
 
+	// variable for flagReportPerDefinitionName parameter
+	perDefinition bool
+
+	// variable for flagReportFileNameName parameter
+	reportFileName string
+
+	// variable for flagReportTemplateEngineName parameter
+	templateEngine string
+
 	// variable for flagReportFieldsFileName parameter
 	templateFormat string
 
@@ -144,4 +315,52 @@ var (
 	// variable for flagDefinitionFormatName parameter
 	// note: we allow multiple definition format files to enable multiple source directories
 	definitionFormatFile []string
+
+	// variable for flagMaxWarningsName parameter
+	maxWarnings int
+
+	// variable for flagSchemaClassName parameter
+	schemaClass string
+
+	// variable for flagOutputDirName parameter
+	outputDir string
+
+	// variable for flagDryRunName parameter
+	dryRun bool
+
+	// variable for flagGitRevisionName parameter
+	gitRevision string
+
+	// variable for flagOutputFormatName parameter
+	outputFormat string
+
+	// variable for flagDepthName parameter
+	depth int
+
+	// variable for flagRelationshipName parameter
+	relationships []string
+
+	// variable for flagFailIfReferencedName parameter
+	failIfReferenced bool
+
+	// variable for flagAllPathsName parameter
+	allPaths bool
+
+	// variable for flagMaxLengthName parameter
+	maxLength int
+
+	// variable for flagCypherFileName parameter
+	cypherFile string
+
+	// variable for flagCypherParamName parameter
+	cypherParams []string
+
+	// variable for flagTopName parameter
+	top int
+
+	// variable for flagDocsExamplesName parameter
+	docsExamples int
+
+	// variable for flagSiteTemplateDirName parameter
+	siteTemplateDir string
 )
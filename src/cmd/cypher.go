@@ -0,0 +1,255 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/nextmetaphor/yaml-graph/graph"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	cypherNode              = "(%s {%s})"
+	cypherRelationship      = "[:%s {%s}]"
+	cypherPathOutgoing      = "-%s->"
+	cypherPathIncoming      = "<-%s-"
+	cypherProperty          = "%s: %s"
+	cypherPropertySeparator = ", "
+	cypherParamSeparator    = "="
+
+	outputCypherFailure = "failed to run cypher"
+
+	logErrorCypherFailed = "cypher failed"
+
+	errorCypherArguments     = "either a query or --file must be provided"
+	errorInvalidCypherParam  = "parameter [%s] must be in the form name=value"
+	errorInvalidCypherOutput = "invalid output format [%s]; must be one of table, csv or json"
+)
+
+var (
+	cypherCmd = &cobra.Command{
+		Use:   commandCypherUse,
+		Short: commandCypherUseShort,
+		Args:  cobra.MaximumNArgs(1),
+		Run:   cypher,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cypherCmd)
+
+	cypherCmd.Flags().StringVar(&cypherFile, flagCypherFileName, "", flagCypherFileUsage)
+	cypherCmd.Flags().StringArrayVar(&cypherParams, flagCypherParamName, nil, flagCypherParamUsage)
+	cypherCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatTable, flagCypherOutputFormatUsage)
+}
+
+// readCypher returns the query from the argument or, if none was provided, from the file
+func readCypher(args []string, file string) (string, error) {
+	if len(args) == 1 && file == "" {
+		return args[0], nil
+	}
+	if len(args) == 0 && file != "" {
+		b, err := os.ReadFile(file)
+		return string(b), err
+	}
+	return "", fmt.Errorf(errorCypherArguments)
+}
+
+// formatCypherProperty formats a property value in full, quoting strings
+func formatCypherProperty(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strconv.Quote(t)
+	case []interface{}:
+		s := make([]string, 0, len(t))
+		for _, i := range t {
+			s = append(s, formatCypherProperty(i))
+		}
+		return "[" + strings.Join(s, cypherPropertySeparator) + "]"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func formatCypherProps(props map[string]interface{}) string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s := make([]string, 0, len(keys))
+	for _, k := range keys {
+		s = append(s, fmt.Sprintf(cypherProperty, k, formatCypherProperty(props[k])))
+	}
+	return strings.Join(s, cypherPropertySeparator)
+}
+
+func formatCypherNode(n neo4j.Node) string {
+	labels := ""
+	for _, l := range n.Labels {
+		labels += ":" + l
+	}
+	return fmt.Sprintf(cypherNode, labels, formatCypherProps(n.Props))
+}
+
+func formatCypherRelationship(r neo4j.Relationship) string {
+	return fmt.Sprintf(cypherRelationship, r.Type, formatCypherProps(r.Props))
+}
+
+// formatCypherValue formats nodes, relationships and paths in Cypher syntax, and any other value as a string
+func formatCypherValue(v interface{}) string {
+	switch t := v.(type) {
+	case neo4j.Node:
+		return formatCypherNode(t)
+	case neo4j.Relationship:
+		return formatCypherRelationship(t)
+	case neo4j.Path:
+		var sb strings.Builder
+		for i, n := range t.Nodes {
+			if i > 0 && i-1 < len(t.Relationships) {
+				r := t.Relationships[i-1]
+				format := cypherPathOutgoing
+				if r.StartElementId != t.Nodes[i-1].ElementId {
+					format = cypherPathIncoming
+				}
+				sb.WriteString(fmt.Sprintf(format, formatCypherRelationship(r)))
+			}
+			sb.WriteString(formatCypherNode(n))
+		}
+		return sb.String()
+	case []interface{}:
+		s := make([]string, 0, len(t))
+		for _, i := range t {
+			s = append(s, formatCypherValue(i))
+		}
+		return "[" + strings.Join(s, cypherPropertySeparator) + "]"
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// cypherJSONValue converts nodes, relationships and paths into maps which can be marshalled
+func cypherJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case neo4j.Node:
+		return map[string]interface{}{"Labels": t.Labels, "Props": t.Props}
+	case neo4j.Relationship:
+		return map[string]interface{}{"Type": t.Type, "Props": t.Props}
+	case neo4j.Path:
+		nodes := make([]interface{}, 0, len(t.Nodes))
+		for _, n := range t.Nodes {
+			nodes = append(nodes, cypherJSONValue(n))
+		}
+		rels := make([]interface{}, 0, len(t.Relationships))
+		for _, r := range t.Relationships {
+			rels = append(rels, cypherJSONValue(r))
+		}
+		return map[string]interface{}{"Nodes": nodes, "Relationships": rels}
+	case []interface{}:
+		l := make([]interface{}, 0, len(t))
+		for _, i := range t {
+			l = append(l, cypherJSONValue(i))
+		}
+		return l
+	}
+	return v
+}
+
+// writeCypherRecords writes the records in the output format
+func writeCypherRecords(keys []string, records []*neo4j.Record, format string, w io.Writer) error {
+	if format != outputFormatTable && format != outputFormatCSV && format != outputFormatJSON {
+		return fmt.Errorf(errorInvalidCypherOutput, format)
+	}
+
+	r := &parser.QueryResult{Columns: keys, Rows: [][]interface{}{}}
+	for _, record := range records {
+		row := make([]interface{}, 0, len(record.Values))
+		for _, v := range record.Values {
+			if format == outputFormatJSON {
+				row = append(row, cypherJSONValue(v))
+			} else {
+				row = append(row, formatCypherValue(v))
+			}
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	return writeQueryResult(r, format, w)
+}
+
+// runCypher runs the query, returning the keys and every record
+func runCypher(session neo4j.Session, query string, params map[string]interface{}) ([]string, []*neo4j.Record,
+	error) {
+	res, err := graph.ExecuteCypher(session, query, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := res.Keys()
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := res.Collect()
+	return keys, records, err
+}
+
+func exitCypherFailed(err error) {
+	log.Error().Err(err).Msg(logErrorCypherFailed)
+	fmt.Println(outputCypherFailure)
+	os.Exit(exitCodeCypherCmdFailed)
+}
+
+func cypher(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName)
+
+	query, err := readCypher(args, cypherFile)
+	if err != nil {
+		exitCypherFailed(err)
+	}
+	params, err := parseReportParams(cypherParams)
+	if err != nil {
+		exitCypherFailed(err)
+	}
+
+	driver, session, err := graph.Init(dbURL, username, password)
+	if err != nil {
+		exitCypherFailed(err)
+	}
+	defer driver.Close()
+	defer session.Close()
+
+	keys, records, err := runCypher(session, query, params)
+	if err == nil {
+		err = writeCypherRecords(keys, records, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		// deferred calls are not run by os.Exit
+		session.Close()
+		driver.Close()
+		exitCypherFailed(err)
+	}
+}
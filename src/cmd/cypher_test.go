@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readCypher(t *testing.T) {
+	q, err := readCypher([]string{"match (n) return n"}, "")
+	assert.Nil(t, err)
+	assert.Equal(t, "match (n) return n", q)
+
+	q, err = readCypher(nil, "_test/cypher/query.cypher")
+	assert.Nil(t, err)
+	assert.Equal(t, "match (n:Service {ID: $id}) return n\n", q)
+
+	_, err = readCypher(nil, "")
+	assert.NotNil(t, err)
+	_, err = readCypher([]string{"match (n) return n"}, "_test/cypher/query.cypher")
+	assert.NotNil(t, err)
+	_, err = readCypher(nil, "_test/cypher/missing.cypher")
+	assert.NotNil(t, err)
+}
+
+func Test_formatCypherProperty(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	assert.Equal(t, `"`+long+`"`, formatCypherProperty(long))
+	assert.Equal(t, `["a", 1, true]`, formatCypherProperty([]interface{}{"a", int64(1), true}))
+	assert.Equal(t, "4.5", formatCypherProperty(4.5))
+}
+
+func Test_writeCypherRecords(t *testing.T) {
+	vm := neo4j.Node{ElementId: "1", Labels: []string{"Service"}, Props: map[string]interface{}{"ID": "vm", "Cores": int64(4)}}
+	azure := neo4j.Node{ElementId: "2", Labels: []string{"Provider"}, Props: map[string]interface{}{"ID": "azure"}}
+	hostedBy := neo4j.Relationship{StartElementId: "1", EndElementId: "2", Type: "HOSTED_BY", Props: map[string]interface{}{}}
+
+	keys := []string{"n", "r", "p", "count"}
+	records := []*neo4j.Record{{Keys: keys, Values: []interface{}{
+		vm, hostedBy, neo4j.Path{Nodes: []neo4j.Node{azure, vm}, Relationships: []neo4j.Relationship{hostedBy}}, int64(2),
+	}}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeCypherRecords(keys, records, "csv", &buf))
+	assert.Equal(t, `n,r,p,count
+"(:Service {Cores: 4, ID: ""vm""})",[:HOSTED_BY {}],"(:Provider {ID: ""azure""})<-[:HOSTED_BY {}]-(:Service {Cores: 4, ID: ""vm""})",2
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeCypherRecords(keys, records, "json", &buf))
+	assert.Contains(t, buf.String(), `"n": {
+      "Labels": [
+        "Service"
+      ],
+      "Props": {
+        "Cores": 4,
+        "ID": "vm"
+      }
+    }`)
+	assert.Contains(t, buf.String(), `"count": 2`)
+
+	buf.Reset()
+	assert.Nil(t, writeCypherRecords([]string{"n"}, nil, "table", &buf))
+	assert.Equal(t, "n\n", buf.String())
+
+	assert.NotNil(t, writeCypherRecords(keys, records, "yaml", &buf))
+}
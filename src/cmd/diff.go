@@ -0,0 +1,336 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputFormatText     = "text"
+	outputFormatJSON     = "json"
+	outputFormatMarkdown = "markdown"
+
+	diffValueMaxLength = 60
+	diffIndent         = "  "
+	diffFilePerm       = 0644
+	diffDirectoryPerm  = 0755
+	diffRevisionRange  = ".."
+	diffDefaultRev     = "HEAD"
+
+	outputDiffFailure = "failed to compare definitions"
+
+	diffTextNoChanges = "no changes\n"
+	diffTextSummary   = "%d added, %d removed, %d modified\n"
+
+	diffMarkdownNoChanges   = "No changes to definitions.\n"
+	diffMarkdownSummary     = "**%d added, %d removed, %d modified**\n"
+	diffMarkdownHeading     = "\n#### %s `%s`\n\n| Change | Before | After |\n|---|---|---|\n"
+	diffMarkdownRow         = "| %s | %s | %s |\n"
+	diffMarkdownField       = "Field `%s`"
+	diffMarkdownReference   = "Relationship"
+	diffMarkdownAdded       = "Added"
+	diffMarkdownRemoved     = "Removed"
+	diffMarkdownModified    = "Modified"
+	diffTextAdded           = "+"
+	diffTextRemoved         = "-"
+	diffTextModified        = "~"
+	diffTextDefinition      = "%s %s/%s\n"
+	diffTextField           = diffIndent + "%s %s: %s\n"
+	diffTextModifiedField   = diffIndent + "%s %s: %s -> %s\n"
+	diffTextReference       = diffIndent + "%s %s\n"
+	diffReferenceTo         = "-[%s]-> %s/%s"
+	diffReferenceFrom       = "<-[%s]- %s/%s"
+	diffReferenceUndirected = "-[%s]- %s/%s"
+
+	logDebugExtractingRevision = "extracting revision [%s] into [%s]"
+	logErrorDiffFailed         = "diff failed"
+
+	errorInvalidDiffOutput  = "invalid output format [%s]; must be one of text, json or markdown"
+	errorDiffArguments      = "either two source directories or --git-rev must be provided"
+	errorGitArchiveFailed   = "git archive of revision [%s] failed: %s"
+	errorInvalidArchivePath = "invalid path [%s] in archive"
+)
+
+var (
+	diffCmd = &cobra.Command{
+		Use:   commandDiffUse,
+		Short: commandDiffUseShort,
+		Long:  commandDiffUseLong,
+		Run:   diff,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&gitRevision, flagGitRevisionName, "", flagGitRevisionUsage)
+	diffCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagDiffSourceUsage)
+	diffCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatText, flagDiffOutputFormatUsage)
+}
+
+// parseRevisionRange splits a revision range of the form A..B; an empty A defaults to HEAD, whilst an empty B, or no
+// range at all, indicates the working tree
+func parseRevisionRange(r string) (before, after string) {
+	before, after, _ = strings.Cut(r, diffRevisionRange)
+	if before == "" {
+		before = diffDefaultRev
+	}
+	return before, after
+}
+
+// extractRevision writes the paths at the git revision into dir; as with git archive, paths are relative to the
+// current directory
+func extractRevision(rev string, paths []string, dir string) error {
+	log.Debug().Msgf(logDebugExtractingRevision, rev, dir)
+
+	var stdout, stderr bytes.Buffer
+	git := exec.Command("git", append([]string{"archive", "--format=tar", rev, "--"}, paths...)...)
+	git.Stdout, git.Stderr = &stdout, &stderr
+	if err := git.Run(); err != nil {
+		return fmt.Errorf(errorGitArchiveFailed, rev, strings.TrimSpace(stderr.String()))
+	}
+
+	tr := tar.NewReader(&stdout)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, hdr.Name)
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf(errorInvalidArchivePath, hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, diffDirectoryPerm)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(path), diffDirectoryPerm); err == nil {
+				var b []byte
+				if b, err = io.ReadAll(tr); err == nil {
+					err = os.WriteFile(path, b, diffFilePerm)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// loadRevision loads the dictionary from the source directories at the git revision, or from the working tree if
+// the revision is empty
+func loadRevision(rev string, dirs []string, ext string) (parser.Dictionary, error) {
+	if rev == "" {
+		return parser.LoadDictionary(dirs, ext), nil
+	}
+
+	tmp, err := os.MkdirTemp("", appName)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err = extractRevision(rev, dirs, tmp); err != nil {
+		return nil, err
+	}
+	revDirs := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		revDirs = append(revDirs, filepath.Join(tmp, d))
+	}
+	return parser.LoadDictionary(revDirs, ext), nil
+}
+
+// formatDiffValue formats a field value on a single line, truncating long values such as the contents of files
+func formatDiffValue(v interface{}) string {
+	s := fmt.Sprintf("%v", v)
+	if str, ok := v.(string); ok {
+		if r := []rune(str); len(r) > diffValueMaxLength {
+			str = string(r[:diffValueMaxLength]) + "..."
+		}
+		s = fmt.Sprintf("%q", str)
+	}
+	return s
+}
+
+func formatDiffReference(ref definition.Reference) string {
+	format := diffReferenceUndirected
+	if ref.RelationshipTo {
+		format = diffReferenceTo
+	} else if ref.RelationshipFrom {
+		format = diffReferenceFrom
+	}
+	return fmt.Sprintf(format, ref.Relationship, ref.Class, ref.ID)
+}
+
+func writeTextDiff(dd *parser.DictionaryDiff, w io.Writer) {
+	if dd.Empty() {
+		fmt.Fprint(w, diffTextNoChanges)
+		return
+	}
+
+	for _, section := range []struct {
+		marker  string
+		changes []parser.DefinitionChange
+	}{{diffTextAdded, dd.Added}, {diffTextRemoved, dd.Removed}, {diffTextModified, dd.Modified}} {
+		for _, c := range section.changes {
+			fmt.Fprintf(w, diffTextDefinition, section.marker, c.Class, c.ID)
+			for _, f := range c.Fields {
+				switch {
+				case f.Old == nil:
+					fmt.Fprintf(w, diffTextField, diffTextAdded, f.Field, formatDiffValue(f.New))
+				case f.New == nil:
+					fmt.Fprintf(w, diffTextField, diffTextRemoved, f.Field, formatDiffValue(f.Old))
+				default:
+					fmt.Fprintf(w, diffTextModifiedField, diffTextModified, f.Field, formatDiffValue(f.Old),
+						formatDiffValue(f.New))
+				}
+			}
+			for _, ref := range c.RemovedReferences {
+				fmt.Fprintf(w, diffTextReference, diffTextRemoved, formatDiffReference(ref))
+			}
+			for _, ref := range c.AddedReferences {
+				fmt.Fprintf(w, diffTextReference, diffTextAdded, formatDiffReference(ref))
+			}
+		}
+	}
+	fmt.Fprintf(w, diffTextSummary, len(dd.Added), len(dd.Removed), len(dd.Modified))
+}
+
+// markdownCode formats the value as inline code within a table cell
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "|", "\\|")
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+func writeMarkdownDiff(dd *parser.DictionaryDiff, w io.Writer) {
+	if dd.Empty() {
+		fmt.Fprint(w, diffMarkdownNoChanges)
+		return
+	}
+
+	fmt.Fprintf(w, diffMarkdownSummary, len(dd.Added), len(dd.Removed), len(dd.Modified))
+	for _, section := range []struct {
+		heading string
+		changes []parser.DefinitionChange
+	}{{diffMarkdownAdded, dd.Added}, {diffMarkdownRemoved, dd.Removed}, {diffMarkdownModified, dd.Modified}} {
+		for _, c := range section.changes {
+			fmt.Fprintf(w, diffMarkdownHeading, section.heading, c.Class+"/"+c.ID)
+			for _, f := range c.Fields {
+				var before, after string
+				if f.Old != nil {
+					before = formatDiffValue(f.Old)
+				}
+				if f.New != nil {
+					after = formatDiffValue(f.New)
+				}
+				fmt.Fprintf(w, diffMarkdownRow, fmt.Sprintf(diffMarkdownField, f.Field), markdownCode(before),
+					markdownCode(after))
+			}
+			for _, ref := range c.RemovedReferences {
+				fmt.Fprintf(w, diffMarkdownRow, diffMarkdownReference, markdownCode(formatDiffReference(ref)), "")
+			}
+			for _, ref := range c.AddedReferences {
+				fmt.Fprintf(w, diffMarkdownRow, diffMarkdownReference, "", markdownCode(formatDiffReference(ref)))
+			}
+		}
+	}
+}
+
+// writeDiff writes the differences in the output format
+func writeDiff(dd *parser.DictionaryDiff, format string, w io.Writer) error {
+	switch format {
+	case outputFormatText:
+		writeTextDiff(dd, w)
+	case outputFormatMarkdown:
+		writeMarkdownDiff(dd, w)
+	case outputFormatJSON:
+		b, err := json.MarshalIndent(dd, "", diffIndent)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	default:
+		return fmt.Errorf(errorInvalidDiffOutput, format)
+	}
+	return nil
+}
+
+// diffDictionaries loads the before and after definitions, either from two directories or from a git revision range
+func diffDictionaries(args []string, rev string, dirs []string, ext string) (*parser.DictionaryDiff, error) {
+	if rev == "" {
+		if len(args) != 2 {
+			return nil, fmt.Errorf(errorDiffArguments)
+		}
+		return parser.DiffDictionaries(parser.LoadDictionary(args[:1], ext), parser.LoadDictionary(args[1:], ext)),
+			nil
+	}
+	if len(args) != 0 {
+		return nil, fmt.Errorf(errorDiffArguments)
+	}
+
+	beforeRev, afterRev := parseRevisionRange(rev)
+	before, err := loadRevision(beforeRev, dirs, ext)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadRevision(afterRev, dirs, ext)
+	if err != nil {
+		return nil, err
+	}
+	return parser.DiffDictionaries(before, after), nil
+}
+
+func diff(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName)
+
+	dd, err := diffDictionaries(args, gitRevision, sourceDir, fileExtension)
+	if err == nil {
+		err = writeDiff(dd, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorDiffFailed)
+		fmt.Println(outputDiffFailure)
+		os.Exit(exitCodeDiffCmdFailed)
+	}
+}
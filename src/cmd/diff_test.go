@@ -0,0 +1,162 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRevisionRange(t *testing.T) {
+	for r, expected := range map[string][2]string{
+		"main..feature": {"main", "feature"},
+		"main..":        {"main", ""},
+		"..feature":     {"HEAD", "feature"},
+		"v1.0":          {"v1.0", ""},
+		"":              {"HEAD", ""},
+	} {
+		before, after := parseRevisionRange(r)
+		assert.Equal(t, expected, [2]string{before, after}, r)
+	}
+}
+
+func Test_writeDiff(t *testing.T) {
+	dd, err := diffDictionaries([]string{"_test/diff/before", "_test/diff/after"}, "", nil, "yaml")
+	assert.Nil(t, err)
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, writeDiff(dd, "text", &buf))
+		assert.Equal(t, `+ Provider/gcp
+  + Name: "Google Cloud"
+- Provider/oracle
+  - Name: "Oracle Cloud"
+~ Service/vm
+  + Cores: 4
+  - Description: "Runs | pipes"
+  ~ Name: "Virtual Machine" -> "VM"
+  - -[HOSTED_BY]-> Provider/azure
+  + -[HOSTED_BY]-> Provider/gcp
+1 added, 1 removed, 1 modified
+`, buf.String())
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, writeDiff(dd, "markdown", &buf))
+		assert.Equal(t, "**1 added, 1 removed, 1 modified**\n"+
+			"\n#### Added `Provider/gcp`\n\n| Change | Before | After |\n|---|---|---|\n"+
+			"| Field `Name` |  | `\"Google Cloud\"` |\n"+
+			"\n#### Removed `Provider/oracle`\n\n| Change | Before | After |\n|---|---|---|\n"+
+			"| Field `Name` | `\"Oracle Cloud\"` |  |\n"+
+			"\n#### Modified `Service/vm`\n\n| Change | Before | After |\n|---|---|---|\n"+
+			"| Field `Cores` |  | `4` |\n"+
+			"| Field `Description` | `\"Runs \\| pipes\"` |  |\n"+
+			"| Field `Name` | `\"Virtual Machine\"` | `\"VM\"` |\n"+
+			"| Relationship | `-[HOSTED_BY]-> Provider/azure` |  |\n"+
+			"| Relationship |  | `-[HOSTED_BY]-> Provider/gcp` |\n", buf.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, writeDiff(dd, "json", &buf))
+		assert.Contains(t, buf.String(), `"Field": "Name",
+          "Old": "Virtual Machine",
+          "New": "VM"`)
+	})
+
+	t.Run("NoChanges", func(t *testing.T) {
+		same, err := diffDictionaries([]string{"_test/diff/before", "_test/diff/before"}, "", nil, "yaml")
+		assert.Nil(t, err)
+		var buf bytes.Buffer
+		assert.Nil(t, writeDiff(same, "text", &buf))
+		assert.Equal(t, "no changes\n", buf.String())
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		assert.NotNil(t, writeDiff(dd, "html", &bytes.Buffer{}))
+	})
+}
+
+func Test_diffDictionaries(t *testing.T) {
+	_, err := diffDictionaries([]string{"_test/diff/before"}, "", nil, "yaml")
+	assert.NotNil(t, err)
+	_, err = diffDictionaries([]string{"_test/diff/before"}, "HEAD", nil, "yaml")
+	assert.NotNil(t, err)
+
+	if _, err = exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	// create a repository with the before definitions committed, and the after definitions in the working tree
+	wd, _ := os.Getwd()
+	before, _ := filepath.Abs("_test/diff/before")
+	after, _ := filepath.Abs("_test/diff/after")
+	repo := t.TempDir()
+	assert.Nil(t, os.Chdir(repo))
+	defer os.Chdir(wd)
+
+	git := func(args ...string) {
+		out, err := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"},
+			args...)...).CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+	copyDir := func(from string) {
+		assert.Nil(t, os.MkdirAll("definition", 0755))
+		for _, f := range []string{"provider.yaml", "service.yaml"} {
+			b, err := os.ReadFile(filepath.Join(from, f))
+			assert.Nil(t, err)
+			assert.Nil(t, os.WriteFile(filepath.Join("definition", f), b, 0644))
+		}
+	}
+	git("init", "-q")
+	copyDir(before)
+	git("add", "-A")
+	git("commit", "-q", "-m", "before")
+	copyDir(after)
+
+	dd, err := diffDictionaries(nil, "HEAD..", []string{"definition"}, "yaml")
+	assert.Nil(t, err)
+	assert.Len(t, dd.Added, 1)
+	assert.Len(t, dd.Removed, 1)
+	assert.Len(t, dd.Modified, 1)
+
+	analyses, err := analyseImpact(nil, "HEAD..", []string{"definition"}, "yaml", nil, parser.ImpactOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, analyses, 1)
+	assert.Equal(t, "oracle", analyses[0].ID)
+	assert.True(t, analyses[0].Missing)
+
+	git("add", "-A")
+	git("commit", "-q", "-m", "after")
+	dd, err = diffDictionaries(nil, "HEAD~1..HEAD", []string{"definition"}, "yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "gcp", dd.Added[0].ID)
+
+	dd, err = diffDictionaries(nil, "HEAD", []string{"definition"}, "yaml")
+	assert.Nil(t, err)
+	assert.True(t, dd.Empty())
+
+	_, err = diffDictionaries(nil, "missing..HEAD", []string{"definition"}, "yaml")
+	assert.NotNil(t, err)
+}
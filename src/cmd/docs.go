@@ -0,0 +1,94 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nextmetaphor/yaml-graph/docs"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputDocsGenerated = "documentation for %d class(es) written to [%s]\n"
+	outputDocsFailure   = "failed to generate documentation"
+
+	logErrorDocsFailed = "docs failed"
+
+	errorNegativeExamples = "the number of examples [%d] cannot be negative"
+)
+
+var (
+	docsCmd = &cobra.Command{
+		Use:   commandDocsUse,
+		Short: commandDocsUseShort,
+		Run:   generateDocs,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+
+	docsCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagSourceUsage)
+	docsCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand,
+		[]string{flagDefinitionFormatDefault}, flagDocsDefinitionFormatUsage)
+	docsCmd.Flags().StringVarP(&outputDir, flagOutputDirName, flagOutputDirShorthand, flagDocsOutputDirDefault,
+		flagDocsOutputDirUsage)
+	docsCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, docs.FormatMarkdown, flagDocsOutputFormatUsage)
+	docsCmd.Flags().IntVar(&docsExamples, flagDocsExamplesName, flagDocsExamplesDefault, flagDocsExamplesUsage)
+}
+
+// buildDocs loads the definition format and the definitions, computing any computed fields so their types can be
+// reported, and documents each class
+func buildDocs(formatFiles, dirs []string, ext string, examples int) (*docs.Documentation, error) {
+	if examples < 0 {
+		return nil, fmt.Errorf(errorNegativeExamples, examples)
+	}
+
+	df, err := buildDefinitionFormat(formatFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	d := parser.LoadDictionary(dirs, ext)
+	if err = parser.ComputeFields(d, df); err != nil {
+		return nil, err
+	}
+
+	return docs.Build(df, d, examples), nil
+}
+
+func generateDocs(c *cobra.Command, _ []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputDirName, flagOutputFormatName, flagDefinitionFormatName)
+
+	doc, err := buildDocs(definitionFormatFile, sourceDir, fileExtension, docsExamples)
+	if err == nil {
+		err = docs.Generate(doc, outputFormat, outputDir)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorDocsFailed)
+		fmt.Println(outputDocsFailure)
+		os.Exit(exitCodeDocsCmdFailed)
+	}
+	fmt.Printf(outputDocsGenerated, len(doc.Classes), outputDir)
+}
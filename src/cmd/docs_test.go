@@ -0,0 +1,49 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/docs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_buildDocs(t *testing.T) {
+	doc, err := buildDocs([]string{"_test/docs/format.yml"}, []string{"_test/diff/before"}, "yaml", 1)
+	assert.Nil(t, err)
+	assert.Len(t, doc.Classes, 2)
+
+	service := doc.Classes[1]
+	assert.Equal(t, "A service offered by a provider", service.Description)
+	assert.Equal(t, "Length", service.Fields[1].Name)
+	assert.Equal(t, []string{"integer"}, service.Fields[1].Types)
+	assert.Len(t, service.Examples, 1)
+
+	dir := t.TempDir()
+	assert.Nil(t, docs.Generate(doc, docs.FormatMarkdown, dir))
+	b, err := os.ReadFile(filepath.Join(dir, "Service.md"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), "| `Description` | optional | string | A short description of the service |")
+
+	_, err = buildDocs([]string{"_test/docs/format.yml"}, []string{"_test/diff/before"}, "yaml", -1)
+	assert.EqualError(t, err, "the number of examples [-1] cannot be negative")
+	_, err = buildDocs([]string{"_test/docs/missing.yml"}, []string{"_test/diff/before"}, "yaml", 1)
+	assert.NotNil(t, err)
+}
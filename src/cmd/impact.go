@@ -0,0 +1,209 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	impactTextDefinition = "%s/%s%s\n"
+	impactTextNode       = "%s%s/%s [%s]%s\n"
+	impactTextIncoming   = diffIndent + "referenced by:\n"
+	impactTextOutgoing   = diffIndent + "references:\n"
+	impactTextCounts     = diffIndent + "counts:\n"
+	impactTextCount      = diffIndent + diffIndent + "%s: %d\n"
+	impactTextMissing    = " (missing)"
+	impactTextNoRemoved  = "no definitions removed\n"
+
+	outputImpactFailure    = "failed to analyse impact"
+	outputImpactReferenced = "%d definition(s) still referenced\n"
+
+	logErrorImpactFailed = "impact failed"
+
+	errorInvalidImpactOutput = "invalid output format [%s]; must be one of text or json"
+	errorImpactArguments     = "either a definition in the form Class/ID or --git-rev must be provided"
+)
+
+var (
+	impactCmd = &cobra.Command{
+		Use:   commandImpactUse,
+		Short: commandImpactUseShort,
+		Long:  commandImpactUseLong,
+		Args:  cobra.MaximumNArgs(1),
+		Run:   impact,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(impactCmd)
+
+	impactCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagSourceUsage)
+	impactCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand,
+		nil, flagGraphDefinitionFormatUsage)
+	impactCmd.Flags().IntVar(&depth, flagDepthName, 0, flagDepthUsage)
+	impactCmd.Flags().StringSliceVar(&relationships, flagRelationshipName, nil, flagRelationshipUsage)
+	impactCmd.Flags().StringVar(&gitRevision, flagGitRevisionName, "", flagImpactGitRevisionUsage)
+	impactCmd.Flags().BoolVar(&failIfReferenced, flagFailIfReferencedName, false, flagFailIfReferencedUsage)
+	impactCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatText, flagImpactOutputFormatUsage)
+}
+
+// analyseImpact analyses the definition in the source directories or, if a git revision range is provided, each
+// definition removed between the revisions, using the definitions at the later revision
+func analyseImpact(args []string, rev string, dirs []string, ext string, df *parser.DefinitionFormat,
+	opts parser.ImpactOptions) ([]*parser.ImpactAnalysis, error) {
+	if rev == "" {
+		if len(args) != 1 {
+			return nil, fmt.Errorf(errorImpactArguments)
+		}
+		class, id, err := parseDefinitionArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []*parser.ImpactAnalysis{parser.Impact(parser.LoadDictionary(dirs, ext), df, class, id, opts)}, nil
+	}
+	if len(args) != 0 {
+		return nil, fmt.Errorf(errorImpactArguments)
+	}
+
+	beforeRev, afterRev := parseRevisionRange(rev)
+	before, err := loadRevision(beforeRev, dirs, ext)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadRevision(afterRev, dirs, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	var analyses []*parser.ImpactAnalysis
+	for _, removed := range parser.DiffDictionaries(before, after).Removed {
+		analyses = append(analyses, parser.Impact(after, df, removed.Class, removed.ID, opts))
+	}
+	return analyses, nil
+}
+
+func writeImpactNodes(nodes []*parser.ImpactNode, indent string, w io.Writer) {
+	for _, n := range nodes {
+		missing := ""
+		if n.Missing {
+			missing = impactTextMissing
+		}
+		fmt.Fprintf(w, impactTextNode, indent, n.Class, n.ID, n.Relationship, missing)
+		writeImpactNodes(n.Children, indent+diffIndent, w)
+	}
+}
+
+func writeTextImpact(analyses []*parser.ImpactAnalysis, w io.Writer) {
+	for _, ia := range analyses {
+		missing := ""
+		if ia.Missing {
+			missing = impactTextMissing
+		}
+		fmt.Fprintf(w, impactTextDefinition, ia.Class, ia.ID, missing)
+
+		if len(ia.Incoming) > 0 {
+			fmt.Fprint(w, impactTextIncoming)
+			writeImpactNodes(ia.Incoming, strings.Repeat(diffIndent, 2), w)
+		}
+		if len(ia.Outgoing) > 0 {
+			fmt.Fprint(w, impactTextOutgoing)
+			writeImpactNodes(ia.Outgoing, strings.Repeat(diffIndent, 2), w)
+		}
+
+		if len(ia.Counts) > 0 {
+			classes := make([]string, 0, len(ia.Counts))
+			for class := range ia.Counts {
+				classes = append(classes, class)
+			}
+			sort.Strings(classes)
+			fmt.Fprint(w, impactTextCounts)
+			for _, class := range classes {
+				fmt.Fprintf(w, impactTextCount, class, ia.Counts[class])
+			}
+		}
+	}
+}
+
+// writeImpact writes the analyses in the output format
+func writeImpact(analyses []*parser.ImpactAnalysis, format string, w io.Writer) error {
+	switch format {
+	case outputFormatText:
+		if len(analyses) == 0 {
+			fmt.Fprint(w, impactTextNoRemoved)
+		}
+		writeTextImpact(analyses, w)
+	case outputFormatJSON:
+		if analyses == nil {
+			analyses = []*parser.ImpactAnalysis{}
+		}
+		b, err := json.MarshalIndent(analyses, "", diffIndent)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	default:
+		return fmt.Errorf(errorInvalidImpactOutput, format)
+	}
+	return nil
+}
+
+// countReferenced returns the number of analysed definitions which are referenced by other definitions
+func countReferenced(analyses []*parser.ImpactAnalysis) (count int) {
+	for _, ia := range analyses {
+		if ia.Referenced() {
+			count++
+		}
+	}
+	return count
+}
+
+func impact(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName, flagDepthName)
+
+	var analyses []*parser.ImpactAnalysis
+	df, err := loadDefinitionFormat(c)
+	if err == nil {
+		analyses, err = analyseImpact(args, gitRevision, sourceDir, fileExtension, df,
+			parser.ImpactOptions{Depth: depth, Relationships: relationships})
+	}
+	if err == nil {
+		err = writeImpact(analyses, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorImpactFailed)
+		fmt.Println(outputImpactFailure)
+		os.Exit(exitCodeImpactCmdFailed)
+	}
+
+	if referenced := countReferenced(analyses); failIfReferenced && referenced > 0 {
+		fmt.Fprintf(os.Stderr, outputImpactReferenced, referenced)
+		os.Exit(exitCodeImpactCmdFailed)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_analyseImpact(t *testing.T) {
+	analyses, err := analyseImpact([]string{"Provider/azure"}, "", []string{"_test/diff/before"}, "yaml", nil,
+		parser.ImpactOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, countReferenced(analyses))
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeImpact(analyses, "text", &buf))
+	assert.Equal(t, `Provider/azure
+  referenced by:
+    Service/vm [HOSTED_BY]
+  counts:
+    Service: 1
+`, buf.String())
+
+	// the definition has been removed but is still referenced
+	analyses, err = analyseImpact([]string{"Provider/oracle"}, "", []string{"_test/diff/after"}, "yaml", nil,
+		parser.ImpactOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countReferenced(analyses))
+	buf.Reset()
+	assert.Nil(t, writeImpact(analyses, "text", &buf))
+	assert.Equal(t, "Provider/oracle (missing)\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeImpact(nil, "json", &buf))
+	assert.Equal(t, "[]\n", buf.String())
+	assert.NotNil(t, writeImpact(nil, "markdown", &buf))
+
+	_, err = analyseImpact(nil, "", nil, "yaml", nil, parser.ImpactOptions{})
+	assert.NotNil(t, err)
+	_, err = analyseImpact([]string{"Provider"}, "", nil, "yaml", nil, parser.ImpactOptions{})
+	assert.NotNil(t, err)
+	_, err = analyseImpact([]string{"Provider/azure"}, "HEAD", nil, "yaml", nil, parser.ImpactOptions{})
+	assert.NotNil(t, err)
+}
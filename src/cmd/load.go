@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/nextmetaphor/yaml-graph/definition"
 	"github.com/nextmetaphor/yaml-graph/graph"
+	"github.com/nextmetaphor/yaml-graph/parser"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -31,6 +32,7 @@ const (
 	logDebugSuccessfullyLoadedFile        = "successfully loaded file [%s]"
 	logWarnSkippingFile                   = "skipping file [%s] due to error [%s]"
 	logErrorGraphDatabaseConnectionFailed = "graph database connection failed"
+	logErrorCouldNotComputeFields         = "could not compute fields"
 )
 
 var (
@@ -46,11 +48,45 @@ func init() {
 
 	loadCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault}, flagSourceUsage)
 	// default value provided so no need to mark flag as required
+
+	loadCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+		flagLoadDefinitionFormatUsage)
+}
+
+// loadDefinitionFormat builds the DefinitionFormat, but only if a definition format has been provided to the command
+func loadDefinitionFormat(c *cobra.Command) (*parser.DefinitionFormat, error) {
+	if c == nil || c.Flags().Lookup(flagDefinitionFormatName) == nil || !c.Flags().Changed(flagDefinitionFormatName) {
+		return nil, nil
+	}
+
+	return buildDefinitionFormat(definitionFormatFile)
+}
+
+// loadComputedFields builds the Dictionary and evaluates any computed fields, but only if a definition format has
+// been explicitly provided
+func loadComputedFields(c *cobra.Command) (parser.Dictionary, *parser.DefinitionFormat, error) {
+	df, err := loadDefinitionFormat(c)
+	if df == nil || err != nil {
+		return nil, nil, err
+	}
+
+	d := parser.LoadDictionary(sourceDir, fileExtension)
+	if err = parser.ComputeFields(d, df); err != nil {
+		return nil, nil, err
+	}
+
+	return d, df, nil
 }
 
-func load(_ *cobra.Command, _ []string) {
+func load(c *cobra.Command, _ []string) {
 	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
 
+	d, df, err := loadComputedFields(c)
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorCouldNotComputeFields)
+		os.Exit(exitCodeLoadCmdFailed)
+	}
+
 	driver, session, err := graph.Init(dbURL, username, password)
 	if err != nil {
 		log.Error().Err(err).Msg(logErrorGraphDatabaseConnectionFailed)
@@ -62,6 +98,9 @@ func load(_ *cobra.Command, _ []string) {
 
 	graph.DeleteAll(session)
 
+	// definitions of a class which extends another are also labelled with each of its base classes
+	labels := df.ClassLabels()
+
 	// First create the nodes...
 	for _, dir := range sourceDir {
 		definition.ProcessFiles(dir, fileExtension, func(filePath string, _ os.FileInfo) (err error) {
@@ -70,7 +109,10 @@ func load(_ *cobra.Command, _ []string) {
 			spec, err := definition.LoadSpecificationFromFile(filePath)
 			if (err == nil) && (spec != nil) {
 				log.Debug().Msg(fmt.Sprintf(logDebugSuccessfullyLoadedFile, filePath))
-				graph.CreateSpecification(session, *spec)
+				if d != nil {
+					parser.ApplyComputedFields(spec, d, df)
+				}
+				graph.CreateSpecification(session, *spec, labels)
 
 			} else {
 				log.Warn().Msgf(logWarnSkippingFile, filePath, err)
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/nextmetaphor/yaml-graph/lsp"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	logErrorLSPFailed = "language server failed"
+)
+
+var (
+	lspCmd = &cobra.Command{
+		Use:   commandLSPUse,
+		Short: commandLSPUseShort,
+		Run:   runLSP,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+
+	lspCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, nil, flagLSPSourceUsage)
+	lspCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+		flagLSPDefinitionFormatUsage)
+}
+
+func runLSP(c *cobra.Command, _ []string) {
+	// note: logs are written to stderr, leaving stdout for the protocol
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+
+	// the flag variables are shared with other commands, so only use them if explicitly provided
+	lspSourceDir := sourceDir
+	if !c.Flags().Changed(flagSourceName) {
+		lspSourceDir = nil
+	}
+
+	var df *parser.DefinitionFormat
+	if c.Flags().Changed(flagDefinitionFormatName) {
+		var err error
+		if df, err = buildDefinitionFormat(definitionFormatFile); err != nil {
+			os.Exit(exitCodeLSPCmdFailed)
+		}
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout, lspSourceDir, fileExtension, df).Run(); err != nil {
+		log.Error().Err(err).Msg(logErrorLSPFailed)
+		os.Exit(exitCodeLSPCmdFailed)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputFormatTable   = "table"
+	outputFormatMermaid = "mermaid"
+
+	pathTableHeader       = "LENGTH\tPATH\n"
+	pathTableRow          = "%d\t%s\n"
+	neighbourTableHeader  = "DEPTH\tCLASS\tID\n"
+	neighbourTableRow     = "%d\t%s\t%s%s\n"
+	pathTableNoPath       = "no path found\n"
+	tableMinWidth         = 0
+	tableTabWidth         = 8
+	tablePadding          = 2
+	tablePadChar          = ' '
+	mermaidHeader         = "graph LR\n"
+	mermaidNode           = diffIndent + "n%d[\"%s\"]\n"
+	mermaidRelationship   = diffIndent + "n%d -->|%s| n%d\n"
+	mermaidQuote          = "#quot;"
+	outputPathFailure     = "failed to find paths"
+	outputNeighbourFailed = "failed to find neighbours"
+
+	logErrorPathFailed      = "path failed"
+	logErrorNeighbourFailed = "neighbours failed"
+
+	errorInvalidPathOutput = "invalid output format [%s]; must be one of table, json or mermaid"
+)
+
+var (
+	pathCmd = &cobra.Command{
+		Use:   commandPathUse,
+		Short: commandPathUseShort,
+		Args:  cobra.ExactArgs(2),
+		Run:   path,
+	}
+
+	neighboursCmd = &cobra.Command{
+		Use:   commandNeighboursUse,
+		Short: commandNeighboursUseShort,
+		Args:  cobra.ExactArgs(1),
+		Run:   neighbours,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(pathCmd)
+	rootCmd.AddCommand(neighboursCmd)
+
+	for _, c := range []*cobra.Command{pathCmd, neighboursCmd} {
+		c.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+			flagSourceUsage)
+		c.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+			flagGraphDefinitionFormatUsage)
+		c.Flags().StringSliceVar(&relationships, flagRelationshipName, nil, flagRelationshipUsage)
+		c.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatTable, flagPathOutputFormatUsage)
+	}
+	pathCmd.Flags().BoolVar(&allPaths, flagAllPathsName, false, flagAllPathsUsage)
+	pathCmd.Flags().IntVar(&maxLength, flagMaxLengthName, flagMaxLengthDefault, flagMaxLengthUsage)
+	neighboursCmd.Flags().IntVar(&depth, flagDepthName, flagNeighbourDepthDefault, flagDepthUsage)
+}
+
+// findPaths returns the shortest path between the definitions, or every simple path if all is set
+func findPaths(d parser.Dictionary, df *parser.DefinitionFormat, fromArg, toArg string, all bool,
+	opts parser.PathOptions) ([]parser.Path, error) {
+	fromClass, fromID, err := parseDefinitionArg(fromArg)
+	if err != nil {
+		return nil, err
+	}
+	toClass, toID, err := parseDefinitionArg(toArg)
+	if err != nil {
+		return nil, err
+	}
+	from := parser.DefinitionKey{Class: fromClass, ID: fromID}
+	to := parser.DefinitionKey{Class: toClass, ID: toID}
+
+	if all {
+		return parser.AllPaths(d, df, from, to, opts), nil
+	}
+	if p := parser.ShortestPath(d, df, from, to, opts); p != nil {
+		return []parser.Path{*p}, nil
+	}
+	return nil, nil
+}
+
+func newTableWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, tableMinWidth, tableTabWidth, tablePadding, tablePadChar, 0)
+}
+
+// writeMermaid writes the definitions and relationships as a Mermaid flowchart
+func writeMermaid(keys []parser.DefinitionKey, rels []parser.Relationship, w io.Writer) {
+	fmt.Fprint(w, mermaidHeader)
+	nodes := map[parser.DefinitionKey]int{}
+	node := func(k parser.DefinitionKey) int {
+		n, found := nodes[k]
+		if !found {
+			n = len(nodes)
+			nodes[k] = n
+			fmt.Fprintf(w, mermaidNode, n, strings.ReplaceAll(k.String(), `"`, mermaidQuote))
+		}
+		return n
+	}
+
+	for _, k := range keys {
+		node(k)
+	}
+	seen := map[parser.Relationship]bool{}
+	for _, r := range rels {
+		if !seen[r] {
+			seen[r] = true
+			fmt.Fprintf(w, mermaidRelationship, node(r.From), strings.ReplaceAll(r.Name, `"`, mermaidQuote), node(r.To))
+		}
+	}
+}
+
+func writeJSON(v interface{}, w io.Writer) error {
+	b, err := json.MarshalIndent(v, "", diffIndent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// writePaths writes the paths in the output format
+func writePaths(paths []parser.Path, format string, w io.Writer) error {
+	switch format {
+	case outputFormatTable:
+		if len(paths) == 0 {
+			fmt.Fprint(w, pathTableNoPath)
+			return nil
+		}
+		tw := newTableWriter(w)
+		fmt.Fprint(tw, pathTableHeader)
+		for _, p := range paths {
+			fmt.Fprintf(tw, pathTableRow, len(p.Steps), p.String())
+		}
+		return tw.Flush()
+	case outputFormatJSON:
+		if paths == nil {
+			paths = []parser.Path{}
+		}
+		return writeJSON(paths, w)
+	case outputFormatMermaid:
+		var keys []parser.DefinitionKey
+		var rels []parser.Relationship
+		for _, p := range paths {
+			keys = append(keys, p.Start)
+			for _, s := range p.Steps {
+				keys = append(keys, s.To)
+			}
+			rels = append(rels, p.Relationships()...)
+		}
+		writeMermaid(keys, rels, w)
+		return nil
+	}
+	return fmt.Errorf(errorInvalidPathOutput, format)
+}
+
+// writeNeighbourhood writes the neighbourhood in the output format
+func writeNeighbourhood(n *parser.Neighbourhood, format string, w io.Writer) error {
+	switch format {
+	case outputFormatTable:
+		tw := newTableWriter(w)
+		fmt.Fprint(tw, neighbourTableHeader)
+		for _, nb := range n.Definitions {
+			missing := ""
+			if nb.Missing {
+				missing = impactTextMissing
+			}
+			fmt.Fprintf(tw, neighbourTableRow, nb.Depth, nb.Class, nb.ID, missing)
+		}
+		return tw.Flush()
+	case outputFormatJSON:
+		return writeJSON(n, w)
+	case outputFormatMermaid:
+		keys := make([]parser.DefinitionKey, 0, len(n.Definitions))
+		for _, nb := range n.Definitions {
+			keys = append(keys, nb.DefinitionKey)
+		}
+		writeMermaid(keys, n.Relationships, w)
+		return nil
+	}
+	return fmt.Errorf(errorInvalidPathOutput, format)
+}
+
+func path(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName)
+
+	var paths []parser.Path
+	df, err := loadDefinitionFormat(c)
+	if err == nil {
+		paths, err = findPaths(parser.LoadDictionary(sourceDir, fileExtension), df, args[0], args[1], allPaths,
+			parser.PathOptions{MaxLength: maxLength, Relationships: relationships})
+	}
+	if err == nil {
+		err = writePaths(paths, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorPathFailed)
+		fmt.Println(outputPathFailure)
+		os.Exit(exitCodePathCmdFailed)
+	}
+}
+
+func neighbours(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName, flagDepthName)
+
+	df, err := loadDefinitionFormat(c)
+	var class, id string
+	if err == nil {
+		class, id, err = parseDefinitionArg(args[0])
+	}
+	if err == nil {
+		n := parser.Neighbours(parser.LoadDictionary(sourceDir, fileExtension), df,
+			parser.DefinitionKey{Class: class, ID: id}, depth, relationships)
+		err = writeNeighbourhood(n, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorNeighbourFailed)
+		fmt.Println(outputNeighbourFailed)
+		os.Exit(exitCodePathCmdFailed)
+	}
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writePaths(t *testing.T) {
+	d := parser.LoadDictionary([]string{"_test/diff/before"}, "yaml")
+
+	paths, err := findPaths(d, nil, "Provider/azure", "Service/vm", false, parser.PathOptions{})
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, writePaths(paths, "table", &buf))
+	assert.Equal(t, "LENGTH  PATH\n1       Provider/azure <-[HOSTED_BY]- Service/vm\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writePaths(paths, "mermaid", &buf))
+	assert.Equal(t, `graph LR
+  n0["Provider/azure"]
+  n1["Service/vm"]
+  n1 -->|HOSTED_BY| n0
+`, buf.String())
+
+	paths, err = findPaths(d, nil, "Provider/oracle", "Service/vm", true, parser.PathOptions{})
+	assert.Nil(t, err)
+	buf.Reset()
+	assert.Nil(t, writePaths(paths, "table", &buf))
+	assert.Equal(t, "no path found\n", buf.String())
+	buf.Reset()
+	assert.Nil(t, writePaths(paths, "json", &buf))
+	assert.Equal(t, "[]\n", buf.String())
+
+	assert.NotNil(t, writePaths(paths, "csv", &buf))
+	_, err = findPaths(d, nil, "Provider", "Service/vm", false, parser.PathOptions{})
+	assert.NotNil(t, err)
+}
+
+func Test_writeNeighbourhood(t *testing.T) {
+	d := parser.LoadDictionary([]string{"_test/diff/after"}, "yaml")
+	n := parser.Neighbours(d, nil, parser.DefinitionKey{Class: "Service", ID: "vm"}, 1, nil)
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeNeighbourhood(n, "table", &buf))
+	assert.Equal(t, "DEPTH  CLASS     ID\n0      Service   vm\n1      Provider  gcp\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeNeighbourhood(n, "json", &buf))
+	assert.Contains(t, buf.String(), `"Name": "HOSTED_BY"`)
+
+	buf.Reset()
+	assert.Nil(t, writeNeighbourhood(n, "mermaid", &buf))
+	assert.Contains(t, buf.String(), "n0 -->|HOSTED_BY| n1\n")
+}
+
+func Test_useCommandDefaults(t *testing.T) {
+	outputFormat = "table"
+	depth = 1
+	useCommandDefaults(impactCmd, flagOutputFormatName, flagDepthName)
+	assert.Equal(t, "text", outputFormat)
+	assert.Equal(t, 0, depth)
+
+	useCommandDefaults(neighboursCmd, flagOutputFormatName, flagDepthName)
+	assert.Equal(t, "table", outputFormat)
+	assert.Equal(t, 1, depth)
+
+	useCommandDefaults(siteCmd, flagOutputDirName)
+	assert.Equal(t, "site", outputDir)
+	useCommandDefaults(schemaCmd, flagOutputDirName)
+	assert.Equal(t, "", outputDir)
+	useCommandDefaults(reportCmd, flagOutputDirName)
+	assert.Equal(t, "", outputDir)
+
+	definitionFormatFile = nil
+	useCommandDefaults(schemaCmd, flagDefinitionFormatName)
+	assert.Equal(t, []string{flagDefinitionFormatDefault}, definitionFormatFile)
+	useCommandDefaults(loadCmd, flagDefinitionFormatName)
+	assert.Empty(t, definitionFormatFile)
+	useCommandDefaults(docsCmd, flagDefinitionFormatName)
+	assert.Equal(t, []string{flagDefinitionFormatDefault}, definitionFormatFile)
+}
+
+func Test_sliceDefault(t *testing.T) {
+	assert.Equal(t, []string{}, sliceDefault("[]"))
+	assert.Equal(t, []string{"definition/format.yml"}, sliceDefault("[definition/format.yml]"))
+	assert.Equal(t, []string{"a.yml", "b,c.yml"}, sliceDefault(`[a.yml,"b,c.yml"]`))
+}
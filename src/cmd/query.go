@@ -0,0 +1,194 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/expression"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputFormatCSV  = "csv"
+	outputFormatYAML = "yaml"
+
+	outputQueryFailure = "failed to run query"
+
+	logErrorQueryFailed = "query failed"
+
+	errorInvalidQueryOutput = "invalid output format [%s]; must be one of table, csv, json or yaml"
+)
+
+var (
+	queryCmd = &cobra.Command{
+		Use:   commandQueryUse,
+		Short: commandQueryUseShort,
+		Long:  commandQueryUseLong,
+		Args:  cobra.ExactArgs(1),
+		Run:   query,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagSourceUsage)
+	queryCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+		flagQueryDefinitionFormatUsage)
+	queryCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatTable, flagQueryOutputFormatUsage)
+}
+
+// marshalQueryJSON returns the rows as an array of objects, keeping the order of the columns
+func marshalQueryJSON(r *parser.QueryResult) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range r.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("{")
+		for j, c := range r.Columns {
+			if j > 0 {
+				buf.WriteString(",")
+			}
+			k, err := json.Marshal(c)
+			if err != nil {
+				return nil, err
+			}
+			v, err := json.Marshal(row[j])
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(k)
+			buf.WriteString(":")
+			buf.Write(v)
+		}
+		buf.WriteString("}")
+	}
+	buf.WriteString("]")
+
+	var indented bytes.Buffer
+	err := json.Indent(&indented, buf.Bytes(), "", diffIndent)
+	return indented.Bytes(), err
+}
+
+// marshalQueryYAML returns the rows as a sequence of mappings, keeping the order of the columns
+func marshalQueryYAML(r *parser.QueryResult) ([]byte, error) {
+	seq := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, row := range r.Rows {
+		m := &yaml.Node{Kind: yaml.MappingNode}
+		for j, c := range r.Columns {
+			var v yaml.Node
+			if err := v.Encode(row[j]); err != nil {
+				return nil, err
+			}
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: c}, &v)
+		}
+		seq.Content = append(seq.Content, m)
+	}
+	if len(seq.Content) == 0 {
+		seq.Style = yaml.FlowStyle
+	}
+	return yaml.Marshal(seq)
+}
+
+// writeQueryResult writes the result in the output format
+func writeQueryResult(r *parser.QueryResult, format string, w io.Writer) error {
+	switch format {
+	case outputFormatTable:
+		tw := newTableWriter(w)
+		fmt.Fprintln(tw, strings.Join(r.Columns, "\t"))
+		for _, row := range r.Rows {
+			values := make([]string, len(row))
+			for i, v := range row {
+				values[i] = expression.ToString(v)
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		return tw.Flush()
+	case outputFormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(r.Columns)
+		for _, row := range r.Rows {
+			values := make([]string, len(row))
+			for i, v := range row {
+				values[i] = expression.ToString(v)
+			}
+			cw.Write(values)
+		}
+		cw.Flush()
+		return cw.Error()
+	case outputFormatJSON:
+		b, err := marshalQueryJSON(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case outputFormatYAML:
+		b, err := marshalQueryYAML(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	return fmt.Errorf(errorInvalidQueryOutput, format)
+}
+
+// runQuery parses the query and evaluates it against the definitions; the DefinitionFormat is optional
+func runQuery(source string, d parser.Dictionary, df *parser.DefinitionFormat) (*parser.QueryResult, error) {
+	q, err := parser.ParseQuery(source)
+	if err != nil {
+		return nil, err
+	}
+	return q.Evaluate(d, df)
+}
+
+func query(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName)
+
+	d, df, err := loadComputedFields(c)
+	var r *parser.QueryResult
+	if err == nil {
+		if d == nil {
+			d = parser.LoadDictionary(sourceDir, fileExtension)
+		}
+		r, err = runQuery(args[0], d, df)
+	}
+	if err == nil {
+		err = writeQueryResult(r, outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorQueryFailed)
+		fmt.Println(outputQueryFailure)
+		os.Exit(exitCodeQueryCmdFailed)
+	}
+}
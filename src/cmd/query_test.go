@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeQueryResult(t *testing.T) {
+	d := parser.LoadDictionary([]string{"_test/diff/before"}, "yaml")
+	r, err := runQuery("Service{ID, Name, Description}", d, nil)
+	assert.Nil(t, err)
+
+	for format, expected := range map[string]string{
+		"table": "ID  Name             Description\nvm  Virtual Machine  Runs | pipes\n",
+		"csv":   "ID,Name,Description\nvm,Virtual Machine,Runs | pipes\n",
+		"json": `[
+  {
+    "ID": "vm",
+    "Name": "Virtual Machine",
+    "Description": "Runs | pipes"
+  }
+]
+`,
+		"yaml": "- ID: vm\n  Name: Virtual Machine\n  Description: Runs | pipes\n",
+	} {
+		var buf bytes.Buffer
+		assert.Nil(t, writeQueryResult(r, format, &buf), format)
+		assert.Equal(t, expected, buf.String(), format)
+	}
+
+	assert.NotNil(t, writeQueryResult(r, "mermaid", &bytes.Buffer{}))
+
+	r, err = runQuery("Service[ID=none]", d, nil)
+	assert.Nil(t, err)
+	for format, expected := range map[string]string{"json": "[]\n", "yaml": "[]\n"} {
+		var buf bytes.Buffer
+		assert.Nil(t, writeQueryResult(r, format, &buf), format)
+		assert.Equal(t, expected, buf.String(), format)
+	}
+
+	_, err = runQuery("Service[", d, nil)
+	assert.NotNil(t, err)
+}
+
+func Test_runQuery(t *testing.T) {
+	d := parser.LoadDictionary([]string{"_test/diff/before"}, "yaml")
+	df := &parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{
+		"Service": {ComputedFields: map[string]parser.ComputedField{"Label": {Expression: `ID + ": " + Name`}}},
+	}}
+
+	r, err := runQuery(`Service[Label="vm: Virtual Machine"]`, d, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, r.Rows)
+
+	// as with the query command, computed fields are evaluated before the query
+	assert.Nil(t, parser.ComputeFields(d, df))
+	r, err = runQuery(`Service[Label="vm: Virtual Machine"]{ID, Label}`, d, df)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]interface{}{{"vm", "vm: Virtual Machine"}}, r.Rows)
+}
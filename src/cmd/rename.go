@@ -0,0 +1,135 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/refactor"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputRenameFailure = "failed to rename"
+	outputRenameSummary = "updated %d file(s)\n"
+
+	logErrorRenameFailed = "rename failed"
+
+	errorInvalidDefinitionArg = "definition [%s] must be in the form Class/ID"
+)
+
+var (
+	renameCmd = &cobra.Command{
+		Use:   commandRenameUse,
+		Short: commandRenameUseShort,
+		Args:  cobra.ExactArgs(2),
+		Run:   rename,
+	}
+
+	renameClassCmd = &cobra.Command{
+		Use:   commandRenameClassUse,
+		Short: commandRenameClassUseShort,
+		Args:  cobra.ExactArgs(2),
+		Run:   renameClass,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(renameClassCmd)
+
+	for _, c := range []*cobra.Command{renameCmd, renameClassCmd} {
+		c.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+			flagSourceUsage)
+		c.Flags().BoolVar(&dryRun, flagDryRunName, false, flagDryRunUsage)
+	}
+	renameClassCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName,
+		flagDefinitionFormatShorthand, nil, flagRenameDefinitionFormatUsage)
+}
+
+// parseDefinitionArg splits a Class/ID argument; the ID may itself contain a slash
+func parseDefinitionArg(arg string) (class, id string, err error) {
+	class, id, ok := strings.Cut(arg, "/")
+	if !ok || class == "" || id == "" {
+		return "", "", fmt.Errorf(errorInvalidDefinitionArg, arg)
+	}
+	return class, id, nil
+}
+
+// applyChanges writes the changes, or prints them as a diff if dry is set
+func applyChanges(changes []refactor.FileChange, dry bool, w io.Writer) error {
+	if dry {
+		for _, c := range changes {
+			if _, err := fmt.Fprint(w, c.Diff()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := refactor.Apply(changes); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, outputRenameSummary, len(changes))
+	return err
+}
+
+func exitRenameFailed(err error) {
+	log.Error().Err(err).Msg(logErrorRenameFailed)
+	fmt.Println(outputRenameFailure)
+	os.Exit(exitCodeRenameCmdFailed)
+}
+
+func rename(_ *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+
+	class, oldID, err := parseDefinitionArg(args[0])
+	if err != nil {
+		exitRenameFailed(err)
+	}
+
+	changes, err := refactor.RenameDefinition(sourceDir, fileExtension, class, oldID, args[1])
+	if err == nil {
+		err = applyChanges(changes, dryRun, os.Stdout)
+	}
+	if err != nil {
+		exitRenameFailed(err)
+	}
+}
+
+func renameClass(c *cobra.Command, args []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+
+	// the flag variable is shared with other commands, so only use it if explicitly provided
+	var formatFiles []string
+	if c.Flags().Changed(flagDefinitionFormatName) {
+		formatFiles = definitionFormatFile
+	}
+
+	changes, err := refactor.RenameClass(sourceDir, fileExtension, formatFiles, args[0], args[1])
+	if err == nil {
+		err = applyChanges(changes, dryRun, os.Stdout)
+	}
+	if err != nil {
+		exitRenameFailed(err)
+	}
+}
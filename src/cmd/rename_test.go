@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/refactor"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseDefinitionArg(t *testing.T) {
+	class, id, err := parseDefinitionArg("Provider/azure")
+	assert.Nil(t, err)
+	assert.Equal(t, "Provider", class)
+	assert.Equal(t, "azure", id)
+
+	_, id, err = parseDefinitionArg("Path/a/b")
+	assert.Nil(t, err)
+	assert.Equal(t, "a/b", id)
+
+	for _, arg := range []string{"Provider", "/azure", "Provider/"} {
+		_, _, err = parseDefinitionArg(arg)
+		assert.NotNil(t, err, arg)
+	}
+}
+
+func Test_applyChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "provider.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("Class: Provider\n"), 0644))
+	changes := []refactor.FileChange{{Path: path, Original: []byte("Class: Provider\n"),
+		Updated: []byte("Class: Vendor\n")}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, applyChanges(changes, true, &buf))
+	assert.Contains(t, buf.String(), "-Class: Provider\n+Class: Vendor\n")
+	b, _ := os.ReadFile(path)
+	assert.Equal(t, "Class: Provider\n", string(b))
+
+	buf.Reset()
+	assert.Nil(t, applyChanges(changes, false, &buf))
+	assert.Equal(t, "updated 1 file(s)\n", buf.String())
+	b, _ = os.ReadFile(path)
+	assert.Equal(t, "Class: Vendor\n", string(b))
+}
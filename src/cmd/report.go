@@ -23,11 +23,18 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"os"
+	"strconv"
+	"strings"
 )
 
 const (
 	outputTemplateFailure = "failed to generate template"
 	logErrorReportFailed  = "report failed"
+
+	reportParamTypeSeparator = ":"
+
+	errorInvalidReportParamType  = "invalid type [%s] for parameter [%s]; must be one of string, int, float or bool"
+	errorInvalidReportParamValue = "invalid %s value [%s] for parameter [%s]"
 )
 
 var (
@@ -41,8 +48,8 @@ var (
 func init() {
 	rootCmd.AddCommand(reportCmd)
 
-	reportCmd.Flags().StringVarP(&templateName, flagReportTemplateFileName, flagReportTemplateFileShorthand,
-		"", flagReportTemplateFileUsage)
+	reportCmd.Flags().StringSliceVarP(&templateFiles, flagReportTemplateFileName, flagReportTemplateFileShorthand,
+		nil, flagReportTemplateFileUsage)
 	if err := reportCmd.MarkFlagRequired(flagReportTemplateFileName); err != nil {
 		log.Error().Err(err).Msg(logErrorReportFailed)
 		os.Exit(exitCodeTemplateCmdFailed)
@@ -55,22 +62,86 @@ func init() {
 		os.Exit(exitCodeTemplateCmdFailed)
 	}
 
+	reportCmd.Flags().StringVar(&templateName, flagReportTemplateNameName, "", flagReportTemplateNameUsage)
+	reportCmd.Flags().StringVarP(&outputDir, flagOutputDirName, flagOutputDirShorthand, "", flagReportOutputDirUsage)
+	reportCmd.Flags().BoolVar(&perDefinition, flagReportPerDefinitionName, false, flagReportPerDefinitionUsage)
+	reportCmd.Flags().StringVar(&reportFileName, flagReportFileNameName, "", flagReportFileNameUsage)
+	reportCmd.Flags().StringArrayVar(&cypherParams, flagCypherParamName, nil, flagReportParamUsage)
+	reportCmd.Flags().StringVar(&templateEngine, flagReportTemplateEngineName, parser.TemplateEngineAuto,
+		flagReportTemplateEngineUsage)
+
 	reportCmd.Flags().BoolVarP(&loadDefinitions, flagLoadDefinitionsName, "", false, flagLoadDefinitionsUsage)
 
 	reportCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault}, flagSourceUsage)
 	// default value provided so no need to mark flag as required
+
+	// note: no shorthand as -f is already used for the report fields file
+	reportCmd.Flags().StringSliceVar(&definitionFormatFile, flagDefinitionFormatName, nil, flagReportDefinitionFormatUsage)
 }
 
 func doReport(c *cobra.Command, s []string) {
 	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputDirName)
+
+	params, err := parseReportParams(cypherParams)
+	var df *parser.DefinitionFormat
+	if err == nil {
+		df, err = loadDefinitionFormat(c)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorReportFailed)
+		fmt.Println(outputTemplateFailure)
+		os.Exit(exitCodeTemplateCmdFailed)
+	}
 
 	if loadDefinitions {
 		// TODO this is horrible - refactor
 		load(c, s)
 	}
 
-	if err := parser.ParseTemplate(dbURL, username, password, templateFormat, templateName, os.Stdout); err != nil {
+	options := parser.ReportOptions{
+		Templates:     templateFiles,
+		TemplateName:  templateName,
+		OutputDir:     outputDir,
+		PerDefinition: perDefinition,
+		FileName:      reportFileName,
+		Engine:        templateEngine,
+		Params:        params,
+		Format:        df,
+	}
+	if err = parser.GenerateReport(dbURL, username, password, templateFormat, options, os.Stdout); err != nil {
 		fmt.Println(outputTemplateFailure)
 		os.Exit(exitCodeTemplateCmdFailed)
 	}
 }
+
+// parseReportParams parses each report or cypher parameter in the form name=value as a string, as fields are usually
+// compared with strings; name:type=value passes the value as one of string, int, float or bool instead
+func parseReportParams(params []string) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	for _, p := range params {
+		name, value, ok := strings.Cut(p, cypherParamSeparator)
+		name, paramType, _ := strings.Cut(name, reportParamTypeSeparator)
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf(errorInvalidCypherParam, p)
+		}
+
+		var err error
+		switch paramType {
+		case "", "string":
+			m[name] = value
+		case "int":
+			m[name], err = strconv.ParseInt(value, 10, 64)
+		case "float":
+			m[name], err = strconv.ParseFloat(value, 64)
+		case "bool":
+			m[name], err = strconv.ParseBool(value)
+		default:
+			return nil, fmt.Errorf(errorInvalidReportParamType, paramType, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(errorInvalidReportParamValue, paramType, value, name)
+		}
+	}
+	return m, nil
+}
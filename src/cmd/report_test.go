@@ -0,0 +1,41 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseReportParams(t *testing.T) {
+	params, err := parseReportParams([]string{"id=007", "enabled=true", "cores:int=4", "cost:float=4.5",
+		"managed:bool=true", "name:string=12", "expr=a=b"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "007", "enabled": "true", "cores": int64(4), "cost": 4.5,
+		"managed": true, "name": "12", "expr": "a=b"}, params)
+
+	for p, e := range map[string]string{
+		"id":          "parameter [id] must be in the form name=value",
+		":int=4":      "parameter [:int=4] must be in the form name=value",
+		"id:date=1":   "invalid type [date] for parameter [id]; must be one of string, int, float or bool",
+		"cores:int=x": "invalid int value [x] for parameter [cores]",
+	} {
+		_, err = parseReportParams([]string{p})
+		assert.EqualError(t, err, e)
+	}
+}
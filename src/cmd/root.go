@@ -17,8 +17,11 @@
 package cmd
 
 import (
-	"github.com/spf13/cobra"
+	"encoding/csv"
 	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
 )
 
 var (
@@ -37,6 +40,35 @@ func init() {
 	rootCmd.PersistentFlags().Int8VarP(&logLevel, flagLogLevelName, flagLogLevelShorthand, flagLogLevelDefault, flagLogLevelUsage)
 }
 
+// useCommandDefaults resets each flag which was not provided to the default for the command; the flag variables are
+// shared between commands, so otherwise hold the default of whichever command registered the flag last
+func useCommandDefaults(c *cobra.Command, names ...string) {
+	for _, name := range names {
+		f := c.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if slice, ok := f.Value.(interface{ Replace([]string) error }); ok {
+			slice.Replace(sliceDefault(f.DefValue))
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+	}
+}
+
+// sliceDefault parses the default of a slice flag, which pflag renders as a bracketed CSV record such as [a,b]
+func sliceDefault(defValue string) []string {
+	defValue = strings.TrimSuffix(strings.TrimPrefix(defValue, "["), "]")
+	if defValue == "" {
+		return []string{}
+	}
+	values, err := csv.NewReader(strings.NewReader(defValue)).Read()
+	if err != nil {
+		return []string{defValue}
+	}
+	return values
+}
+
 // Execute TODO
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
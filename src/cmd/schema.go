@@ -0,0 +1,132 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputSchemaFailure = "failed to generate schema"
+
+	schemaFileName      = "%s.schema.json"
+	schemaCombinedName  = "specification"
+	schemaIndent        = "  "
+	schemaFilePerm      = 0644
+	schemaDirectoryPerm = 0755
+
+	logErrorSchemaFailed      = "schema failed"
+	logDebugWritingSchemaFile = "writing schema file [%s]"
+)
+
+var (
+	schemaCmd = &cobra.Command{
+		Use:   commandSchemaUse,
+		Short: commandSchemaUseShort,
+		Run:   schema,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand,
+		[]string{flagDefinitionFormatDefault}, flagDefinitionFormatUsage)
+	schemaCmd.Flags().StringVarP(&schemaClass, flagSchemaClassName, flagSchemaClassShorthand, "", flagSchemaClassUsage)
+	schemaCmd.Flags().StringVarP(&outputDir, flagOutputDirName, flagOutputDirShorthand, "", flagSchemaOutputDirUsage)
+}
+
+func marshalSchema(df *parser.DefinitionFormat, class string) ([]byte, error) {
+	s, err := parser.GenerateSchema(df, class)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(s, "", schemaIndent)
+}
+
+// writeSchemas writes the schema for the class, or for all classes if none is provided, to w; if an output directory
+// is provided then a schema for all classes, together with a schema for each class, is written to files instead
+func writeSchemas(df *parser.DefinitionFormat, class, dir string, w io.Writer) error {
+	if dir == "" {
+		b, err := marshalSchema(df, class)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+
+	if err := os.MkdirAll(dir, schemaDirectoryPerm); err != nil {
+		return err
+	}
+
+	classes := []string{""}
+	if class != "" {
+		classes = []string{class}
+	} else {
+		for c, cf := range df.ClassFormat {
+			if cf != nil && !cf.Abstract {
+				classes = append(classes, c)
+			}
+		}
+	}
+
+	for _, c := range classes {
+		b, err := marshalSchema(df, c)
+		if err != nil {
+			return err
+		}
+
+		name := c
+		if name == "" {
+			name = schemaCombinedName
+		}
+		path := filepath.Join(dir, fmt.Sprintf(schemaFileName, name))
+		log.Debug().Msgf(logDebugWritingSchemaFile, path)
+		if err = os.WriteFile(path, append(b, '\n'), schemaFilePerm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func schema(c *cobra.Command, _ []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputDirName, flagDefinitionFormatName)
+
+	df, err := buildDefinitionFormat(definitionFormatFile)
+	if err != nil {
+		fmt.Println(definitionFormatFailure)
+		os.Exit(exitCodeSchemaCmdFailed)
+	}
+
+	if err = writeSchemas(df, schemaClass, outputDir, os.Stdout); err != nil {
+		log.Error().Err(err).Msg(logErrorSchemaFailed)
+		fmt.Println(outputSchemaFailure)
+		os.Exit(exitCodeSchemaCmdFailed)
+	}
+}
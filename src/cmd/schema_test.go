@@ -0,0 +1,54 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeSchemas(t *testing.T) {
+	df, err := buildDefinitionFormat([]string{"_test/validate/format-extends.yml",
+		"_test/validate/format-extends-base.yml"})
+	assert.Nil(t, err)
+
+	t.Run("Stdout", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, writeSchemas(df, "Service", "", &buf))
+		assert.Contains(t, buf.String(), `"const": "Service"`)
+	})
+
+	t.Run("OutputDirectory", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, writeSchemas(df, "", dir, nil))
+
+		for _, name := range []string{"specification", "Service", "Provider"} {
+			_, err := os.Stat(filepath.Join(dir, name+".schema.json"))
+			assert.Nil(t, err)
+		}
+		_, err := os.Stat(filepath.Join(dir, "Named.schema.json"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("UnknownClass", func(t *testing.T) {
+		assert.NotNil(t, writeSchemas(df, "Missing", t.TempDir(), nil))
+	})
+}
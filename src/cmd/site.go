@@ -0,0 +1,77 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/nextmetaphor/yaml-graph/site"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputSiteGenerated = "site for %d class(es) written to [%s]\n"
+	outputSiteFailure   = "failed to generate site"
+
+	logErrorSiteFailed = "site failed"
+)
+
+var (
+	siteCmd = &cobra.Command{
+		Use:   commandSiteUse,
+		Short: commandSiteUseShort,
+		Run:   generateSite,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(siteCmd)
+
+	siteCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagSourceUsage)
+	siteCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+		flagSiteDefinitionFormatUsage)
+	siteCmd.Flags().StringVarP(&outputDir, flagOutputDirName, flagOutputDirShorthand, flagSiteOutputDirDefault,
+		flagSiteOutputDirUsage)
+	siteCmd.Flags().StringVarP(&siteTemplateDir, flagSiteTemplateDirName, flagSiteTemplateDirShorthand, "",
+		flagSiteTemplateDirUsage)
+}
+
+func generateSite(c *cobra.Command, _ []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputDirName)
+
+	d, df, err := loadComputedFields(c)
+	var s *site.Site
+	if err == nil {
+		if d == nil {
+			d = parser.LoadDictionary(sourceDir, fileExtension)
+		}
+		s = site.Build(d, df)
+		err = site.Generate(s, outputDir, siteTemplateDir)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorSiteFailed)
+		fmt.Println(outputSiteFailure)
+		os.Exit(exitCodeSiteCmdFailed)
+	}
+	fmt.Printf(outputSiteGenerated, len(s.Classes), outputDir)
+}
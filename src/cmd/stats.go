@@ -0,0 +1,166 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	statsTextSummary        = "definitions: %d\nrelationships: %d (%d to missing definitions)\n"
+	statsTextSection        = "\n%s\n"
+	statsClassesSection     = "CLASSES"
+	statsClassesHeader      = "CLASS\tDEFINITIONS\n"
+	statsClassesRow         = "%s\t%d\n"
+	statsFieldsSection      = "FIELD FILL RATES"
+	statsFieldsHeader       = "CLASS\tFIELD\tKIND\tPOPULATED\tFILL RATE\n"
+	statsFieldsRow          = "%s\t%s\t%s\t%d/%d\t%.0f%%\n"
+	statsRelationshipsTitle = "RELATIONSHIPS"
+	statsRelationshipsHdr   = "RELATIONSHIP\tFROM\tTO\tCOUNT\n"
+	statsRelationshipsRow   = "%s\t%s\t%s\t%d\n"
+	statsDegreeSection      = "DEGREE DISTRIBUTION"
+	statsDegreeHeader       = "DEGREE\tDEFINITIONS\n"
+	statsDegreeRow          = "%d\t%d\n"
+	statsConnectedSection   = "MOST CONNECTED"
+	statsConnectedHeader    = "CLASS\tID\tDEGREE\tINCOMING\tOUTGOING\n"
+	statsConnectedRow       = "%s\t%s\t%d\t%d\t%d\n"
+	statsLargestSection     = "LARGEST"
+	statsLargestHeader      = "CLASS\tID\tFIELDS\tSIZE\n"
+	statsLargestRow         = "%s\t%s\t%d\t%d\n"
+
+	outputStatsFailure = "failed to generate statistics"
+
+	logErrorStatsFailed = "stats failed"
+
+	errorInvalidStatsOutput = "invalid output format [%s]; must be one of text or json"
+)
+
+var (
+	statsCmd = &cobra.Command{
+		Use:   commandStatsUse,
+		Short: commandStatsUseShort,
+		Run:   stats,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringSliceVarP(&sourceDir, flagSourceName, flagSourceShorthand, []string{flagSourceDefault},
+		flagSourceUsage)
+	statsCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand, nil,
+		flagStatsDefinitionFormatUsage)
+	statsCmd.Flags().IntVar(&top, flagTopName, flagTopDefault, flagTopUsage)
+	statsCmd.Flags().StringVar(&outputFormat, flagOutputFormatName, outputFormatText, flagStatsOutputFormatUsage)
+}
+
+// writeStatsSection writes a titled table, calling row for each row
+func writeStatsSection(w io.Writer, title, header string, rows int, row func(w io.Writer, i int)) error {
+	if rows == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, statsTextSection, title)
+	tw := newTableWriter(w)
+	fmt.Fprint(tw, header)
+	for i := 0; i < rows; i++ {
+		row(tw, i)
+	}
+	return tw.Flush()
+}
+
+func writeTextStats(s *parser.Statistics, w io.Writer) error {
+	fmt.Fprintf(w, statsTextSummary, s.Definitions, s.TotalRelationships, s.BrokenRelationships)
+
+	type fieldRow struct {
+		class       string
+		definitions int
+		parser.FieldStatistics
+	}
+	var fields []fieldRow
+	for _, cs := range s.Classes {
+		for _, fs := range cs.Fields {
+			fields = append(fields, fieldRow{class: cs.Class, definitions: cs.Definitions, FieldStatistics: fs})
+		}
+	}
+
+	for _, err := range []error{
+		writeStatsSection(w, statsClassesSection, statsClassesHeader, len(s.Classes), func(w io.Writer, i int) {
+			fmt.Fprintf(w, statsClassesRow, s.Classes[i].Class, s.Classes[i].Definitions)
+		}),
+		writeStatsSection(w, statsFieldsSection, statsFieldsHeader, len(fields), func(w io.Writer, i int) {
+			f := fields[i]
+			fmt.Fprintf(w, statsFieldsRow, f.class, f.Field, f.Kind, f.Populated, f.definitions, f.FillRate*100)
+		}),
+		writeStatsSection(w, statsRelationshipsTitle, statsRelationshipsHdr, len(s.Relationships),
+			func(w io.Writer, i int) {
+				r := s.Relationships[i]
+				fmt.Fprintf(w, statsRelationshipsRow, r.Relationship, r.FromClass, r.ToClass, r.Count)
+			}),
+		writeStatsSection(w, statsDegreeSection, statsDegreeHeader, len(s.DegreeDistribution), func(w io.Writer, i int) {
+			fmt.Fprintf(w, statsDegreeRow, s.DegreeDistribution[i].Degree, s.DegreeDistribution[i].Definitions)
+		}),
+		writeStatsSection(w, statsConnectedSection, statsConnectedHeader, len(s.MostConnected), func(w io.Writer, i int) {
+			ds := s.MostConnected[i]
+			fmt.Fprintf(w, statsConnectedRow, ds.Class, ds.ID, ds.Degree, ds.Incoming, ds.Outgoing)
+		}),
+		writeStatsSection(w, statsLargestSection, statsLargestHeader, len(s.Largest), func(w io.Writer, i int) {
+			ds := s.Largest[i]
+			fmt.Fprintf(w, statsLargestRow, ds.Class, ds.ID, ds.Fields, ds.Size)
+		}),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStats writes the statistics in the output format
+func writeStats(s *parser.Statistics, format string, w io.Writer) error {
+	switch format {
+	case outputFormatText:
+		return writeTextStats(s, w)
+	case outputFormatJSON:
+		return writeJSON(s, w)
+	}
+	return fmt.Errorf(errorInvalidStatsOutput, format)
+}
+
+func stats(c *cobra.Command, _ []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagOutputFormatName)
+
+	d, df, err := loadComputedFields(c)
+	if err == nil {
+		if d == nil {
+			d = parser.LoadDictionary(sourceDir, fileExtension)
+		}
+		err = writeStats(parser.Stats(d, df, top), outputFormat, os.Stdout)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorStatsFailed)
+		fmt.Println(outputStatsFailure)
+		os.Exit(exitCodeStatsCmdFailed)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_writeStats(t *testing.T) {
+	s := parser.Stats(parser.LoadDictionary([]string{"_test/diff/before"}, "yaml"), nil, 1)
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeStats(s, "text", &buf))
+	assert.Equal(t, `definitions: 3
+relationships: 1 (0 to missing definitions)
+
+CLASSES
+CLASS     DEFINITIONS
+Provider  2
+Service   1
+
+FIELD FILL RATES
+CLASS     FIELD        KIND        POPULATED  FILL RATE
+Provider  Name         undeclared  2/2        100%
+Service   Description  undeclared  1/1        100%
+Service   Name         undeclared  1/1        100%
+
+RELATIONSHIPS
+RELATIONSHIP  FROM     TO        COUNT
+HOSTED_BY     Service  Provider  1
+
+DEGREE DISTRIBUTION
+DEGREE  DEFINITIONS
+0       1
+1       2
+
+MOST CONNECTED
+CLASS     ID     DEGREE  INCOMING  OUTGOING
+Provider  azure  1       1         0
+
+LARGEST
+CLASS    ID  FIELDS  SIZE
+Service  vm  2       27
+`, buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeStats(s, "json", &buf))
+	assert.Contains(t, buf.String(), `"Definitions": 3`)
+
+	assert.NotNil(t, writeStats(s, "table", &buf))
+}
@@ -57,6 +57,7 @@ func init() {
 
 	validateCmd.Flags().StringSliceVarP(&definitionFormatFile, flagDefinitionFormatName, flagDefinitionFormatShorthand,
 		[]string{flagDefinitionFormatDefault}, flagDefinitionFormatUsage)
+	validateCmd.Flags().IntVar(&maxWarnings, flagMaxWarningsName, flagMaxWarningsDefault, flagMaxWarningsUsage)
 	if err := validateCmd.MarkFlagRequired(flagDefinitionFormatName); err != nil {
 		log.Error().Err(err).Msg(logErrorValidateFailed)
 		os.Exit(exitCodeValidateCmdFailed)
@@ -75,6 +76,23 @@ func mergeDefinitionFormat(current, new *parser.DefinitionFormat) (err error) {
 		current.ClassFormat[dfnClass] = dfnValue
 	}
 
+	if new.GraphChecks != nil {
+		if current.GraphChecks != nil {
+			return errors.New("graph checks have already been declared")
+		}
+		current.GraphChecks = new.GraphChecks
+	}
+
+	for ruleID, severity := range new.Severities {
+		if existing, ok := current.Severities[ruleID]; ok && existing != severity {
+			return errors.New("severity for rule " + ruleID + " has already been declared")
+		}
+		if current.Severities == nil {
+			current.Severities = map[string]parser.Severity{}
+		}
+		current.Severities[ruleID] = severity
+	}
+
 	return nil
 }
 
@@ -97,31 +115,48 @@ func loadDefinitionFormatConf(cfgPath string) (definitionFormat *parser.Definiti
 	return definitionFormat, nil
 }
 
-func validate(_ *cobra.Command, _ []string) {
-	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
-
+// buildDefinitionFormat loads and merges each of the definition format files provided
+func buildDefinitionFormat(dfnFiles []string) (*parser.DefinitionFormat, error) {
 	overallDefinitionFormat := parser.DefinitionFormat{
 		ClassFormat: map[string]*parser.ClassDefinitionFormat{},
 	}
-	for _, dfnFile := range definitionFormatFile {
+	for _, dfnFile := range dfnFiles {
 		if dfnFile != "" {
 			definitionFormat, err := loadDefinitionFormatConf(dfnFile)
 			if err != nil {
-				fmt.Println(outputValidationFailure)
-				os.Exit(exitCodeValidateCmdFailed)
+				return nil, err
 			}
 
 			err = mergeDefinitionFormat(&overallDefinitionFormat, definitionFormat)
 			if err != nil {
 				log.Error().Err(err).Msgf(logErrorCouldNotBuildDefinitionFormat)
-				fmt.Println(definitionFormatFailure)
-				os.Exit(exitCodeValidateCmdFailed)
+				return nil, err
 			}
 		}
 	}
 
+	// inheritance is resolved once all formats are merged, so classes can extend classes declared in other files
+	if err := overallDefinitionFormat.ResolveInheritance(); err != nil {
+		log.Error().Err(err).Msgf(logErrorCouldNotBuildDefinitionFormat)
+		return nil, err
+	}
+
+	return &overallDefinitionFormat, nil
+}
+
+func validate(c *cobra.Command, _ []string) {
+	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
+	useCommandDefaults(c, flagDefinitionFormatName)
+
+	overallDefinitionFormat, err := buildDefinitionFormat(definitionFormatFile)
+	if err != nil {
+		fmt.Println(definitionFormatFailure)
+		os.Exit(exitCodeValidateCmdFailed)
+	}
+
 	d := parser.LoadDictionary(sourceDir, fileExtension)
-	if parser.ValidateDictionary(d, &overallDefinitionFormat) != nil {
+	computeErr := parser.ComputeFields(d, overallDefinitionFormat)
+	if parser.Validate(d, overallDefinitionFormat).Err(maxWarnings) != nil || computeErr != nil {
 		fmt.Println(outputValidationFailure)
 		os.Exit(exitCodeValidateCmdFailed)
 	} else {
@@ -115,6 +115,55 @@ func Test_mergeDefinitionFormat(t *testing.T) {
 	})
 }
 
+func Test_mergeDefinitionFormatGraphChecks(t *testing.T) {
+	graphChecks := &parser.GraphChecks{Orphans: &parser.GraphCheck{Severity: parser.SeverityWarning}}
+
+	t.Run("NewGraphChecks", func(t *testing.T) {
+		currentFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{}}
+		newFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			GraphChecks: graphChecks}
+
+		assert.Nil(t, mergeDefinitionFormat(&currentFormat, &newFormat))
+		assert.Equal(t, graphChecks, currentFormat.GraphChecks)
+	})
+
+	t.Run("ClashingGraphChecks", func(t *testing.T) {
+		currentFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			GraphChecks: graphChecks}
+		newFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			GraphChecks: graphChecks}
+
+		assert.NotNil(t, mergeDefinitionFormat(&currentFormat, &newFormat))
+	})
+}
+
+func Test_mergeDefinitionFormatSeverities(t *testing.T) {
+	t.Run("NewSeverities", func(t *testing.T) {
+		currentFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			Severities: map[string]parser.Severity{parser.RuleOrphan: parser.SeverityWarning}}
+		newFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			Severities: map[string]parser.Severity{
+				parser.RuleOrphan:          parser.SeverityWarning,
+				parser.RuleAdditionalField: parser.SeverityOff,
+			}}
+
+		assert.Nil(t, mergeDefinitionFormat(&currentFormat, &newFormat))
+		assert.Equal(t, map[string]parser.Severity{
+			parser.RuleOrphan:          parser.SeverityWarning,
+			parser.RuleAdditionalField: parser.SeverityOff,
+		}, currentFormat.Severities)
+	})
+
+	t.Run("ClashingSeverities", func(t *testing.T) {
+		currentFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			Severities: map[string]parser.Severity{parser.RuleOrphan: parser.SeverityWarning}}
+		newFormat := parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{},
+			Severities: map[string]parser.Severity{parser.RuleOrphan: parser.SeverityError}}
+
+		assert.NotNil(t, mergeDefinitionFormat(&currentFormat, &newFormat))
+	})
+}
+
 func Test_loadDefinitionFormatConf(t *testing.T) {
 	t.Run("Invalid", func(t *testing.T) {
 		_, err := loadDefinitionFormatConf("_test/validate/format-invalid.yml")
@@ -207,4 +256,52 @@ func Test_loadDefinitionFormatConf(t *testing.T) {
 		}}, fmt)
 	})
 
+	t.Run("Rules", func(t *testing.T) {
+		fmt, err := loadDefinitionFormatConf("_test/validate/format-rules.yml")
+
+		assert.Nil(t, err)
+		assert.Equal(t, &parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{
+			"Service": {
+				MandatoryFields: map[string]parser.ClassField{
+					"Name": {Description: ""},
+				},
+				OptionalFields: map[string]parser.ClassField{
+					"Tenancy": {Description: ""},
+					"Link":    {Description: ""},
+				},
+				Rules: []parser.Rule{
+					{
+						ID:         "public-link",
+						Expression: `Tenancy != "Public" || has(Link)`,
+						Message:    "public services must have a link",
+					},
+					{
+						ID:         "unique-name",
+						Expression: `definitions(Class).filter(d, d.Name == Name).size() == 1`,
+						Severity:   parser.SeverityWarning,
+					},
+				},
+			},
+		}}, fmt)
+	})
+}
+
+func Test_buildDefinitionFormat(t *testing.T) {
+	t.Run("Extends", func(t *testing.T) {
+		df, err := buildDefinitionFormat([]string{"_test/validate/format-extends.yml",
+			"_test/validate/format-extends-base.yml"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]parser.ClassField{"Name": {}, "Category": {}},
+			df.ClassFormat["Service"].MandatoryFields)
+		assert.Equal(t, map[string]parser.ClassField{"Name": {}}, df.ClassFormat["Provider"].MandatoryFields)
+		assert.Equal(t, map[string]parser.ClassField{"Description": {}}, df.ClassFormat["Provider"].OptionalFields)
+		assert.Equal(t, []parser.Rule{{ID: "name-not-id", Expression: "Name != ID"}}, df.ClassFormat["Service"].Rules)
+	})
+
+	t.Run("UnknownBaseClass", func(t *testing.T) {
+		_, err := buildDefinitionFormat([]string{"_test/validate/format-extends.yml"})
+
+		assert.NotNil(t, err)
+	})
 }
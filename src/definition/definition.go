@@ -71,6 +71,12 @@ type (
 		Path     string `yaml:"Path"`
 		Prefix   string `yaml:"Prefix"`
 		Encoding string `yaml:"Encoding"`
+
+		// RenderHTML indicates whether the body of a markdown file should be rendered as HTML
+		RenderHTML bool `yaml:"RenderHTML,omitempty"`
+
+		// TableOfContentsField names the field into which the headings of a markdown file should be extracted
+		TableOfContentsField string `yaml:"TableOfContentsField,omitempty"`
 	}
 
 	// FileFields TODO
@@ -82,6 +88,10 @@ type (
 		FileFields     FileFields               `yaml:"FileFields"`
 		References     []Reference              `yaml:"References"`
 		SubDefinitions map[string]Specification `yaml:"SubDefinitions"`
+
+		// Suppressions holds the validation rule IDs ignored for this definition, taken from any
+		// "# yaml-graph:ignore" comments in the definition file
+		Suppressions []string `yaml:"-"`
 	}
 
 	// Specification TODO
@@ -130,13 +140,18 @@ func getFileFields(path string, dfn *Definition) {
 	if dfn.SubDefinitions != nil {
 		for _, spec := range dfn.SubDefinitions {
 			if spec.Definitions != nil {
-				for _, subDef := range spec.Definitions {
+				for subDefID, subDef := range spec.Definitions {
 					getFileFields(path, &subDef)
+					spec.Definitions[subDefID] = subDef
 				}
 			}
 		}
 	}
 
+	if len(dfn.FileFields) > 0 && dfn.Fields == nil {
+		dfn.Fields = Fields{}
+	}
+
 	for fieldName, fileDefn := range dfn.FileFields {
 		log.Debug().Err(nil).Msg(fieldName)
 		log.Debug().Err(nil).Msg(fileDefn.Prefix)
@@ -146,13 +161,18 @@ func getFileFields(path string, dfn *Definition) {
 		var err error
 
 		switch fileDefn.Encoding {
+		case encodingMarkdown:
+			if err = getFileFieldAsMarkdown(path, fieldName, fileDefn, dfn); err != nil {
+				log.Debug().Err(err).Msg(fmt.Sprintf(logErrorCannotEncodeFile, fileDefn.Path))
+			}
+			continue
 		case encodingBase64:
 			b64Str, err = getFileFieldAsBase64(path, fileDefn)
 		default:
 			b64Str, err = getFileFieldAsString(path, fileDefn)
 		}
 		if err != nil {
-			log.Debug().Err(err).Msg(fmt.Sprintf(logErrorCannotEncodeFile, fileDefn))
+			log.Debug().Err(err).Msg(fmt.Sprintf(logErrorCannotEncodeFile, fileDefn.Path))
 		} else {
 			dfn.Fields[fieldName] = *b64Str
 		}
@@ -167,8 +187,13 @@ func LoadSpecificationFromFile(filename string) (*Specification, error) {
 		return nil, err
 	}
 
+	return LoadSpecification(filename, yamlFile)
+}
+
+// LoadSpecification parses the contents of a definition file; the filename is used to locate any FileFields
+func LoadSpecification(filename string, yamlFile []byte) (*Specification, error) {
 	spec := &Specification{}
-	err = yaml.Unmarshal(yamlFile, spec)
+	err := yaml.Unmarshal(yamlFile, spec)
 	if err != nil {
 		log.Debug().Err(err).Msg(fmt.Sprintf(logDebugCannotParseYAMLFile, filename))
 
@@ -181,9 +206,16 @@ func LoadSpecificationFromFile(filename string) (*Specification, error) {
 		return nil, fmt.Errorf(logDebugNoDefinitionsFoundInYAMLFile, filename)
 	}
 
+	// record any validation rules which have been suppressed using comments
+	if err = loadSuppressions(spec, yamlFile); err != nil {
+		log.Debug().Err(err).Msg(fmt.Sprintf(logDebugCannotParseYAMLFile, filename))
+		return nil, err
+	}
+
 	// load any files into the definition that are explicitly referenced in FileFields
-	for _, d := range spec.Definitions {
+	for dfnID, d := range spec.Definitions {
 		getFileFields(filepath.Dir(filename), &d)
+		spec.Definitions[dfnID] = d
 	}
 
 	// TODO debug
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package definition
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	encodingMarkdown = "markdown"
+
+	frontMatterDelimiter = "---"
+	tocEntry             = "%s- [%s](#%s)\n"
+	tocIndent            = "  "
+
+	logDebugIgnoringFrontMatterField = "ignoring front-matter field [%s] in file [%s] as it is already defined"
+)
+
+type (
+	// markdownFile holds the constituent parts of a markdown file once parsed
+	markdownFile struct {
+		// FrontMatter contains any YAML key/value pairs found between the leading "---" delimiters
+		FrontMatter Fields
+
+		// Body contains the markdown (or HTML, if rendered) content following any front-matter
+		Body string
+
+		// TableOfContents is a markdown list of the headings within the body, linking to each heading ID
+		TableOfContents string
+	}
+)
+
+// splitFrontMatter separates any YAML front-matter from the remainder of the markdown content
+func splitFrontMatter(content []byte) (frontMatter Fields, body []byte, err error) {
+	normalised := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalised, []byte(frontMatterDelimiter+"\n")) {
+		return nil, content, nil
+	}
+
+	remainder := normalised[len(frontMatterDelimiter)+1:]
+	var yamlContent []byte
+	if bytes.HasPrefix(remainder, []byte(frontMatterDelimiter)) {
+		// empty front-matter
+		remainder = remainder[len(frontMatterDelimiter):]
+	} else {
+		end := bytes.Index(remainder, []byte("\n"+frontMatterDelimiter))
+		if end < 0 {
+			// no closing delimiter, so treat the whole file as markdown
+			return nil, content, nil
+		}
+		yamlContent = remainder[:end]
+		remainder = remainder[end+len(frontMatterDelimiter)+1:]
+	}
+
+	if err = yaml.Unmarshal(yamlContent, &frontMatter); err != nil {
+		return nil, nil, err
+	}
+
+	// skip the remainder of the closing delimiter line
+	if i := bytes.IndexByte(remainder, '\n'); i >= 0 {
+		remainder = remainder[i+1:]
+	} else {
+		remainder = nil
+	}
+
+	return frontMatter, remainder, nil
+}
+
+// getTableOfContents walks the headings in the document to build a nested markdown list
+func getTableOfContents(doc ast.Node, source []byte) string {
+	var headings []*ast.Heading
+	minLevel := 0
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := n.(*ast.Heading); ok && entering {
+			headings = append(headings, heading)
+			if minLevel == 0 || heading.Level < minLevel {
+				minLevel = heading.Level
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var toc strings.Builder
+	for _, heading := range headings {
+		id := ""
+		if attr, ok := heading.AttributeString("id"); ok {
+			if b, ok := attr.([]byte); ok {
+				id = string(b)
+			}
+		}
+		toc.WriteString(fmt.Sprintf(tocEntry, strings.Repeat(tocIndent, heading.Level-minLevel),
+			string(heading.Text(source)), id))
+	}
+
+	return toc.String()
+}
+
+// parseMarkdown splits the content into front-matter and body, optionally rendering the body as HTML
+func parseMarkdown(content []byte, renderHTML bool) (*markdownFile, error) {
+	frontMatter, body, err := splitFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+
+	md := goldmark.New(goldmark.WithParserOptions(parser.WithAutoHeadingID()))
+	doc := md.Parser().Parse(text.NewReader(body))
+
+	mdFile := &markdownFile{
+		FrontMatter:     frontMatter,
+		Body:            string(body),
+		TableOfContents: getTableOfContents(doc, body),
+	}
+
+	if renderHTML {
+		var buf bytes.Buffer
+		if err = md.Renderer().Render(&buf, body, doc); err != nil {
+			return nil, err
+		}
+		mdFile.Body = buf.String()
+	}
+
+	return mdFile, nil
+}
+
+// getFileFieldAsMarkdown reads a markdown file and sets the field, any front-matter fields and the optional table of
+// contents field on the definition; fields already present on the definition are not overwritten by front-matter
+func getFileFieldAsMarkdown(path, fieldName string, fileDefn FileDefinition, dfn *Definition) error {
+	filePath := path + string(filepath.Separator) + fileDefn.Path
+	dat, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	mdFile, err := parseMarkdown(dat, fileDefn.RenderHTML)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range mdFile.FrontMatter {
+		if _, exists := dfn.Fields[key]; exists {
+			log.Debug().Msgf(logDebugIgnoringFrontMatterField, key, filePath)
+			continue
+		}
+		dfn.Fields[key] = value
+	}
+
+	dfn.Fields[fieldName] = fileDefn.Prefix + mdFile.Body
+	if fileDefn.TableOfContentsField != "" {
+		dfn.Fields[fileDefn.TableOfContentsField] = mdFile.TableOfContents
+	}
+
+	return nil
+}
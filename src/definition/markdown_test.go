@@ -0,0 +1,101 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package definition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitFrontMatter(t *testing.T) {
+	t.Run("NoFrontMatter", func(t *testing.T) {
+		fm, body, err := splitFrontMatter([]byte("# Heading\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, fm)
+		assert.Equal(t, "# Heading\n", string(body))
+	})
+
+	t.Run("FrontMatter", func(t *testing.T) {
+		fm, body, err := splitFrontMatter([]byte("---\nName: value\nCount: 3\n---\n# Heading\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, Fields{"Name": "value", "Count": 3}, fm)
+		assert.Equal(t, "# Heading\n", string(body))
+	})
+
+	t.Run("EmptyFrontMatter", func(t *testing.T) {
+		fm, body, err := splitFrontMatter([]byte("---\n---\nbody"))
+		assert.Nil(t, err)
+		assert.Nil(t, fm)
+		assert.Equal(t, "body", string(body))
+	})
+
+	t.Run("UnterminatedFrontMatter", func(t *testing.T) {
+		fm, body, err := splitFrontMatter([]byte("---\nName: value\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, fm)
+		assert.Equal(t, "---\nName: value\n", string(body))
+	})
+
+	t.Run("InvalidFrontMatter", func(t *testing.T) {
+		_, _, err := splitFrontMatter([]byte("---\n[invalid\n---\nbody"))
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_parseMarkdown(t *testing.T) {
+	content := []byte("# Overview\n\ntext\n\n## Detail\n\n### Further Detail\n\n## Summary\n")
+
+	t.Run("Markdown", func(t *testing.T) {
+		md, err := parseMarkdown(content, false)
+		assert.Nil(t, err)
+		assert.Equal(t, string(content), md.Body)
+		assert.Equal(t, "- [Overview](#overview)\n  - [Detail](#detail)\n    - [Further Detail](#further-detail)\n"+
+			"  - [Summary](#summary)\n", md.TableOfContents)
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		md, err := parseMarkdown(content, true)
+		assert.Nil(t, err)
+		assert.Equal(t, "<h1 id=\"overview\">Overview</h1>\n<p>text</p>\n<h2 id=\"detail\">Detail</h2>\n"+
+			"<h3 id=\"further-detail\">Further Detail</h3>\n<h2 id=\"summary\">Summary</h2>\n", md.Body)
+	})
+}
+
+func Test_getFileFieldsMarkdown(t *testing.T) {
+	spec, err := LoadSpecificationFromFile("./_test/Markdown/definitions.yaml")
+	assert.Nil(t, err)
+
+	t.Run("FrontMatterAndTableOfContents", func(t *testing.T) {
+		assert.Equal(t, Fields{
+			"Name":    "Definition1_Name",
+			"Author":  "Jane Doe",
+			"Version": 2,
+			"Description": "<h1 id=\"overview\">Overview</h1>\n<p>Some <strong>bold</strong> text.</p>\n" +
+				"<h2 id=\"detail\">Detail</h2>\n<p>More text.</p>\n<h3 id=\"further-detail\">Further Detail</h3>\n" +
+				"<h2 id=\"summary\">Summary</h2>\n",
+			"Contents": "- [Overview](#overview)\n  - [Detail](#detail)\n    - [Further Detail](#further-detail)\n" +
+				"  - [Summary](#summary)\n",
+		}, spec.Definitions["Definition1_ID"].Fields)
+	})
+
+	t.Run("NoFields", func(t *testing.T) {
+		assert.Equal(t, Fields{
+			"Description": "# Heading\n\nBody text.\n",
+		}, spec.Definitions["Definition2_ID"].Fields)
+	})
+}
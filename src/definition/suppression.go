@@ -0,0 +1,142 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package definition
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SuppressAll is recorded when a suppression comment does not name any rules
+	SuppressAll = "*"
+
+	suppressionPrefix = "yaml-graph:ignore"
+
+	keyDefinitions    = "Definitions"
+	keySubDefinitions = "SubDefinitions"
+)
+
+// parseSuppressions extracts the rule IDs from any "# yaml-graph:ignore rule-1, rule-2" lines within the comments
+func parseSuppressions(comments ...string) (suppressions []string) {
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+			if !strings.HasPrefix(line, suppressionPrefix) {
+				continue
+			}
+
+			rules := strings.FieldsFunc(strings.TrimPrefix(line, suppressionPrefix), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			})
+			if len(rules) == 0 {
+				rules = []string{SuppressAll}
+			}
+			suppressions = append(suppressions, rules...)
+		}
+	}
+	return suppressions
+}
+
+// nodeSuppressions collects the suppressions from the comments of a node and its children, stopping at any
+// SubDefinitions as these are handled separately
+func nodeSuppressions(n *yaml.Node) (suppressions []string) {
+	if n == nil {
+		return nil
+	}
+	suppressions = parseSuppressions(n.HeadComment, n.LineComment, n.FootComment)
+
+	for i := 0; i < len(n.Content); i++ {
+		if n.Kind == yaml.MappingNode && i%2 == 0 && n.Content[i].Value == keySubDefinitions {
+			suppressions = append(suppressions, parseSuppressions(n.Content[i].HeadComment,
+				n.Content[i].LineComment)...)
+			i++
+			continue
+		}
+		suppressions = append(suppressions, nodeSuppressions(n.Content[i])...)
+	}
+	return suppressions
+}
+
+// mappingValue returns the value node for the key within a mapping node
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// applySuppressions sets the Suppressions of each definition within the specification (and its sub-definitions)
+// from the comments in the corresponding specification node, together with any inherited suppressions
+func applySuppressions(spec *Specification, specNode *yaml.Node, inherited []string) {
+	definitionsNode := mappingValue(specNode, keyDefinitions)
+	if definitionsNode == nil || definitionsNode.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(definitionsNode.Content); i += 2 {
+		idNode, dfnNode := definitionsNode.Content[i], definitionsNode.Content[i+1]
+		dfn, ok := spec.Definitions[idNode.Value]
+		if !ok {
+			continue
+		}
+
+		suppressions := append(append([]string{}, inherited...), parseSuppressions(idNode.HeadComment,
+			idNode.LineComment, idNode.FootComment)...)
+		suppressions = append(suppressions, nodeSuppressions(dfnNode)...)
+		if len(suppressions) > 0 {
+			dfn.Suppressions = suppressions
+		}
+
+		subDefinitionsNode := mappingValue(dfnNode, keySubDefinitions)
+		for relationship, subSpec := range dfn.SubDefinitions {
+			applySuppressions(&subSpec, mappingValue(subDefinitionsNode, relationship), inherited)
+			dfn.SubDefinitions[relationship] = subSpec
+		}
+
+		spec.Definitions[idNode.Value] = dfn
+	}
+}
+
+// loadSuppressions parses the comments in the YAML file to find any suppressions; comments at the start of the file
+// or on its top-level keys apply to all of the definitions in the file
+func loadSuppressions(spec *Specification, yamlFile []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(yamlFile, &doc); err != nil {
+		return err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	fileSuppressions := parseSuppressions(doc.HeadComment, root.HeadComment)
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i < len(root.Content); i += 2 {
+			fileSuppressions = append(fileSuppressions, parseSuppressions(root.Content[i].HeadComment)...)
+		}
+	}
+
+	applySuppressions(spec, root, fileSuppressions)
+	return nil
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package definition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseSuppressions(t *testing.T) {
+	assert.Nil(t, parseSuppressions("", "# a comment"))
+	assert.Equal(t, []string{"a", "b", "c", SuppressAll},
+		parseSuppressions("# yaml-graph:ignore a, b\n# other", "#yaml-graph:ignore c", "# yaml-graph:ignore"))
+}
+
+func Test_loadSuppressions(t *testing.T) {
+	spec, err := LoadSpecificationFromFile("./_test/Suppression/suppressed.yaml")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"orphan", "additional-field", "unique-name", "mandatory-field-missing"},
+		spec.Definitions["Definition1_ID"].Suppressions)
+	assert.Equal(t, []string{"orphan", SuppressAll}, spec.Definitions["Definition2_ID"].Suppressions)
+
+	children := spec.Definitions["Definition2_ID"].SubDefinitions["CHILD_OF"].Definitions
+	assert.Equal(t, []string{"orphan", "cycle"}, children["Child1_ID"].Suppressions)
+	assert.Equal(t, []string{"orphan"}, children["Child2_ID"].Suppressions)
+}
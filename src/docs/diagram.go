@@ -0,0 +1,115 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package docs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	diagramHeader       = "classDiagram\n"
+	diagramClassOpen    = "  class %s {\n"
+	diagramClassClose   = "  }\n"
+	diagramClass        = "  class %s\n"
+	diagramAbstract     = "    <<abstract>>\n"
+	diagramField        = "    +%s %s\n"
+	diagramExtends      = "  %s <|-- %s\n"
+	diagramRelationship = "  %s --> %s : %s\n"
+)
+
+// diagramType returns a single type for a field, as Mermaid cannot show alternatives
+func diagramType(f Field) string {
+	if len(f.Types) == 1 {
+		return f.Types[0]
+	}
+	return typeAny
+}
+
+// classDiagram returns a Mermaid class diagram. If focus is nil then every class is included, without fields;
+// otherwise the focused classes are shown with their fields together with every class they are related to.
+func classDiagram(doc *Documentation, focus map[string]bool) string {
+	classes := map[string]*Class{}
+	for _, c := range doc.Classes {
+		classes[c.Name] = c
+	}
+
+	included := map[string]bool{}
+	var lines []string
+	addRelationship := func(from, to, name string) {
+		lines = append(lines, fmt.Sprintf(diagramRelationship, from, to, name))
+		included[from], included[to] = true, true
+	}
+
+	for _, c := range doc.Classes {
+		if focus != nil && !focus[c.Name] {
+			continue
+		}
+		included[c.Name] = true
+		if c.Extends != "" {
+			lines = append(lines, fmt.Sprintf(diagramExtends, c.Extends, c.Name))
+			included[c.Extends] = true
+		}
+		for _, r := range c.Outgoing {
+			addRelationship(c.Name, r.Class, r.Name)
+		}
+		if focus == nil {
+			continue
+		}
+		for _, sub := range c.SubClasses {
+			if classes[sub] != nil && classes[sub].Extends == c.Name {
+				lines = append(lines, fmt.Sprintf(diagramExtends, c.Name, sub))
+				included[sub] = true
+			}
+		}
+		for _, r := range c.Incoming {
+			if !focus[r.Class] {
+				addRelationship(r.Class, c.Name, r.Name)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(included))
+	for name := range included {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(diagramHeader)
+	for _, name := range names {
+		c := classes[name]
+		if focus == nil || !focus[name] || c == nil || (len(c.Fields) == 0 && !c.Abstract) {
+			sb.WriteString(fmt.Sprintf(diagramClass, name))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(diagramClassOpen, name))
+		if c.Abstract {
+			sb.WriteString(diagramAbstract)
+		}
+		for _, f := range c.Fields {
+			sb.WriteString(fmt.Sprintf(diagramField, diagramType(f), f.Name))
+		}
+		sb.WriteString(diagramClassClose)
+	}
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package docs generates reference documentation for the classes of a definition format, illustrated with
+// definitions from a Dictionary.
+package docs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kindMandatory = "mandatory"
+	kindOptional  = "optional"
+	kindComputed  = "computed"
+
+	typeString  = "string"
+	typeInteger = "integer"
+	typeNumber  = "number"
+	typeBoolean = "boolean"
+	typeList    = "list"
+	typeMap     = "map"
+	typeAny     = "any"
+
+	exampleValueMaxLength = 200
+	exampleTruncated      = "..."
+)
+
+type (
+	// Field documents a single field of a class
+	Field struct {
+		Name        string
+		Kind        string
+		Description string
+
+		// Types holds the types of the values found in the definitions; mandatory fields are always strings
+		Types []string
+
+		// Expression is set for computed fields
+		Expression string
+
+		// Inherited names the base class the field is declared in, if any
+		Inherited string
+	}
+
+	// Relationship documents the relationships of a type between a class and another class, found in the definitions
+	Relationship struct {
+		Name  string
+		Class string
+		Count int
+	}
+
+	// Example is a definition of the class, as YAML
+	Example struct {
+		ID   string
+		YAML string
+	}
+
+	// Class documents a single class of the definition format
+	Class struct {
+		Name        string
+		Description string
+		Abstract    bool
+		Extends     string
+		SubClasses  []string
+		Definitions int
+
+		Fields   []Field
+		Rules    []parser.Rule
+		Outgoing []Relationship
+		Incoming []Relationship
+		Examples []Example
+
+		// Diagram is a Mermaid class diagram of the class and the classes it is related to
+		Diagram string
+	}
+
+	// Documentation holds every class of the definition format
+	Documentation struct {
+		Classes []*Class
+
+		// Diagram is a Mermaid class diagram of every class
+		Diagram string
+	}
+
+	// exampleDefinition omits empty values so that examples only show what is set
+	exampleDefinition struct {
+		Fields     definition.Fields  `yaml:"Fields,omitempty"`
+		References []exampleReference `yaml:"References,omitempty"`
+	}
+
+	exampleReference struct {
+		Class            string            `yaml:"Class"`
+		ID               string            `yaml:"ID"`
+		Relationship     string            `yaml:"Relationship,omitempty"`
+		RelationshipFrom bool              `yaml:"RelationshipFrom,omitempty"`
+		RelationshipTo   bool              `yaml:"RelationshipTo,omitempty"`
+		Fields           definition.Fields `yaml:"Fields,omitempty"`
+	}
+)
+
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return typeString
+	case int, int64:
+		return typeInteger
+	case float64:
+		return typeNumber
+	case bool:
+		return typeBoolean
+	case []interface{}:
+		return typeList
+	case map[string]interface{}:
+		return typeMap
+	}
+	return typeAny
+}
+
+// fieldTypes returns the sorted types of the values of the field found in the definitions
+func fieldTypes(definitions map[string]*parser.DictionaryDefinition, field string) []string {
+	types := map[string]bool{}
+	for _, dfn := range definitions {
+		if v, ok := dfn.Fields[field]; ok && v != nil {
+			types[valueType(v)] = true
+		}
+	}
+	if len(types) == 0 {
+		return []string{typeAny}
+	}
+	return sortedKeys(types)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// declaringClass returns the class, of the class and its bases, in which the field was first declared
+func declaringClass(df *parser.DefinitionFormat, class string, declared func(*parser.ClassDefinitionFormat) bool) string {
+	bases := df.BaseClasses(class)
+	for i := len(bases) - 1; i >= 0; i-- {
+		if cf := df.ClassFormat[bases[i]]; cf != nil && declared(cf) {
+			return bases[i]
+		}
+	}
+	return ""
+}
+
+func buildFields(df *parser.DefinitionFormat, class string, definitions map[string]*parser.DictionaryDefinition) []Field {
+	cf := df.ClassFormat[class]
+	fields := map[string]Field{}
+	for name, f := range cf.OptionalFields {
+		fields[name] = Field{Name: name, Kind: kindOptional, Description: f.Description,
+			Types: fieldTypes(definitions, name)}
+	}
+	for name, f := range cf.ComputedFields {
+		fields[name] = Field{Name: name, Kind: kindComputed, Description: f.Description, Expression: f.Expression,
+			Types: fieldTypes(definitions, name)}
+	}
+	for name, f := range cf.MandatoryFields {
+		fields[name] = Field{Name: name, Kind: kindMandatory, Description: f.Description, Types: []string{typeString}}
+	}
+
+	result := make([]Field, 0, len(fields))
+	for _, name := range sortedFieldNames(fields) {
+		f := fields[name]
+		f.Inherited = declaringClass(df, class, func(base *parser.ClassDefinitionFormat) bool {
+			_, mandatory := base.MandatoryFields[name]
+			_, optional := base.OptionalFields[name]
+			_, computed := base.ComputedFields[name]
+			return mandatory || optional || computed
+		})
+		result = append(result, f)
+	}
+	return result
+}
+
+func sortedFieldNames(fields map[string]Field) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildRelationships returns the relationships from, and to, definitions of the class
+func buildRelationships(d parser.Dictionary, class string) (outgoing, incoming []Relationship) {
+	out := map[Relationship]int{}
+	in := map[Relationship]int{}
+	for fromClass, definitions := range d {
+		for _, dfn := range definitions {
+			for _, ref := range dfn.References {
+				if fromClass == class {
+					out[Relationship{Name: ref.Relationship, Class: ref.Class}]++
+				}
+				if ref.Class == class {
+					in[Relationship{Name: ref.Relationship, Class: fromClass}]++
+				}
+			}
+		}
+	}
+	return countedRelationships(out), countedRelationships(in)
+}
+
+func countedRelationships(counts map[Relationship]int) (rels []Relationship) {
+	for r, count := range counts {
+		r.Count = count
+		rels = append(rels, r)
+	}
+	sort.Slice(rels, func(i, j int) bool {
+		if rels[i].Name != rels[j].Name {
+			return rels[i].Name < rels[j].Name
+		}
+		return rels[i].Class < rels[j].Class
+	})
+	return rels
+}
+
+func truncateValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok && len([]rune(s)) > exampleValueMaxLength {
+		return string([]rune(s)[:exampleValueMaxLength]) + exampleTruncated
+	}
+	return v
+}
+
+// buildExamples returns up to count definitions of the class as YAML, truncating long values such as file contents
+func buildExamples(definitions map[string]*parser.DictionaryDefinition, count int) (examples []Example) {
+	if count <= 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(definitions))
+	for id := range definitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) > count {
+		ids = ids[:count]
+	}
+
+	for _, id := range ids {
+		dfn := definitions[id]
+		ex := exampleDefinition{Fields: definition.Fields{}}
+		for name, v := range dfn.Fields {
+			ex.Fields[name] = truncateValue(v)
+		}
+		for _, ref := range dfn.References {
+			ex.References = append(ex.References, exampleReference(ref))
+		}
+
+		b, err := yaml.Marshal(map[string]exampleDefinition{id: ex})
+		if err != nil {
+			continue
+		}
+		examples = append(examples, Example{ID: id, YAML: strings.TrimSpace(string(b))})
+	}
+	return examples
+}
+
+// Build documents each class of the definition format, including up to examples definitions of each class
+func Build(df *parser.DefinitionFormat, d parser.Dictionary, examples int) *Documentation {
+	doc := &Documentation{}
+	if df == nil {
+		return doc
+	}
+
+	classes := make([]string, 0, len(df.ClassFormat))
+	for class, cf := range df.ClassFormat {
+		if cf != nil {
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+
+	for _, class := range classes {
+		cf := df.ClassFormat[class]
+		c := &Class{
+			Name:        class,
+			Description: cf.Description,
+			Abstract:    cf.Abstract,
+			Extends:     cf.Extends,
+			SubClasses:  df.SubClasses(class),
+			Definitions: len(d[class]),
+			Fields:      buildFields(df, class, d[class]),
+			Rules:       cf.Rules,
+			Examples:    buildExamples(d[class], examples),
+		}
+		c.Outgoing, c.Incoming = buildRelationships(d, class)
+		doc.Classes = append(doc.Classes, c)
+	}
+
+	for _, c := range doc.Classes {
+		c.Diagram = classDiagram(doc, map[string]bool{c.Name: true})
+	}
+	doc.Diagram = classDiagram(doc, nil)
+
+	return doc
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package docs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFormat() *parser.DefinitionFormat {
+	df := &parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{
+		"Resource": {
+			Description:     "Anything which can be provided",
+			Abstract:        true,
+			MandatoryFields: map[string]parser.ClassField{"Name": {Description: "The display name"}},
+		},
+		"Service": {
+			Description:    "A service offered by a provider",
+			Extends:        "Resource",
+			OptionalFields: map[string]parser.ClassField{"Cost": {Description: "Monthly cost"}, "Tags": {}},
+			ComputedFields: map[string]parser.ComputedField{
+				"Label": {Description: "Name in capitals", Expression: "upper(Name)"},
+			},
+			Rules: []parser.Rule{{ID: "cost", Expression: "Cost >= 0", Severity: "warning"}},
+		},
+		"Provider": {
+			MandatoryFields: map[string]parser.ClassField{"Name": {}},
+		},
+	}}
+	if err := df.ResolveInheritance(); err != nil {
+		panic(err)
+	}
+	return df
+}
+
+func getDictionary() parser.Dictionary {
+	return parser.Dictionary{
+		"Provider": {
+			"aws":   {Fields: definition.Fields{"Name": "Amazon Web Services"}},
+			"azure": {Fields: definition.Fields{"Name": strings.Repeat("a", 250)}},
+		},
+		"Service": {
+			"vm": {Fields: definition.Fields{"Name": "VM", "Cost": 10, "Tags": []interface{}{"compute"}},
+				References: []definition.Reference{{Class: "Provider", ID: "azure", Relationship: "HOSTED_BY"}}},
+			"s3": {Fields: definition.Fields{"Name": "S3", "Cost": 2.5},
+				References: []definition.Reference{{Class: "Provider", ID: "aws", Relationship: "HOSTED_BY"}}},
+		},
+	}
+}
+
+func Test_Build(t *testing.T) {
+	doc := Build(getFormat(), getDictionary(), 1)
+	assert.Len(t, doc.Classes, 3)
+
+	provider, resource, service := doc.Classes[0], doc.Classes[1], doc.Classes[2]
+	assert.Equal(t, "Provider", provider.Name)
+	assert.Equal(t, 2, provider.Definitions)
+	assert.Equal(t, []Relationship{{Name: "HOSTED_BY", Class: "Service", Count: 2}}, provider.Incoming)
+	assert.Nil(t, provider.Outgoing)
+	assert.Len(t, provider.Examples, 1)
+	assert.Equal(t, "aws", provider.Examples[0].ID)
+
+	assert.True(t, resource.Abstract)
+	assert.Equal(t, []string{"Service"}, resource.SubClasses)
+	assert.Equal(t, 0, resource.Definitions)
+
+	assert.Equal(t, "Resource", service.Extends)
+	assert.Equal(t, []Field{
+		{Name: "Cost", Kind: kindOptional, Description: "Monthly cost", Types: []string{typeInteger, typeNumber}},
+		{Name: "Label", Kind: kindComputed, Description: "Name in capitals", Types: []string{typeAny},
+			Expression: "upper(Name)"},
+		{Name: "Name", Kind: kindMandatory, Description: "The display name", Types: []string{typeString},
+			Inherited: "Resource"},
+		{Name: "Tags", Kind: kindOptional, Types: []string{typeList}},
+	}, service.Fields)
+	assert.Equal(t, []Relationship{{Name: "HOSTED_BY", Class: "Provider", Count: 2}}, service.Outgoing)
+	assert.Len(t, service.Rules, 1)
+	assert.Equal(t, "s3", service.Examples[0].ID)
+	assert.Contains(t, service.Examples[0].YAML, "Relationship: HOSTED_BY")
+	assert.NotContains(t, service.Examples[0].YAML, "RelationshipFrom")
+
+	assert.Empty(t, Build(nil, getDictionary(), 1).Classes)
+}
+
+func Test_buildExamples(t *testing.T) {
+	examples := buildExamples(getDictionary()["Provider"], 5)
+	assert.Len(t, examples, 2)
+	assert.Contains(t, examples[1].YAML, strings.Repeat("a", exampleValueMaxLength)+exampleTruncated)
+	assert.NotContains(t, examples[1].YAML, strings.Repeat("a", exampleValueMaxLength+1))
+
+	assert.Empty(t, buildExamples(getDictionary()["Provider"], 0))
+	assert.Empty(t, buildExamples(getDictionary()["Provider"], -1))
+}
+
+func Test_classDiagram(t *testing.T) {
+	doc := Build(getFormat(), getDictionary(), 0)
+
+	assert.Equal(t, "classDiagram\n"+
+		"  class Provider\n"+
+		"  class Resource\n"+
+		"  class Service\n"+
+		"  Resource <|-- Service\n"+
+		"  Service --> Provider : HOSTED_BY\n", doc.Diagram)
+
+	assert.Equal(t, "classDiagram\n"+
+		"  class Resource {\n"+
+		"    <<abstract>>\n"+
+		"    +string Name\n"+
+		"  }\n"+
+		"  class Service\n"+
+		"  Resource <|-- Service\n", doc.Classes[1].Diagram)
+
+	assert.Contains(t, doc.Classes[2].Diagram, "    +any Cost\n")
+	assert.Contains(t, doc.Classes[0].Diagram, "  Service --> Provider : HOSTED_BY\n")
+}
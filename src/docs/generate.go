@@ -0,0 +1,175 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package docs
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	// FormatMarkdown generates Markdown pages
+	FormatMarkdown = "markdown"
+	// FormatHTML generates HTML pages, rendered from the Markdown pages
+	FormatHTML = "html"
+
+	extMarkdown = ".md"
+	extHTML     = ".html"
+	indexName   = "index"
+
+	templateIndex = "templates/index.md.tmpl"
+	templateClass = "templates/class.md.tmpl"
+	templatePage  = "templates/page.html.tmpl"
+
+	indexTitle = "Definition Format Reference"
+
+	directoryPerm = 0755
+	filePerm      = 0644
+
+	errorInvalidFormat = "invalid documentation format [%s]; must be one of markdown or html"
+)
+
+var (
+	//go:embed templates
+	templates embed.FS
+)
+
+type (
+	// page is the data passed to the index and class templates
+	page struct {
+		Documentation *Documentation
+		Class         *Class
+		Index         string
+	}
+
+	// htmlPage is the data passed to the HTML page template
+	htmlPage struct {
+		Title string
+		Body  htmltemplate.HTML
+	}
+
+	generator struct {
+		doc   *Documentation
+		ext   string
+		index *texttemplate.Template
+		class *texttemplate.Template
+		page  *htmltemplate.Template
+	}
+)
+
+// cell escapes a value for use within a Markdown table cell
+func cell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\r\n", " ", "\n", " ").Replace(strings.TrimSpace(s))
+}
+
+func newGenerator(doc *Documentation, format string) (*generator, error) {
+	g := &generator{doc: doc}
+	switch format {
+	case FormatMarkdown:
+		g.ext = extMarkdown
+	case FormatHTML:
+		g.ext = extHTML
+	default:
+		return nil, fmt.Errorf(errorInvalidFormat, format)
+	}
+
+	documented := map[string]bool{}
+	for _, c := range doc.Classes {
+		documented[c.Name] = true
+	}
+	funcs := texttemplate.FuncMap{
+		"cell": cell,
+		"join": strings.Join,
+		// link links to the page of a class, if it has one
+		"link": func(class string) string {
+			if !documented[class] {
+				return class
+			}
+			return "[" + class + "](" + class + g.ext + ")"
+		},
+	}
+
+	var err error
+	if g.index, err = texttemplate.New(filepath.Base(templateIndex)).Funcs(funcs).ParseFS(templates, templateIndex); err != nil {
+		return nil, err
+	}
+	if g.class, err = texttemplate.New(filepath.Base(templateClass)).Funcs(funcs).ParseFS(templates, templateClass); err != nil {
+		return nil, err
+	}
+	if g.page, err = htmltemplate.ParseFS(templates, templatePage); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// render executes the Markdown template, converting the result to HTML if required
+func (g *generator) render(t *texttemplate.Template, title string, data page) ([]byte, error) {
+	var md bytes.Buffer
+	if err := t.Execute(&md, data); err != nil {
+		return nil, err
+	}
+	if g.ext == extMarkdown {
+		return md.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	if err := goldmark.New(goldmark.WithExtensions(extension.GFM)).Convert(md.Bytes(), &body); err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	err := g.page.Execute(&html, htmlPage{Title: title, Body: htmltemplate.HTML(body.String())})
+	return html.Bytes(), err
+}
+
+// Generate writes the documentation to the directory, as an index page together with a page for each class
+func Generate(doc *Documentation, format, dir string) error {
+	g, err := newGenerator(doc, format)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, directoryPerm); err != nil {
+		return err
+	}
+
+	index := indexName + g.ext
+	b, err := g.render(g.index, indexTitle, page{Documentation: doc, Index: index})
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filepath.Join(dir, index), b, filePerm); err != nil {
+		return err
+	}
+
+	for _, c := range doc.Classes {
+		if b, err = g.render(g.class, c.Name, page{Documentation: doc, Class: c, Index: index}); err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(dir, c.Name+g.ext), b, filePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package docs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cell(t *testing.T) {
+	assert.Equal(t, "a \\| b c", cell(" a | b\nc "))
+}
+
+func Test_Generate(t *testing.T) {
+	doc := Build(getFormat(), getDictionary(), 1)
+
+	t.Run("Markdown", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, Generate(doc, FormatMarkdown, dir))
+
+		index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(index), "| [Resource](Resource.md) _(abstract)_ |  | 0 | Anything which can be provided |")
+		assert.Contains(t, string(index), "```mermaid\nclassDiagram\n")
+
+		service, err := os.ReadFile(filepath.Join(dir, "Service.md"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(service), "# Service\n\n[Index](index.md)\n\nA service offered by a provider\n")
+		assert.Contains(t, string(service), "Extends [Resource](Resource.md).")
+		assert.Contains(t, string(service),
+			"| `Name` | mandatory (from [Resource](Resource.md)) | string | The display name |")
+		assert.Contains(t, string(service),
+			"| `Label` | computed | any | Name in capitals `upper(Name)` |")
+		assert.Contains(t, string(service), "| `cost` | warning |  | `Cost >= 0` |")
+		assert.Contains(t, string(service), "| outgoing | `HOSTED_BY` | [Provider](Provider.md) | 2 |")
+		assert.Contains(t, string(service), "```yaml\ns3:\n")
+
+		resource, err := os.ReadFile(filepath.Join(dir, "Resource.md"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(resource), "No relationships are found in the definitions.")
+		assert.NotContains(t, string(resource), "## Examples")
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, Generate(doc, FormatHTML, dir))
+
+		index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(index), "<title>Definition Format Reference</title>")
+		assert.Contains(t, string(index), `<a href="Service.html">Service</a>`)
+		assert.Contains(t, string(index), `<code class="language-mermaid">classDiagram`)
+
+		service, err := os.ReadFile(filepath.Join(dir, "Service.html"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(service), "<title>Service</title>")
+		assert.Contains(t, string(service), "<table>")
+	})
+
+	assert.EqualError(t, Generate(doc, "pdf", t.TempDir()),
+		"invalid documentation format [pdf]; must be one of markdown or html")
+}
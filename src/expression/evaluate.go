@@ -0,0 +1,812 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package expression
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	errorUnknownFunction      = "unknown function [%s]"
+	errorInvalidArguments     = "invalid arguments for function [%s]"
+	errorInvalidOperands      = "invalid operands for operator [%s]"
+	errorDivisionByZero       = "division by zero"
+	errorMacroVariable        = "first argument to [%s] must be a variable name"
+	errorMacroTarget          = "[%s] can only be applied to a list"
+	errorIndexOutOfRange      = "index [%d] out of range"
+	errorCannotIndex          = "cannot index value of type [%T]"
+	errorCannotConvert        = "cannot convert [%v] to %s"
+	errorExpressionNotBoolean = "expression [%s] did not evaluate to a boolean"
+	errorInvalidRegExp        = "invalid regular expression [%s]"
+)
+
+type (
+	// Environment provides the variables and domain-specific functions available to an expression. Resolve returns
+	// false if the name is unknown, in which case the identifier evaluates to null; Call returns false if the function
+	// is not provided by the Environment, in which case the built-in functions are consulted.
+	Environment interface {
+		Resolve(name string) (interface{}, bool)
+		Call(name string, args []interface{}) (interface{}, bool, error)
+	}
+
+	// Object is a value whose fields are resolved when they are accessed rather than when it is created, so that
+	// resolving a field can fail; an Object is converted into a map of all of its fields when passed to a function
+	Object interface {
+		Field(name string) (interface{}, error)
+		Fields() (map[string]interface{}, error)
+	}
+
+	// scope binds macro variables on top of an Environment
+	scope struct {
+		parent   Environment
+		name     string
+		variable interface{}
+	}
+
+	builtinFunc func(args []interface{}) (interface{}, error)
+)
+
+var (
+	slugRegExp = regexp.MustCompile(`[^a-z0-9]+`)
+
+	builtins = map[string]builtinFunc{
+		"size":       fnSize,
+		"has":        fnHas,
+		"default":    fnDefault,
+		"string":     fnString,
+		"int":        fnInt,
+		"float":      fnFloat,
+		"lower":      stringFunc("lower", strings.ToLower),
+		"upper":      stringFunc("upper", strings.ToUpper),
+		"title":      stringFunc("title", capitalise),
+		"trim":       stringFunc("trim", strings.TrimSpace),
+		"slug":       stringFunc("slug", Slug),
+		"contains":   fnContains,
+		"startsWith": stringPredicate("startsWith", strings.HasPrefix),
+		"endsWith":   stringPredicate("endsWith", strings.HasSuffix),
+		"matches":    fnMatches,
+		"replace":    fnReplace,
+		"split":      fnSplit,
+		"join":       fnJoin,
+		"concat":     fnConcat,
+		"uniq":       fnUniq,
+		"first":      fnFirst,
+		"last":       fnLast,
+	}
+
+	macros = map[string]bool{"all": true, "exists": true, "exists_one": true, "filter": true, "map": true}
+)
+
+func (s scope) Resolve(name string) (interface{}, bool) {
+	if name == s.name {
+		return s.variable, true
+	}
+	return s.parent.Resolve(name)
+}
+
+func (s scope) Call(name string, args []interface{}) (interface{}, bool, error) {
+	return s.parent.Call(name, args)
+}
+
+// Evaluate evaluates the expression against the Environment provided
+func (e *Expression) Evaluate(env Environment) (interface{}, error) {
+	return evaluate(e.root, env)
+}
+
+// EvaluateBool evaluates the expression and returns an error if the result is not a boolean
+func (e *Expression) EvaluateBool(env Environment) (bool, error) {
+	v, err := e.Evaluate(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf(errorExpressionNotBoolean, e.source)
+	}
+	return b, nil
+}
+
+// Slug converts a string into a lowercase, hyphen-separated identifier suitable for use in URLs and filenames
+func Slug(s string) string {
+	return strings.Trim(slugRegExp.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Truthy indicates whether a value should be treated as true in a boolean context
+func Truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case int:
+		return t != 0
+	case int64:
+		return t != 0
+	case float64:
+		return t != 0
+	case []interface{}:
+		return len(t) > 0
+	case map[string]interface{}:
+		return len(t) > 0
+	}
+	return true
+}
+
+// ToString converts a value into its string representation; null is converted to an empty string
+func ToString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch t := v.(type) {
+	case int:
+		return t, true
+	case int64:
+		return int(t), true
+	}
+	return 0, false
+}
+
+// fields converts an Object into a map of all of its fields; any other value is returned unchanged
+func fields(v interface{}) (interface{}, error) {
+	if o, ok := v.(Object); ok {
+		return o.Fields()
+	}
+	return v, nil
+}
+
+// toList converts any slice into a []interface{}
+func toList(v interface{}) ([]interface{}, bool) {
+	if l, ok := v.([]interface{}); ok {
+		return l, true
+	}
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, true
+}
+
+// toMap converts any map keyed by string into a map[string]interface{}
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(v)
+	if v == nil || rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	m := make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		m[k.String()] = rv.MapIndex(k).Interface()
+	}
+	return m, true
+}
+
+// Equal compares two values, treating numbers of different types as equal if their values are equal
+func Equal(a, b interface{}) bool {
+	af, aIsNumber := toFloat(a)
+	bf, bIsNumber := toFloat(b)
+	if aIsNumber && bIsNumber {
+		return af == bf
+	}
+	if al, ok := toList(a); ok {
+		bl, ok := toList(b)
+		if !ok || len(al) != len(bl) {
+			return false
+		}
+		for i := range al {
+			if !Equal(al[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// Compare orders two numbers or two strings, returning -1, 0 or 1
+func Compare(a, b interface{}) (int, bool) {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if as, ok := a.(string); ok {
+		if bs, ok := b.(string); ok {
+			return strings.Compare(as, bs), true
+		}
+	}
+	return 0, false
+}
+
+func evaluate(n node, env Environment) (interface{}, error) {
+	switch t := n.(type) {
+	case literalNode:
+		return t.value, nil
+
+	case identNode:
+		v, _ := env.Resolve(t.name)
+		return v, nil
+
+	case listNode:
+		l := make([]interface{}, 0, len(t.items))
+		for _, item := range t.items {
+			v, err := evaluate(item, env)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+		}
+		return l, nil
+
+	case unaryNode:
+		v, err := evaluate(t.operand, env)
+		if err != nil {
+			return nil, err
+		}
+		if t.op == "!" {
+			return !Truthy(v), nil
+		}
+		if i, ok := toInt(v); ok {
+			return -i, nil
+		}
+		if f, ok := toFloat(v); ok {
+			return -f, nil
+		}
+		return nil, fmt.Errorf(errorInvalidOperands, t.op)
+
+	case ternaryNode:
+		c, err := evaluate(t.condition, env)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(c) {
+			return evaluate(t.whenTrue, env)
+		}
+		return evaluate(t.whenFalse, env)
+
+	case binaryNode:
+		return evaluateBinary(t, env)
+
+	case memberNode:
+		target, err := evaluate(t.target, env)
+		if err != nil {
+			return nil, err
+		}
+		if o, ok := target.(Object); ok {
+			return o.Field(t.name)
+		}
+		if m, ok := toMap(target); ok {
+			return m[t.name], nil
+		}
+		return nil, nil
+
+	case indexNode:
+		target, err := evaluate(t.target, env)
+		if err != nil {
+			return nil, err
+		}
+		index, err := evaluate(t.index, env)
+		if err != nil {
+			return nil, err
+		}
+		return evaluateIndex(target, index)
+
+	case callNode:
+		return evaluateCall(t, env)
+	}
+
+	return nil, fmt.Errorf(errorUnexpectedToken, fmt.Sprintf("%T", n), 0)
+}
+
+func evaluateIndex(target, index interface{}) (interface{}, error) {
+	if o, ok := target.(Object); ok {
+		return o.Field(ToString(index))
+	}
+	if m, ok := toMap(target); ok {
+		return m[ToString(index)], nil
+	}
+	if l, ok := toList(target); ok {
+		i, ok := toInt(index)
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidOperands, "[]")
+		}
+		if i < 0 {
+			i += len(l)
+		}
+		if i < 0 || i >= len(l) {
+			return nil, fmt.Errorf(errorIndexOutOfRange, i)
+		}
+		return l[i], nil
+	}
+	if target == nil {
+		return nil, nil
+	}
+	return nil, fmt.Errorf(errorCannotIndex, target)
+}
+
+func evaluateBinary(t binaryNode, env Environment) (interface{}, error) {
+	left, err := evaluate(t.left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit the logical operators
+	switch t.op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := evaluate(t.right, env)
+		return Truthy(right), err
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := evaluate(t.right, env)
+		return Truthy(right), err
+	}
+
+	right, err := evaluate(t.right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.op {
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, ok := Compare(left, right)
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidOperands, t.op)
+		}
+		switch t.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "in":
+		if right, err = fields(right); err != nil {
+			return nil, err
+		}
+		return fnContains([]interface{}{right, left})
+	case "+":
+		if ll, ok := toList(left); ok {
+			if rl, ok := toList(right); ok {
+				return append(append([]interface{}{}, ll...), rl...), nil
+			}
+		}
+		_, leftIsString := left.(string)
+		_, rightIsString := right.(string)
+		if leftIsString || rightIsString {
+			return ToString(left) + ToString(right), nil
+		}
+	}
+
+	return arithmetic(t.op, left, right)
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	li, leftIsInt := toInt(left)
+	ri, rightIsInt := toInt(right)
+	if leftIsInt && rightIsInt {
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "/", "%":
+			if ri == 0 {
+				return nil, fmt.Errorf(errorDivisionByZero)
+			}
+			if op == "/" {
+				return li / ri, nil
+			}
+			return li % ri, nil
+		}
+	}
+
+	lf, leftIsNumber := toFloat(left)
+	rf, rightIsNumber := toFloat(right)
+	if !leftIsNumber || !rightIsNumber {
+		return nil, fmt.Errorf(errorInvalidOperands, op)
+	}
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/", "%":
+		if rf == 0 {
+			return nil, fmt.Errorf(errorDivisionByZero)
+		}
+		if op == "/" {
+			return lf / rf, nil
+		}
+		return math.Mod(lf, rf), nil
+	}
+
+	return nil, fmt.Errorf(errorInvalidOperands, op)
+}
+
+func evaluateCall(t callNode, env Environment) (interface{}, error) {
+	if t.target != nil && macros[t.name] {
+		return evaluateMacro(t, env)
+	}
+
+	var args []interface{}
+	if t.target != nil {
+		target, err := evaluate(t.target, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, target)
+	}
+	for _, a := range t.args {
+		v, err := evaluate(a, env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+
+	v, found, err := env.Call(t.name, args)
+	if found {
+		return v, err
+	}
+	if fn, found := builtins[t.name]; found {
+		for i := range args {
+			if args[i], err = fields(args[i]); err != nil {
+				return nil, err
+			}
+		}
+		return fn(args)
+	}
+
+	return nil, fmt.Errorf(errorUnknownFunction, t.name)
+}
+
+// evaluateMacro implements the CEL-style list macros, e.g. list.exists(x, x.Name == "a")
+func evaluateMacro(t callNode, env Environment) (interface{}, error) {
+	if len(t.args) != 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, t.name)
+	}
+	variable, ok := t.args[0].(identNode)
+	if !ok {
+		return nil, fmt.Errorf(errorMacroVariable, t.name)
+	}
+
+	target, err := evaluate(t.target, env)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := toList(target)
+	if !ok {
+		if target != nil {
+			return nil, fmt.Errorf(errorMacroTarget, t.name)
+		}
+		list = nil
+	}
+
+	matches := 0
+	result := []interface{}{}
+	for _, item := range list {
+		v, err := evaluate(t.args[1], scope{parent: env, name: variable.name, variable: item})
+		if err != nil {
+			return nil, err
+		}
+
+		switch t.name {
+		case "map":
+			result = append(result, v)
+		case "filter":
+			if Truthy(v) {
+				result = append(result, item)
+			}
+		case "all":
+			if !Truthy(v) {
+				return false, nil
+			}
+		case "exists":
+			if Truthy(v) {
+				return true, nil
+			}
+		case "exists_one":
+			if Truthy(v) {
+				matches++
+			}
+		}
+	}
+
+	switch t.name {
+	case "all":
+		return true, nil
+	case "exists":
+		return false, nil
+	case "exists_one":
+		return matches == 1, nil
+	}
+	return result, nil
+}
+
+func stringFunc(name string, f func(string) string) builtinFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf(errorInvalidArguments, name)
+		}
+		return f(ToString(args[0])), nil
+	}
+}
+
+func stringPredicate(name string, f func(string, string) bool) builtinFunc {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf(errorInvalidArguments, name)
+		}
+		return f(ToString(args[0]), ToString(args[1])), nil
+	}
+}
+
+func fnSize(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "size")
+	}
+	switch t := args[0].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return len([]rune(t)), nil
+	}
+	if l, ok := toList(args[0]); ok {
+		return len(l), nil
+	}
+	if m, ok := toMap(args[0]); ok {
+		return len(m), nil
+	}
+	return nil, fmt.Errorf(errorInvalidArguments, "size")
+}
+
+func fnHas(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "has")
+	}
+	if s, ok := args[0].(string); ok {
+		return strings.TrimSpace(s) != "", nil
+	}
+	return args[0] != nil, nil
+}
+
+func fnDefault(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, "default")
+	}
+	if s, ok := args[0].(string); (ok && s == "") || args[0] == nil {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func fnString(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "string")
+	}
+	return ToString(args[0]), nil
+}
+
+func fnInt(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "int")
+	}
+	if i, ok := toInt(args[0]); ok {
+		return i, nil
+	}
+	if f, ok := toFloat(args[0]); ok {
+		return int(f), nil
+	}
+	if i, err := strconv.Atoi(strings.TrimSpace(ToString(args[0]))); err == nil {
+		return i, nil
+	}
+	return nil, fmt.Errorf(errorCannotConvert, args[0], "int")
+}
+
+func fnFloat(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "float")
+	}
+	if f, ok := toFloat(args[0]); ok {
+		return f, nil
+	}
+	if f, err := strconv.ParseFloat(strings.TrimSpace(ToString(args[0])), 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf(errorCannotConvert, args[0], "float")
+}
+
+// fnContains checks for a substring, a list element or a map key
+func fnContains(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, "contains")
+	}
+	if s, ok := args[0].(string); ok {
+		return strings.Contains(s, ToString(args[1])), nil
+	}
+	if l, ok := toList(args[0]); ok {
+		for _, item := range l {
+			if Equal(item, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if m, ok := toMap(args[0]); ok {
+		_, found := m[ToString(args[1])]
+		return found, nil
+	}
+	return false, nil
+}
+
+func fnMatches(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, "matches")
+	}
+	re, err := regexp.Compile(ToString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf(errorInvalidRegExp, ToString(args[1]))
+	}
+	return re.MatchString(ToString(args[0])), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf(errorInvalidArguments, "replace")
+	}
+	return strings.ReplaceAll(ToString(args[0]), ToString(args[1]), ToString(args[2])), nil
+}
+
+func fnSplit(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, "split")
+	}
+	var l []interface{}
+	for _, s := range strings.Split(ToString(args[0]), ToString(args[1])) {
+		l = append(l, s)
+	}
+	return l, nil
+}
+
+func fnJoin(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf(errorInvalidArguments, "join")
+	}
+	l, ok := toList(args[0])
+	if !ok && args[0] != nil {
+		return nil, fmt.Errorf(errorInvalidArguments, "join")
+	}
+	separator := ""
+	if len(args) == 2 {
+		separator = ToString(args[1])
+	}
+	s := make([]string, len(l))
+	for i, item := range l {
+		s[i] = ToString(item)
+	}
+	return strings.Join(s, separator), nil
+}
+
+func fnConcat(args []interface{}) (interface{}, error) {
+	var sb strings.Builder
+	for _, a := range args {
+		sb.WriteString(ToString(a))
+	}
+	return sb.String(), nil
+}
+
+func fnUniq(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "uniq")
+	}
+	l, _ := toList(args[0])
+	result := []interface{}{}
+	for _, item := range l {
+		found, _ := fnContains([]interface{}{result, item})
+		if !found.(bool) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func fnFirst(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "first")
+	}
+	if l, ok := toList(args[0]); ok && len(l) > 0 {
+		return l[0], nil
+	}
+	return nil, nil
+}
+
+func fnLast(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf(errorInvalidArguments, "last")
+	}
+	if l, ok := toList(args[0]); ok && len(l) > 0 {
+		return l[len(l)-1], nil
+	}
+	return nil, nil
+}
+
+// capitalise is used by the title function to upper-case the first letter of each word
+func capitalise(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package expression
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEnvironment map[string]interface{}
+
+func (e testEnvironment) Resolve(name string) (interface{}, bool) {
+	v, ok := e[name]
+	return v, ok
+}
+
+func (e testEnvironment) Call(name string, args []interface{}) (interface{}, bool, error) {
+	switch name {
+	case "double":
+		return args[0].(int) * 2, true, nil
+	case "fail":
+		return nil, true, errors.New("failed")
+	}
+	return nil, false, nil
+}
+
+// testObject resolves its fields on demand, failing for any field named Broken
+type testObject map[string]interface{}
+
+func (o testObject) Field(name string) (interface{}, error) {
+	if name == "Broken" {
+		return nil, errors.New("broken")
+	}
+	return o[name], nil
+}
+
+func (o testObject) Fields() (map[string]interface{}, error) {
+	if _, ok := o["Broken"]; ok {
+		return nil, errors.New("broken")
+	}
+	return o, nil
+}
+
+func evaluateString(t *testing.T, source string) (interface{}, error) {
+	e, err := Parse(source)
+	assert.Nil(t, err, source)
+
+	return e.Evaluate(testEnvironment{
+		"Name":   "Virtual Machine",
+		"Count":  3,
+		"Price":  1.5,
+		"Public": true,
+		"Tags":   []interface{}{"a", "b", "a"},
+		"Parent": map[string]interface{}{"Name": "Compute", "ID": "compute"},
+		"Items": []interface{}{
+			map[string]interface{}{"Name": "x", "Size": 1},
+			map[string]interface{}{"Name": "y", "Size": 2},
+		},
+	})
+}
+
+func Test_Evaluate(t *testing.T) {
+	valid := map[string]interface{}{
+		`Name`:                       "Virtual Machine",
+		`Missing`:                    nil,
+		`Count + 2 * 3`:              9,
+		`Count / 2`:                  1,
+		`Count % 2`:                  1,
+		`Price * 2`:                  3.0,
+		`-Count`:                     -3,
+		`Count == 3.0`:               true,
+		`Name != "x"`:                true,
+		`Count >= 3 && Price < 2`:    true,
+		`Missing || Public`:          true,
+		`!Public`:                    false,
+		`"b" in Tags`:                true,
+		`"Machine" in Name`:          true,
+		`"ID" in Parent`:             true,
+		`Parent.Name + "/" + Name`:   "Compute/Virtual Machine",
+		`Parent["ID"]`:               "compute",
+		`Missing.Name`:               nil,
+		`Tags[-1]`:                   "a",
+		`Public ? "yes" : "no"`:      "yes",
+		`slug(Name)`:                 "virtual-machine",
+		`Name.lower()`:               "virtual machine",
+		`title("hello world")`:       "Hello World",
+		`size(Tags)`:                 3,
+		`Tags.uniq().size()`:         2,
+		`join(Tags, ",")`:            "a,b,a",
+		`concat(Name, " ", Count)`:   "Virtual Machine 3",
+		`default(Missing, "none")`:   "none",
+		`has(Name) && !has(Missing)`: true,
+		`Name.startsWith("Virtual")`: true,
+		`Name.matches("^V.*e$")`:     true,
+		`int("42") + float("0.5")`:   42.5,
+		`Items.map(i, i.Name)`:       []interface{}{"x", "y"},
+		`Items.filter(i, i.Size > 1).map(i, i.Name)`:   []interface{}{"y"},
+		`Items.all(i, i.Size > 0)`:                     true,
+		`Items.exists(i, i.Name == "z")`:               false,
+		`Tags.exists_one(t, t == "b")`:                 true,
+		`Missing.exists(i, true)`:                      false,
+		`double(Count)`:                                6,
+		`[1, 2] + [3]`:                                 []interface{}{1, 2, 3},
+		`split("a-b", "-").last()`:                     "b",
+		`replace(Name, " ", "_")`:                      "Virtual_Machine",
+		`first(Items).Name`:                            "x",
+		`Items.map(Name, Name.Size).exists(s, s == 2)`: true,
+	}
+	for source, expected := range valid {
+		v, err := evaluateString(t, source)
+		assert.Nil(t, err, source)
+		assert.Equal(t, expected, v, source)
+	}
+
+	invalid := []string{`Count / 0`, `Name - 1`, `Name < 1`, `unknown(1)`, `fail()`, `Tags[5]`, `Count.map(i, i)`,
+		`Tags.map("i", i)`, `size(1)`, `matches(Name, "(")`, `int("x")`}
+	for _, source := range invalid {
+		_, err := evaluateString(t, source)
+		assert.NotNil(t, err, source)
+	}
+}
+
+func Test_Evaluate_object(t *testing.T) {
+	env := testEnvironment{
+		"Object": testObject{"Name": "Compute"},
+		"Broken": testObject{"Broken": true},
+	}
+
+	for source, expected := range map[string]interface{}{
+		`Object.Name`:        "Compute",
+		`Object["Name"]`:     "Compute",
+		`size(Object)`:       1,
+		`"Name" in Object`:   true,
+		`has(Object.Absent)`: false,
+	} {
+		e, err := Parse(source)
+		assert.Nil(t, err, source)
+		v, err := e.Evaluate(env)
+		assert.Nil(t, err, source)
+		assert.Equal(t, expected, v, source)
+	}
+
+	for _, source := range []string{`Broken.Broken`, `Broken["Broken"]`, `size(Broken)`, `"Name" in Broken`} {
+		e, err := Parse(source)
+		assert.Nil(t, err, source)
+		_, err = e.Evaluate(env)
+		assert.EqualError(t, err, "broken", source)
+	}
+}
+
+func Test_EvaluateBool(t *testing.T) {
+	e, _ := Parse("1 < 2")
+	b, err := e.EvaluateBool(testEnvironment{})
+	assert.Nil(t, err)
+	assert.True(t, b)
+
+	e, _ = Parse("1 + 2")
+	_, err = e.EvaluateBool(testEnvironment{})
+	assert.NotNil(t, err)
+}
+
+func Test_Slug(t *testing.T) {
+	assert.Equal(t, "azure-kubernetes-service-aks", Slug("Azure Kubernetes Service (AKS)"))
+	assert.Equal(t, "", Slug("--"))
+}
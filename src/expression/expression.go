@@ -0,0 +1,436 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package expression implements a small, CEL-style expression language which is evaluated over the fields and
+// references of definitions. Expressions are parsed once and can then be evaluated against any Environment.
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+const (
+	errorUnexpectedCharacter = "unexpected character [%c] at position %d"
+	errorUnterminatedString  = "unterminated string starting at position %d"
+	errorUnexpectedToken     = "unexpected [%s] at position %d"
+	errorExpectedToken       = "expected [%s] but found [%s] at position %d"
+	errorEmptyExpression     = "expression is empty"
+)
+
+type (
+	token struct {
+		typ   tokenType
+		value string
+		pos   int
+	}
+
+	node interface{}
+
+	literalNode struct {
+		value interface{}
+	}
+
+	identNode struct {
+		name string
+	}
+
+	unaryNode struct {
+		op      string
+		operand node
+	}
+
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+
+	ternaryNode struct {
+		condition, whenTrue, whenFalse node
+	}
+
+	memberNode struct {
+		target node
+		name   string
+	}
+
+	indexNode struct {
+		target, index node
+	}
+
+	callNode struct {
+		// target is nil for global function calls and set for method-style calls such as x.size()
+		target node
+		name   string
+		args   []node
+	}
+
+	listNode struct {
+		items []node
+	}
+
+	// Expression is a parsed expression which can be evaluated any number of times
+	Expression struct {
+		source string
+		root   node
+	}
+
+	parser struct {
+		tokens []token
+		pos    int
+	}
+)
+
+// multi-character operators must precede their single-character prefixes
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "?", ":",
+	"(", ")", "[", "]", ".", ","}
+
+func tokenise(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{typ: tokenIdent, value: string(runes[start:i]), pos: start})
+
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{typ: tokenNumber, value: string(runes[start:i]), pos: start})
+
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for i < len(runes) && runes[i] != r {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i])
+					}
+				} else {
+					sb.WriteRune(runes[i])
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf(errorUnterminatedString, start)
+			}
+			i++
+			tokens = append(tokens, token{typ: tokenString, value: sb.String(), pos: start})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{typ: tokenOperator, value: op, pos: i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf(errorUnexpectedCharacter, r, i)
+			}
+		}
+	}
+
+	return append(tokens, token{typ: tokenEOF, pos: len(runes)}), nil
+}
+
+// Parse compiles the source into an Expression, returning an error if the syntax is invalid
+func Parse(source string) (*Expression, error) {
+	tokens, err := tokenise(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, fmt.Errorf(errorEmptyExpression)
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().typ != tokenEOF {
+		return nil, fmt.Errorf(errorUnexpectedToken, p.peek().value, p.peek().pos)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOperator(values ...string) bool {
+	t := p.peek()
+	if t.typ != tokenOperator && !(t.typ == tokenIdent && t.value == "in") {
+		return false
+	}
+	for _, v := range values {
+		if t.value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if t.value != value || (t.typ != tokenOperator) {
+		return fmt.Errorf(errorExpectedToken, value, t.value, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) parseTernary() (node, error) {
+	condition, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator("?") {
+		return condition, nil
+	}
+	p.next()
+
+	whenTrue, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	whenFalse, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+
+	return ternaryNode{condition: condition, whenTrue: whenTrue, whenFalse: whenFalse}, nil
+}
+
+// binary operators grouped by ascending precedence
+var binaryPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator(binaryPrecedence[level]...) {
+		op := p.next().value
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("!", "-") {
+		op := p.next().value
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parseArgs(closing string) ([]node, error) {
+	var args []node
+	if p.isOperator(closing) {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.isOperator(",") {
+			p.next()
+			continue
+		}
+		if err = p.expect(closing); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.isOperator("."):
+			p.next()
+			t := p.next()
+			if t.typ != tokenIdent {
+				return nil, fmt.Errorf(errorUnexpectedToken, t.value, t.pos)
+			}
+			if p.isOperator("(") {
+				p.next()
+				args, err := p.parseArgs(")")
+				if err != nil {
+					return nil, err
+				}
+				n = callNode{target: n, name: t.value, args: args}
+			} else {
+				n = memberNode{target: n, name: t.value}
+			}
+
+		case p.isOperator("["):
+			p.next()
+			index, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = indexNode{target: n, index: index}
+
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.typ {
+	case tokenNumber:
+		if i, err := strconv.Atoi(t.value); err == nil {
+			return literalNode{value: i}, nil
+		}
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf(errorUnexpectedToken, t.value, t.pos)
+		}
+		return literalNode{value: f}, nil
+
+	case tokenString:
+		return literalNode{value: t.value}, nil
+
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if p.isOperator("(") {
+			p.next()
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return callNode{name: t.value, args: args}, nil
+		}
+		return identNode{name: t.value}, nil
+
+	case tokenOperator:
+		switch t.value {
+		case "(":
+			n, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return listNode{items: items}, nil
+		}
+	}
+
+	if t.typ == tokenEOF {
+		return nil, fmt.Errorf(errorUnexpectedToken, "end of expression", t.pos)
+	}
+	return nil, fmt.Errorf(errorUnexpectedToken, t.value, t.pos)
+}
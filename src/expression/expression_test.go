@@ -0,0 +1,101 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_tokenise(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		tokens, err := tokenise(`Name == "a\"b" && size(x) >= 1.5`)
+		assert.Nil(t, err)
+		assert.Equal(t, []token{
+			{typ: tokenIdent, value: "Name", pos: 0},
+			{typ: tokenOperator, value: "==", pos: 5},
+			{typ: tokenString, value: `a"b`, pos: 8},
+			{typ: tokenOperator, value: "&&", pos: 15},
+			{typ: tokenIdent, value: "size", pos: 18},
+			{typ: tokenOperator, value: "(", pos: 22},
+			{typ: tokenIdent, value: "x", pos: 23},
+			{typ: tokenOperator, value: ")", pos: 24},
+			{typ: tokenOperator, value: ">=", pos: 26},
+			{typ: tokenNumber, value: "1.5", pos: 29},
+			{typ: tokenEOF, pos: 32},
+		}, tokens)
+	})
+
+	t.Run("UnterminatedString", func(t *testing.T) {
+		_, err := tokenise(`Name == "abc`)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("UnexpectedCharacter", func(t *testing.T) {
+		_, err := tokenise(`Name # 1`)
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_Parse(t *testing.T) {
+	t.Run("Precedence", func(t *testing.T) {
+		e, err := Parse("a || b && !c == d + e * f")
+		assert.Nil(t, err)
+		assert.Equal(t, binaryNode{op: "||", left: identNode{name: "a"}, right: binaryNode{
+			op:   "&&",
+			left: identNode{name: "b"},
+			right: binaryNode{
+				op:   "==",
+				left: unaryNode{op: "!", operand: identNode{name: "c"}},
+				right: binaryNode{op: "+", left: identNode{name: "d"}, right: binaryNode{
+					op: "*", left: identNode{name: "e"}, right: identNode{name: "f"}}},
+			},
+		}}, e.root)
+	})
+
+	t.Run("PostfixAndTernary", func(t *testing.T) {
+		e, err := Parse(`refs("X")[0].Name.lower() in ["a", 1] ? 1 : null`)
+		assert.Nil(t, err)
+		assert.Equal(t, ternaryNode{
+			condition: binaryNode{
+				op: "in",
+				left: callNode{
+					target: memberNode{
+						target: indexNode{
+							target: callNode{name: "refs", args: []node{literalNode{value: "X"}}},
+							index:  literalNode{value: 0},
+						},
+						name: "Name",
+					},
+					name: "lower",
+				},
+				right: listNode{items: []node{literalNode{value: "a"}, literalNode{value: 1}}},
+			},
+			whenTrue:  literalNode{value: 1},
+			whenFalse: literalNode{value: nil},
+		}, e.root)
+		assert.Equal(t, `refs("X")[0].Name.lower() in ["a", 1] ? 1 : null`, e.String())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, source := range []string{"", "a ==", "(a", "a b", "f(a,", "a ? b", "a.1", "[1, 2"} {
+			_, err := Parse(source)
+			assert.NotNil(t, err, source)
+		}
+	})
+}
@@ -18,6 +18,7 @@ package graph
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/nextmetaphor/yaml-graph/definition"
@@ -28,6 +29,7 @@ const (
 	deleteAllCypher   = `MATCH (n) DETACH DELETE(n);`
 	mergeCypherPrefix = `MERGE (n:%s {ID:$ID})`
 	mergeCypherField  = `n.%s=$%s`
+	mergeCypherLabels = `n:%s`
 	edgeCypherField   = `%s: "%s"`
 
 	edgeCypher = `
@@ -76,10 +78,15 @@ func ExecuteCypher(session neo4j.Session, cypher string, param map[string]interf
 	return
 }
 
-func getDefinitionCypherString(class string, fields definition.Fields) (cypher string) {
+func getDefinitionCypherString(class string, labels []string, fields definition.Fields) (cypher string) {
 	cypher = fmt.Sprintf(mergeCypherPrefix, class)
 
 	firstValue := true
+	if len(labels) > 0 {
+		cypher = cypher + " SET " + fmt.Sprintf(mergeCypherLabels, strings.Join(labels, ":"))
+		firstValue = false
+	}
+
 	for fieldName := range fields {
 		if !firstValue {
 			cypher = cypher + ","
@@ -125,12 +132,12 @@ func getEdgeCypherString(class, ID string, refs definition.Reference) string {
 	return fmt.Sprintf(edgeCypher, class, ID, refs.Class, refs.ID, relationshipFrom, refs.Relationship, edgeFields, relationshipTo)
 }
 
-// CreateSpecification TODO
-func CreateSpecification(session neo4j.Session, spec definition.Specification) {
+// CreateSpecification TODO; labels holds any additional labels for each class, such as the classes it extends
+func CreateSpecification(session neo4j.Session, spec definition.Specification, labels map[string][]string) {
 	class := spec.Class
 	// iterate through the top-level (i.e. no parent ID) definitions...
 	for definitionID := range spec.Definitions {
-		definitionCypher := getDefinitionCypherString(class, spec.Definitions[definitionID].Fields)
+		definitionCypher := getDefinitionCypherString(class, labels[class], spec.Definitions[definitionID].Fields)
 
 		if spec.Definitions[definitionID].Fields == nil {
 			ExecuteCypher(session, definitionCypher, map[string]interface{}{"ID": definitionID})
@@ -143,7 +150,7 @@ func CreateSpecification(session neo4j.Session, spec definition.Specification) {
 		// TODO - do we really want to use recursion for this?
 		if spec.Definitions[definitionID].SubDefinitions != nil {
 			for subdefinitionID := range spec.Definitions[definitionID].SubDefinitions {
-				CreateSpecification(session, spec.Definitions[definitionID].SubDefinitions[subdefinitionID])
+				CreateSpecification(session, spec.Definitions[definitionID].SubDefinitions[subdefinitionID], labels)
 			}
 		}
 	}
@@ -28,18 +28,18 @@ import (
 func Test_getDefinitionCypherString(t *testing.T) {
 
 	t.Run("ZeroFields", func(t *testing.T) {
-		cypher := getDefinitionCypherString("class1", definition.Fields{})
+		cypher := getDefinitionCypherString("class1", nil, definition.Fields{})
 		assert.Equal(t, fmt.Sprintf(mergeCypherPrefix, "class1"), cypher)
 	})
 
 	t.Run("OneField", func(t *testing.T) {
-		cypher := getDefinitionCypherString("class1", definition.Fields{"name1": "value1"})
+		cypher := getDefinitionCypherString("class1", nil, definition.Fields{"name1": "value1"})
 		assert.Equal(t, fmt.Sprintf(mergeCypherPrefix, "class1")+" SET "+fmt.Sprintf(mergeCypherField,
 			"name1", "name1"), cypher)
 	})
 
 	t.Run("TwoFields", func(t *testing.T) {
-		cypher := getDefinitionCypherString("class1", definition.Fields{
+		cypher := getDefinitionCypherString("class1", nil, definition.Fields{
 			"name1": "value1", "name2": "value2"})
 
 		// can't guarantee order here, so match with a regexp
@@ -57,7 +57,7 @@ func Test_getDefinitionCypherString(t *testing.T) {
 
 	t.Run("ThreeFields", func(t *testing.T) {
 
-		cypher := getDefinitionCypherString("class1", definition.Fields{
+		cypher := getDefinitionCypherString("class1", nil, definition.Fields{
 			"name1": "value1", "name2": "value2", "name3": "value3"})
 
 		// can't guarantee order here, so match with a regexp
@@ -75,6 +75,19 @@ func Test_getDefinitionCypherString(t *testing.T) {
 	})
 }
 
+func Test_getDefinitionCypherStringLabels(t *testing.T) {
+	t.Run("LabelsOnly", func(t *testing.T) {
+		cypher := getDefinitionCypherString("class1", []string{"base1", "base2"}, nil)
+		assert.Equal(t, fmt.Sprintf(mergeCypherPrefix, "class1")+" SET n:base1:base2", cypher)
+	})
+
+	t.Run("LabelsAndField", func(t *testing.T) {
+		cypher := getDefinitionCypherString("class1", []string{"base1"}, definition.Fields{"name1": "value1"})
+		assert.Equal(t, fmt.Sprintf(mergeCypherPrefix, "class1")+" SET n:base1,"+fmt.Sprintf(mergeCypherField,
+			"name1", "name1"), cypher)
+	})
+}
+
 func Test_getEdgeCypherString(t *testing.T) {
 
 	t.Run("FromOnly", func(t *testing.T) {
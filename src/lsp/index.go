@@ -0,0 +1,237 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package lsp
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyClass          = "Class"
+	keyID             = "ID"
+	keyDefinitions    = "Definitions"
+	keyReferences     = "References"
+	keySubDefinitions = "SubDefinitions"
+)
+
+type symbolKind int
+
+const (
+	symbolDefinition symbolKind = iota
+	symbolReference
+)
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+type (
+	// symbol is the ID of a definition, or of a reference to a definition, within a document
+	symbol struct {
+		kind  symbolKind
+		Class string
+		ID    string
+
+		// Range covers the ID text, excluding any quotes
+		Range textRange
+
+		// style is the quoting style of the ID
+		style yaml.Style
+	}
+
+	// document holds the latest content of a definition file together with its symbols
+	document struct {
+		uri  string
+		path string
+		text string
+
+		symbols []symbol
+
+		// spec is nil if the document holds no definitions or could not be parsed, in which case err holds the
+		// reason
+		spec *definition.Specification
+		err  error
+	}
+)
+
+// newDocument parses the text, indexing the symbols within it; if the text cannot be parsed then the symbols from
+// any previous version of the document are kept, so navigation continues to work whilst the document is edited
+func newDocument(uri, path, text string, previous *document) *document {
+	doc := &document{uri: uri, path: path, text: text}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &root); err != nil {
+		doc.err = err
+		if previous != nil {
+			doc.symbols = previous.symbols
+		}
+		return doc
+	}
+	hasDefinitions := false
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		doc.symbols = indexSpecification(root.Content[0])
+		hasDefinitions = mappingValue(root.Content[0], keyDefinitions) != nil
+	}
+
+	// other YAML files, such as the definition format, are ignored rather than reported, in the same way as when
+	// loading the dictionary
+	var typeErr *yaml.TypeError
+	var err error
+	if doc.spec, err = definition.LoadSpecification(path, []byte(text)); hasDefinitions && errors.As(err, &typeErr) {
+		doc.err = err
+	}
+
+	return doc
+}
+
+// errorRange returns the range of the line on which a YAML error occurred, or the start of the document
+func (doc *document) errorRange() textRange {
+	if doc.err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(doc.err.Error()); m != nil {
+			if line, err := strconv.Atoi(m[1]); err == nil && line > 0 {
+				return textRange{Start: position{Line: line - 1}, End: position{Line: line}}
+			}
+		}
+	}
+	return textRange{}
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// byteOffset converts a character offset within a line, which the protocol counts in UTF-16 code units, to a byte
+// offset; an offset beyond the end of the line is treated as the end
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units++
+		if r >= 0x10000 {
+			// encoded as a surrogate pair
+			units++
+		}
+	}
+	return len(line)
+}
+
+// scalarRange returns the range of the value of a scalar node, excluding any quotes
+func scalarRange(n *yaml.Node) textRange {
+	start := n.Column - 1
+	if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		start++
+	}
+	end := start + len(utf16.Encode([]rune(n.Value)))
+	return textRange{Start: position{Line: n.Line - 1, Character: start}, End: position{Line: n.Line - 1, Character: end}}
+}
+
+// renameEdit returns the edit replacing the ID with the new name, in the same quoting style as the ID; a plain ID is
+// replaced with a quoted name where YAML requires it, such as a name containing ": " or starting with a quote
+func (sym symbol) renameEdit(newName string) textEdit {
+	quoted := sym.style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+	b, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Style: sym.style & (yaml.DoubleQuotedStyle |
+		yaml.SingleQuotedStyle), Value: newName})
+	text := strings.TrimSuffix(string(b), "\n")
+	if err != nil || strings.Contains(text, "\n") {
+		b, _ = yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: newName})
+		text = strings.TrimSuffix(string(b), "\n")
+	}
+	if !quoted && text == newName {
+		return textEdit{Range: sym.Range, NewText: newName}
+	}
+
+	// replace any quotes as well as the ID text
+	r := sym.Range
+	if quoted {
+		r.Start.Character--
+		r.End.Character++
+	}
+	return textEdit{Range: r, NewText: text}
+}
+
+func indexReferences(n *yaml.Node) (symbols []symbol) {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, ref := range n.Content {
+		class, id := mappingValue(ref, keyClass), mappingValue(ref, keyID)
+		if class == nil || id == nil || class.Kind != yaml.ScalarNode || id.Kind != yaml.ScalarNode {
+			continue
+		}
+		symbols = append(symbols, symbol{kind: symbolReference, Class: class.Value, ID: id.Value,
+			Range: scalarRange(id), style: id.Style})
+	}
+	return symbols
+}
+
+// indexSpecification returns the definitions and references within a specification node and its sub-definitions
+func indexSpecification(n *yaml.Node) (symbols []symbol) {
+	classNode := mappingValue(n, keyClass)
+	if classNode == nil || classNode.Kind != yaml.ScalarNode {
+		return nil
+	}
+	class := classNode.Value
+	symbols = append(symbols, indexReferences(mappingValue(n, keyReferences))...)
+
+	definitions := mappingValue(n, keyDefinitions)
+	if definitions == nil || definitions.Kind != yaml.MappingNode {
+		return symbols
+	}
+	for i := 0; i+1 < len(definitions.Content); i += 2 {
+		idNode, dfnNode := definitions.Content[i], definitions.Content[i+1]
+		dfn := symbol{kind: symbolDefinition, Class: class, ID: idNode.Value, Range: scalarRange(idNode),
+			style: idNode.Style}
+		symbols = append(symbols, dfn)
+		symbols = append(symbols, indexReferences(mappingValue(dfnNode, keyReferences))...)
+
+		subDefinitions := mappingValue(dfnNode, keySubDefinitions)
+		if subDefinitions == nil || subDefinitions.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 1; j < len(subDefinitions.Content); j += 2 {
+			symbols = append(symbols, indexSpecification(subDefinitions.Content[j])...)
+		}
+	}
+
+	return symbols
+}
+
+// symbolAt returns the symbol whose range contains the position
+func (doc *document) symbolAt(pos position) *symbol {
+	for i, s := range doc.symbols {
+		if s.Range.Start.Line == pos.Line && s.Range.Start.Character <= pos.Character &&
+			pos.Character <= s.Range.End.Character {
+			return &doc.symbols[i]
+		}
+	}
+	return nil
+}
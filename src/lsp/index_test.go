@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package lsp
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func rangeOf(line, start, end int) textRange {
+	return textRange{Start: position{Line: line, Character: start}, End: position{Line: line, Character: end}}
+}
+
+func Test_newDocument(t *testing.T) {
+	text, err := os.ReadFile("_test/Workspace/service.yaml")
+	assert.Nil(t, err)
+
+	doc := newDocument("file:///service.yaml", "_test/Workspace/service.yaml", string(text), nil)
+	assert.Nil(t, doc.err)
+	assert.NotNil(t, doc.spec)
+	assert.Equal(t, []symbol{
+		{kind: symbolDefinition, Class: "Service", ID: "vm", Range: rangeOf(2, 2, 4)},
+		{kind: symbolReference, Class: "Provider", ID: "azure", Range: rangeOf(7, 12, 17)},
+		{kind: symbolDefinition, Class: "Service", ID: "disk", Range: rangeOf(9, 2, 6)},
+		{kind: symbolReference, Class: "Provider", ID: "azure", Range: rangeOf(14, 13, 18),
+			style: yaml.DoubleQuotedStyle},
+		{kind: symbolReference, Class: "Provider", ID: "oracle", Range: rangeOf(17, 12, 18)},
+	}, doc.symbols)
+
+	assert.Equal(t, "disk", doc.symbolAt(position{Line: 9, Character: 6}).ID)
+	assert.Nil(t, doc.symbolAt(position{Line: 9, Character: 7}))
+
+	t.Run("SubDefinitions", func(t *testing.T) {
+		doc := newDocument("file:///provider.yaml", "provider.yaml", `Class: Provider
+References:
+  - {Class: Category, ID: compute, Relationship: IS_A}
+Definitions:
+  azure:
+    SubDefinitions:
+      PROVIDED_BY:
+        Class: Service
+        Definitions:
+          'vm':
+            Fields:
+              Name: VM
+`, nil)
+		assert.Nil(t, doc.err)
+		assert.Equal(t, []symbol{
+			{kind: symbolReference, Class: "Category", ID: "compute", Range: rangeOf(2, 26, 33)},
+			{kind: symbolDefinition, Class: "Provider", ID: "azure", Range: rangeOf(4, 2, 7)},
+			{kind: symbolDefinition, Class: "Service", ID: "vm", Range: rangeOf(9, 11, 13),
+				style: yaml.SingleQuotedStyle},
+		}, doc.symbols)
+	})
+
+	t.Run("InvalidYAML", func(t *testing.T) {
+		invalid := newDocument("file:///service.yaml", "service.yaml", "Class: Service\nDefinitions: [\n", doc)
+		assert.NotNil(t, invalid.err)
+		assert.Nil(t, invalid.spec)
+		assert.Equal(t, doc.symbols, invalid.symbols)
+		assert.Equal(t, 1, invalid.errorRange().Start.Line)
+	})
+
+	t.Run("InvalidSpecification", func(t *testing.T) {
+		invalid := newDocument("file:///service.yaml", "service.yaml", "Class: [Service]\nDefinitions:\n  vm:\n", nil)
+		assert.NotNil(t, invalid.err)
+	})
+
+	t.Run("NoDefinitions", func(t *testing.T) {
+		empty := newDocument("file:///format.yaml", "format.yaml", "Class:\n  Service:\n    MandatoryFields:\n", nil)
+		assert.Nil(t, empty.err)
+		assert.Nil(t, empty.spec)
+		assert.Empty(t, empty.symbols)
+	})
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	jsonRPCVersion = "2.0"
+
+	headerContentLength = "Content-Length"
+	headerFormat        = "Content-Length: %d\r\n\r\n"
+
+	// JSON-RPC error codes
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+	codeRequestFailed  = -32803
+
+	// LSP enum values
+	textDocumentSyncFull = 1
+
+	diagnosticSeverityError       = 1
+	diagnosticSeverityWarning     = 2
+	diagnosticSeverityInformation = 3
+
+	completionItemKindClass     = 7
+	completionItemKindReference = 18
+
+	fileScheme = "file"
+
+	errorMissingContentLength = "missing Content-Length header"
+	errorInvalidHeader        = "invalid header [%s]"
+)
+
+type (
+	// message is an incoming request or notification; notifications have no ID
+	message struct {
+		JSONRPC string           `json:"jsonrpc"`
+		ID      *json.RawMessage `json:"id,omitempty"`
+		Method  string           `json:"method"`
+		Params  json.RawMessage  `json:"params,omitempty"`
+	}
+
+	response struct {
+		JSONRPC string           `json:"jsonrpc"`
+		ID      *json.RawMessage `json:"id"`
+		Result  interface{}      `json:"result"`
+	}
+
+	errorResponse struct {
+		JSONRPC string           `json:"jsonrpc"`
+		ID      *json.RawMessage `json:"id"`
+		Error   responseError    `json:"error"`
+	}
+
+	responseError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	notification struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}
+
+	position struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+
+	textRange struct {
+		Start position `json:"start"`
+		End   position `json:"end"`
+	}
+
+	location struct {
+		URI   string    `json:"uri"`
+		Range textRange `json:"range"`
+	}
+
+	textDocumentIdentifier struct {
+		URI string `json:"uri"`
+	}
+
+	textDocumentItem struct {
+		URI        string `json:"uri"`
+		LanguageID string `json:"languageId"`
+		Version    int    `json:"version"`
+		Text       string `json:"text"`
+	}
+
+	textDocumentPositionParams struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+		Position     position               `json:"position"`
+	}
+
+	referenceParams struct {
+		textDocumentPositionParams
+		Context struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}
+
+	renameParams struct {
+		textDocumentPositionParams
+		NewName string `json:"newName"`
+	}
+
+	initializeParams struct {
+		RootURI  string `json:"rootUri"`
+		RootPath string `json:"rootPath"`
+	}
+
+	didOpenTextDocumentParams struct {
+		TextDocument textDocumentItem `json:"textDocument"`
+	}
+
+	didChangeTextDocumentParams struct {
+		TextDocument   textDocumentIdentifier `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+
+	didCloseTextDocumentParams struct {
+		TextDocument textDocumentIdentifier `json:"textDocument"`
+	}
+
+	serverCapabilities struct {
+		TextDocumentSync   int                `json:"textDocumentSync"`
+		DefinitionProvider bool               `json:"definitionProvider"`
+		ReferencesProvider bool               `json:"referencesProvider"`
+		RenameProvider     bool               `json:"renameProvider"`
+		CompletionProvider *completionOptions `json:"completionProvider,omitempty"`
+	}
+
+	completionOptions struct {
+		TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+	}
+
+	serverInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+
+	initializeResult struct {
+		Capabilities serverCapabilities `json:"capabilities"`
+		ServerInfo   serverInfo         `json:"serverInfo"`
+	}
+
+	diagnostic struct {
+		Range    textRange `json:"range"`
+		Severity int       `json:"severity"`
+		Code     string    `json:"code,omitempty"`
+		Source   string    `json:"source"`
+		Message  string    `json:"message"`
+	}
+
+	publishDiagnosticsParams struct {
+		URI         string       `json:"uri"`
+		Diagnostics []diagnostic `json:"diagnostics"`
+	}
+
+	completionItem struct {
+		Label  string `json:"label"`
+		Kind   int    `json:"kind"`
+		Detail string `json:"detail,omitempty"`
+	}
+
+	textEdit struct {
+		Range   textRange `json:"range"`
+		NewText string    `json:"newText"`
+	}
+
+	workspaceEdit struct {
+		Changes map[string][]textEdit `json:"changes"`
+	}
+)
+
+// readMessage reads a single message, consisting of headers followed by a JSON body of Content-Length bytes
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	contentLength := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf(errorInvalidHeader, line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), headerContentLength) {
+			if contentLength, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf(errorInvalidHeader, line)
+			}
+		}
+	}
+
+	if contentLength < 0 {
+		return nil, fmt.Errorf(errorMissingContentLength)
+	}
+
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage writes the value as a JSON body preceded by its Content-Length header
+func writeMessage(w io.Writer, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, headerFormat, len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// uriToPath converts a file URI to a file path
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != fileScheme {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI converts a file path to a file URI
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: fileScheme, Path: filepath.ToSlash(path)}).String()
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Nil(t, writeMessage(&buf, map[string]string{"jsonrpc": "2.0", "method": "exit"}))
+	assert.Equal(t, "Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}", buf.String())
+
+	body, err := readMessage(bufio.NewReader(&buf))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","method":"exit"}`, string(body))
+
+	t.Run("AdditionalHeaders", func(t *testing.T) {
+		body, err := readMessage(bufio.NewReader(strings.NewReader(
+			"Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 2\r\n\r\n{}")))
+		assert.Nil(t, err)
+		assert.Equal(t, "{}", string(body))
+	})
+
+	t.Run("MissingContentLength", func(t *testing.T) {
+		_, err := readMessage(bufio.NewReader(strings.NewReader("Content-Type: x\r\n\r\n{}")))
+		assert.NotNil(t, err)
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		_, err := readMessage(bufio.NewReader(strings.NewReader("Content-Length 2\r\n\r\n{}")))
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_uriToPath(t *testing.T) {
+	uri := pathToURI("/tmp/my definitions/service.yaml")
+	assert.Equal(t, "file:///tmp/my%20definitions/service.yaml", uri)
+	assert.Equal(t, "/tmp/my definitions/service.yaml", uriToPath(uri))
+	assert.Equal(t, "untitled:1", uriToPath("untitled:1"))
+}
@@ -0,0 +1,586 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package lsp implements a language server for definition files, speaking the Language Server Protocol over a pair
+// of streams such as stdin and stdout.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	serverName = "yaml-graph"
+
+	methodInitialize         = "initialize"
+	methodInitialized        = "initialized"
+	methodShutdown           = "shutdown"
+	methodExit               = "exit"
+	methodDidOpen            = "textDocument/didOpen"
+	methodDidChange          = "textDocument/didChange"
+	methodDidClose           = "textDocument/didClose"
+	methodDefinition         = "textDocument/definition"
+	methodReferences         = "textDocument/references"
+	methodCompletion         = "textDocument/completion"
+	methodRename             = "textDocument/rename"
+	methodPublishDiagnostics = "textDocument/publishDiagnostics"
+
+	logDebugReceivedMessage    = "received [%s]"
+	logWarnInvalidMessage      = "invalid message"
+	logWarnCannotLoadFile      = "cannot load file [%s]"
+	logErrorCannotSendMessage  = "cannot send message"
+	logErrorCannotHandle       = "cannot handle [%s]: %v"
+	errorMethodNotFound        = "method [%s] not supported"
+	errorEmptyNewName          = "new name cannot be empty"
+	errorNoSymbolAtPosition    = "no definition or reference at position"
+	errorDefinitionExists      = "definition ID [%s] for class [%s] already exists"
+	errorServerNotShutDown     = "exit received before shutdown"
+	errorCannotHandle          = "cannot handle [%s]"
+	diagnosticCannotParseFile  = "cannot parse file: %s"
+	completionTriggerCharacter = " "
+)
+
+var (
+	completionID    = regexp.MustCompile(`^\s*(?:-\s+)?ID:\s*["']?[^"']*$`)
+	completionClass = regexp.MustCompile(`^\s*(?:-\s+)?Class:\s*["']?[^"']*$`)
+	classLine       = regexp.MustCompile(`^\s*(?:-\s+)?Class:\s*["']?([^"'\s#]+)`)
+)
+
+type (
+	// Server is a language server for the definition files within one or more source directories
+	Server struct {
+		in  *bufio.Reader
+		out io.Writer
+
+		sourceDirs    []string
+		fileExtension string
+		df            *parser.DefinitionFormat
+
+		// documents holds every definition file, keyed by URI, using the editor's content for any open files
+		documents map[string]*document
+
+		shutdown bool
+	}
+)
+
+// NewServer creates a Server reading requests from in and writing responses to out; if no source directories are
+// provided then the root of the editor's workspace is used. The DefinitionFormat is optional.
+func NewServer(in io.Reader, out io.Writer, sourceDirs []string, fileExtension string,
+	df *parser.DefinitionFormat) *Server {
+	return &Server{
+		in:            bufio.NewReader(in),
+		out:           out,
+		sourceDirs:    sourceDirs,
+		fileExtension: fileExtension,
+		df:            df,
+		documents:     map[string]*document{},
+	}
+}
+
+// Run handles messages until the exit notification is received or the input is closed
+func (s *Server) Run() error {
+	for {
+		body, err := readMessage(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var msg message
+		if err = json.Unmarshal(body, &msg); err != nil {
+			log.Warn().Err(err).Msg(logWarnInvalidMessage)
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		log.Debug().Msgf(logDebugReceivedMessage, msg.Method)
+
+		if msg.Method == methodExit {
+			if !s.shutdown {
+				return errors.New(errorServerNotShutDown)
+			}
+			return nil
+		}
+
+		result, respErr := s.safeHandle(msg)
+		if msg.ID != nil {
+			s.reply(msg.ID, result, respErr)
+		}
+	}
+}
+
+func (s *Server) send(v interface{}) {
+	if err := writeMessage(s.out, v); err != nil {
+		log.Error().Err(err).Msg(logErrorCannotSendMessage)
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, respErr *responseError) {
+	if respErr != nil {
+		s.send(errorResponse{JSONRPC: jsonRPCVersion, ID: id, Error: *respErr})
+		return
+	}
+	s.send(response{JSONRPC: jsonRPCVersion, ID: id, Result: result})
+}
+
+// safeHandle handles a message, recovering from any panic so that one bad request cannot stop the server
+func (s *Server) safeHandle(msg message) (result interface{}, respErr *responseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf(logErrorCannotHandle, msg.Method, r)
+			result, respErr = nil, &responseError{Code: codeInternalError, Message: fmt.Sprintf(errorCannotHandle, msg.Method)}
+		}
+	}()
+	return s.handle(msg)
+}
+
+func (s *Server) handle(msg message) (interface{}, *responseError) {
+	unmarshal := func(v interface{}) *responseError {
+		if err := json.Unmarshal(msg.Params, v); err != nil {
+			return &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch msg.Method {
+	case methodInitialize:
+		var params initializeParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params), nil
+
+	case methodInitialized:
+		s.publishDiagnostics()
+
+	case methodShutdown:
+		s.shutdown = true
+
+	case methodDidOpen:
+		var params didOpenTextDocumentParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		s.setDocument(params.TextDocument.URI, params.TextDocument.Text)
+		s.publishDiagnostics()
+
+	case methodDidChange:
+		var params didChangeTextDocumentParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		// full synchronisation, so the last change holds the whole document
+		if n := len(params.ContentChanges); n > 0 {
+			s.setDocument(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+			s.publishDiagnostics()
+		}
+
+	case methodDidClose:
+		var params didCloseTextDocumentParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		// revert to the content on disk, which may differ if the changes were not saved
+		s.loadFile(uriToPath(params.TextDocument.URI))
+		s.publishDiagnostics()
+
+	case methodDefinition:
+		var params textDocumentPositionParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+
+	case methodReferences:
+		var params referenceParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		return s.references(params), nil
+
+	case methodCompletion:
+		var params textDocumentPositionParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+
+	case methodRename:
+		var params renameParams
+		if err := unmarshal(&params); err != nil {
+			return nil, err
+		}
+		return s.rename(params)
+
+	default:
+		if msg.ID != nil {
+			return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf(errorMethodNotFound, msg.Method)}
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Server) initialize(params initializeParams) initializeResult {
+	if len(s.sourceDirs) == 0 {
+		if params.RootURI != "" {
+			s.sourceDirs = []string{uriToPath(params.RootURI)}
+		} else if params.RootPath != "" {
+			s.sourceDirs = []string{params.RootPath}
+		}
+	}
+	s.loadWorkspace()
+
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:   textDocumentSyncFull,
+			DefinitionProvider: true,
+			ReferencesProvider: true,
+			RenameProvider:     true,
+			CompletionProvider: &completionOptions{TriggerCharacters: []string{completionTriggerCharacter}},
+		},
+		ServerInfo: serverInfo{Name: serverName},
+	}
+}
+
+func (s *Server) loadWorkspace() {
+	for _, dir := range s.sourceDirs {
+		definition.ProcessFiles(dir, s.fileExtension, func(filePath string, _ os.FileInfo) error {
+			s.loadFile(filePath)
+			return nil
+		})
+	}
+}
+
+func (s *Server) loadFile(path string) {
+	uri := pathToURI(path)
+	text, err := os.ReadFile(path)
+	if err != nil {
+		log.Warn().Err(err).Msgf(logWarnCannotLoadFile, path)
+		delete(s.documents, uri)
+		return
+	}
+	s.documents[uri] = newDocument(uri, path, string(text), s.documents[uri])
+}
+
+func (s *Server) setDocument(uri, text string) {
+	s.documents[uri] = newDocument(uri, uriToPath(uri), text, s.documents[uri])
+}
+
+func (s *Server) sortedURIs() []string {
+	uris := make([]string, 0, len(s.documents))
+	for uri := range s.documents {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	return uris
+}
+
+// publishDiagnostics validates every document together, then publishes the findings for each document, including
+// an empty list for those without findings so that any previous findings are cleared
+func (s *Server) publishDiagnostics() {
+	d := parser.Dictionary{}
+	diagnostics := map[string][]diagnostic{}
+	for _, uri := range s.sortedURIs() {
+		doc := s.documents[uri]
+		diagnostics[uri] = []diagnostic{}
+		if doc.err != nil {
+			diagnostics[uri] = append(diagnostics[uri], diagnostic{
+				Range:    doc.errorRange(),
+				Severity: diagnosticSeverityError,
+				Source:   serverName,
+				Message:  fmt.Sprintf(diagnosticCannotParseFile, doc.err),
+			})
+		}
+		if doc.spec != nil {
+			parser.AddSpecification(d, *doc.spec)
+		}
+	}
+
+	if s.df != nil {
+		// failures are also reported as findings of the rules which use the computed fields
+		parser.ComputeFields(d, s.df)
+	}
+
+	for _, f := range parser.Validate(d, s.df).Findings {
+		for _, loc := range s.definitionLocations(f.Class, f.ID) {
+			diagnostics[loc.URI] = append(diagnostics[loc.URI], diagnostic{
+				Range:    loc.Range,
+				Severity: diagnosticSeverity(f.Severity),
+				Code:     f.RuleID,
+				Source:   serverName,
+				Message:  f.Message,
+			})
+		}
+	}
+
+	for _, uri := range s.sortedURIs() {
+		s.send(notification{JSONRPC: jsonRPCVersion, Method: methodPublishDiagnostics,
+			Params: publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics[uri]}})
+	}
+}
+
+func diagnosticSeverity(severity parser.Severity) int {
+	switch severity {
+	case parser.SeverityWarning:
+		return diagnosticSeverityWarning
+	case parser.SeverityInfo:
+		return diagnosticSeverityInformation
+	}
+	return diagnosticSeverityError
+}
+
+// definitionLocations returns the locations of the definitions with the class and ID
+func (s *Server) definitionLocations(class, id string) (locations []location) {
+	if class == "" {
+		return nil
+	}
+	for _, uri := range s.sortedURIs() {
+		for _, sym := range s.documents[uri].symbols {
+			if sym.kind == symbolDefinition && sym.Class == class && sym.ID == id {
+				locations = append(locations, location{URI: uri, Range: sym.Range})
+			}
+		}
+	}
+	return locations
+}
+
+// target returns the symbol at the position, resolving any reference to the class of the definition it refers to,
+// which may be a subclass of the referenced class
+func (s *Server) target(params textDocumentPositionParams) (class, id string, found bool) {
+	doc := s.documents[params.TextDocument.URI]
+	if doc == nil {
+		return "", "", false
+	}
+	sym := doc.symbolAt(params.Position)
+	if sym == nil {
+		return "", "", false
+	}
+	if sym.kind == symbolDefinition {
+		return sym.Class, sym.ID, true
+	}
+
+	for _, c := range append([]string{sym.Class}, s.df.SubClasses(sym.Class)...) {
+		if len(s.definitionLocations(c, sym.ID)) > 0 {
+			return c, sym.ID, true
+		}
+	}
+	return sym.Class, sym.ID, true
+}
+
+// referenceLocations returns the locations of the references to the definition with the class and ID, including
+// references to any of the classes it extends
+func (s *Server) referenceLocations(class, id string) (locations []location) {
+	classes := append([]string{class}, s.df.BaseClasses(class)...)
+	for _, uri := range s.sortedURIs() {
+		for _, sym := range s.documents[uri].symbols {
+			if sym.kind == symbolReference && sym.ID == id && contains(classes, sym.Class) {
+				locations = append(locations, location{URI: uri, Range: sym.Range})
+			}
+		}
+	}
+	return locations
+}
+
+func (s *Server) definition(params textDocumentPositionParams) []location {
+	class, id, found := s.target(params)
+	if !found {
+		return []location{}
+	}
+	if locations := s.definitionLocations(class, id); locations != nil {
+		return locations
+	}
+	return []location{}
+}
+
+func (s *Server) references(params referenceParams) []location {
+	class, id, found := s.target(params.textDocumentPositionParams)
+	if !found {
+		return []location{}
+	}
+
+	locations := []location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, s.definitionLocations(class, id)...)
+	}
+	return append(locations, s.referenceLocations(class, id)...)
+}
+
+func (s *Server) rename(params renameParams) (*workspaceEdit, *responseError) {
+	newName := strings.TrimSpace(params.NewName)
+	if newName == "" {
+		return nil, &responseError{Code: codeInvalidParams, Message: errorEmptyNewName}
+	}
+	class, id, found := s.target(params.textDocumentPositionParams)
+	if !found {
+		return nil, &responseError{Code: codeRequestFailed, Message: errorNoSymbolAtPosition}
+	}
+
+	if newName != id && len(s.definitionLocations(class, newName)) > 0 {
+		return nil, &responseError{Code: codeRequestFailed, Message: fmt.Sprintf(errorDefinitionExists, newName, class)}
+	}
+
+	edit := &workspaceEdit{Changes: map[string][]textEdit{}}
+	for _, loc := range append(s.definitionLocations(class, id), s.referenceLocations(class, id)...) {
+		if sym := s.documents[loc.URI].symbolAt(loc.Range.Start); sym != nil {
+			edit.Changes[loc.URI] = append(edit.Changes[loc.URI], sym.renameEdit(newName))
+		}
+	}
+	return edit, nil
+}
+
+// keyColumn returns the column of the key on a line, ignoring any sequence indicator
+func keyColumn(line string) int {
+	trimmed := strings.TrimLeft(line, " ")
+	column := len(line) - len(trimmed)
+	if strings.HasPrefix(trimmed, "- ") {
+		column += len(trimmed) - len(strings.TrimLeft(trimmed[1:], " "))
+	}
+	return column
+}
+
+// siblingClass finds the Class key within the same mapping as the key on the line provided; the text is scanned
+// line by line, rather than parsed, as it is likely to be invalid whilst being edited
+func siblingClass(lines []string, line int) string {
+	column := keyColumn(lines[line])
+	isItemStart := func(l string) bool { return strings.HasPrefix(strings.TrimLeft(l, " "), "- ") }
+
+	// search up to the start of the mapping...
+	for l := line; l >= 0; l-- {
+		if strings.TrimSpace(lines[l]) == "" {
+			continue
+		}
+		if keyColumn(lines[l]) < column {
+			break
+		}
+		if keyColumn(lines[l]) == column {
+			if m := classLine.FindStringSubmatch(lines[l]); m != nil {
+				return m[1]
+			}
+			if isItemStart(lines[l]) {
+				break
+			}
+		}
+	}
+
+	// ...then down to the end of it
+	for l := line + 1; l < len(lines); l++ {
+		if strings.TrimSpace(lines[l]) == "" {
+			continue
+		}
+		if keyColumn(lines[l]) < column || isItemStart(lines[l]) {
+			break
+		}
+		if keyColumn(lines[l]) == column {
+			if m := classLine.FindStringSubmatch(lines[l]); m != nil {
+				return m[1]
+			}
+		}
+	}
+
+	return ""
+}
+
+// completion offers the IDs of the definitions of a class when completing the ID of a reference, and the known
+// classes when completing a Class
+func (s *Server) completion(params textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+	doc := s.documents[params.TextDocument.URI]
+	if doc == nil {
+		return items
+	}
+
+	lines := strings.Split(doc.text, "\n")
+	if params.Position.Line < 0 || params.Position.Line >= len(lines) || params.Position.Character < 0 {
+		return items
+	}
+	line := strings.TrimRight(lines[params.Position.Line], "\r")
+	prefix := line[:byteOffset(line, params.Position.Character)]
+
+	switch {
+	case completionID.MatchString(prefix):
+		class := siblingClass(lines, params.Position.Line)
+		if class == "" {
+			return items
+		}
+		seen := map[string]bool{}
+		for _, c := range append([]string{class}, s.df.SubClasses(class)...) {
+			for _, uri := range s.sortedURIs() {
+				for _, sym := range s.documents[uri].symbols {
+					if sym.kind == symbolDefinition && sym.Class == c && !seen[sym.ID] {
+						seen[sym.ID] = true
+						items = append(items, completionItem{Label: sym.ID, Kind: completionItemKindReference,
+							Detail: c})
+					}
+				}
+			}
+		}
+
+	case completionClass.MatchString(prefix):
+		for _, class := range s.classes() {
+			items = append(items, completionItem{Label: class, Kind: completionItemKindClass})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// classes returns the classes declared in the definition format, together with those used by any definition
+func (s *Server) classes() []string {
+	seen := map[string]bool{}
+	if s.df != nil {
+		for class := range s.df.ClassFormat {
+			seen[class] = true
+		}
+	}
+	for _, doc := range s.documents {
+		for _, sym := range doc.symbols {
+			if sym.kind == symbolDefinition {
+				seen[sym.Class] = true
+			}
+		}
+	}
+
+	classes := make([]string, 0, len(seen))
+	for class := range seen {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+func contains(a []string, x string) bool {
+	for _, n := range a {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}
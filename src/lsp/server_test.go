@@ -0,0 +1,289 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T, df *parser.DefinitionFormat) (*Server, *bytes.Buffer) {
+	var out bytes.Buffer
+	s := NewServer(strings.NewReader(""), &out, []string{"_test/Workspace"}, "yaml", df)
+	s.initialize(initializeParams{})
+	assert.Len(t, s.documents, 2)
+	return s, &out
+}
+
+func at(uri string, line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+// readDiagnostics returns the diagnostics published, keyed by the base name of the file
+func readDiagnostics(t *testing.T, out *bytes.Buffer) map[string][]diagnostic {
+	diagnostics := map[string][]diagnostic{}
+	r := bufio.NewReader(out)
+	for {
+		body, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		var n struct {
+			Method string                   `json:"method"`
+			Params publishDiagnosticsParams `json:"params"`
+		}
+		assert.Nil(t, json.Unmarshal(body, &n))
+		if n.Method == methodPublishDiagnostics {
+			diagnostics[n.Params.URI[strings.LastIndex(n.Params.URI, "/")+1:]] = n.Params.Diagnostics
+		}
+	}
+	return diagnostics
+}
+
+func Test_definitionAndReferences(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	provider, service := pathToURI("_test/Workspace/provider.yaml"), pathToURI("_test/Workspace/service.yaml")
+
+	azure := location{URI: provider, Range: rangeOf(2, 2, 7)}
+	azureRefs := []location{
+		{URI: service, Range: rangeOf(7, 12, 17)},
+		{URI: service, Range: rangeOf(14, 13, 18)},
+	}
+
+	assert.Equal(t, []location{azure}, s.definition(at(service, 7, 14)))
+	assert.Equal(t, []location{azure}, s.definition(at(provider, 2, 3)))
+	assert.Equal(t, []location{}, s.definition(at(service, 17, 14)))
+	assert.Equal(t, []location{}, s.definition(at(service, 0, 0)))
+
+	assert.Equal(t, azureRefs, s.references(referenceParams{textDocumentPositionParams: at(provider, 2, 3)}))
+	refs := referenceParams{textDocumentPositionParams: at(service, 14, 13)}
+	refs.Context.IncludeDeclaration = true
+	assert.Equal(t, append([]location{azure}, azureRefs...), s.references(refs))
+}
+
+func Test_inheritedReferences(t *testing.T) {
+	df := &parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{
+		"Organisation": {Abstract: true},
+		"Provider":     {Extends: "Organisation"},
+	}}
+	s, _ := newTestServer(t, df)
+	provider, service := pathToURI("_test/Workspace/provider.yaml"), pathToURI("_test/Workspace/service.yaml")
+	s.setDocument(service, strings.Replace(s.documents[service].text, "ID: oracle", "ID: aws", 1))
+	s.setDocument(service, strings.Replace(s.documents[service].text, "- Class: Provider\n        ID: aws",
+		"- Class: Organisation\n        ID: aws", 1))
+
+	// a reference to a base class resolves to the definition of the subclass
+	assert.Equal(t, []location{{URI: provider, Range: rangeOf(5, 3, 6)}}, s.definition(at(service, 17, 13)))
+	assert.Equal(t, []location{{URI: service, Range: rangeOf(17, 12, 15)}},
+		s.references(referenceParams{textDocumentPositionParams: at(provider, 5, 4)}))
+}
+
+func Test_rename(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	provider, service := pathToURI("_test/Workspace/provider.yaml"), pathToURI("_test/Workspace/service.yaml")
+
+	edit, err := s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: "microsoft-azure"})
+	assert.Nil(t, err)
+	assert.Equal(t, &workspaceEdit{Changes: map[string][]textEdit{
+		provider: {{Range: rangeOf(2, 2, 7), NewText: "microsoft-azure"}},
+		service: {
+			{Range: rangeOf(7, 12, 17), NewText: "microsoft-azure"},
+			{Range: rangeOf(14, 12, 19), NewText: `"microsoft-azure"`},
+		},
+	}}, edit)
+
+	// names are quoted where YAML requires it
+	edit, err = s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: "azure: cloud"})
+	assert.Nil(t, err)
+	assert.Equal(t, []textEdit{{Range: rangeOf(2, 2, 7), NewText: "'azure: cloud'"}}, edit.Changes[provider])
+	assert.Equal(t, textEdit{Range: rangeOf(14, 12, 19), NewText: `"azure: cloud"`}, edit.Changes[service][1])
+	edit, err = s.rename(renameParams{textDocumentPositionParams: at(provider, 5, 4), NewName: "#amazon"})
+	assert.Nil(t, err)
+	assert.Equal(t, []textEdit{{Range: rangeOf(5, 2, 7), NewText: `"#amazon"`}}, edit.Changes[provider])
+	edit, err = s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: "'azure"})
+	assert.Nil(t, err)
+	assert.Equal(t, []textEdit{{Range: rangeOf(2, 2, 7), NewText: "'''azure'"}}, edit.Changes[provider])
+
+	// the new name cannot be that of another definition of the class
+	_, err = s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: "aws"})
+	assert.Equal(t, codeRequestFailed, err.Code)
+	assert.Equal(t, "definition ID [aws] for class [Provider] already exists", err.Message)
+	_, err = s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: "azure"})
+	assert.Nil(t, err)
+
+	_, err = s.rename(renameParams{textDocumentPositionParams: at(service, 7, 12), NewName: " "})
+	assert.Equal(t, codeInvalidParams, err.Code)
+	_, err = s.rename(renameParams{textDocumentPositionParams: at(service, 0, 0), NewName: "x"})
+	assert.Equal(t, codeRequestFailed, err.Code)
+}
+
+func Test_completion(t *testing.T) {
+	df := &parser.DefinitionFormat{ClassFormat: map[string]*parser.ClassDefinitionFormat{"Category": {}}}
+	s, _ := newTestServer(t, df)
+	service := pathToURI("_test/Workspace/service.yaml")
+
+	labels := func(items []completionItem) (l []string) {
+		for _, i := range items {
+			l = append(l, i.Label)
+		}
+		return l
+	}
+
+	// ID with the Class above
+	assert.Equal(t, []string{"aws", "azure"}, labels(s.completion(at(service, 7, 12))))
+	// ID with the Class below
+	assert.Equal(t, []string{"aws", "azure"}, labels(s.completion(at(service, 14, 13))))
+	assert.Equal(t, []string{"Category", "Provider", "Service"}, labels(s.completion(at(service, 6, 15))))
+	assert.Empty(t, s.completion(at(service, 4, 6)))
+
+	t.Run("IncompleteDocument", func(t *testing.T) {
+		s.setDocument(service, "Class: Service\nDefinitions:\n  vm:\n    References:\n      - Class: Provider\n        ID: \n")
+		assert.Equal(t, []string{"aws", "azure"}, labels(s.completion(at(service, 5, 12))))
+
+		s.setDocument(service, "Class: Service\nDefinitions:\n  vm:\n    References:\n      - ID: a\n      - Class: Provider\n")
+		assert.Empty(t, s.completion(at(service, 4, 13)))
+	})
+
+	t.Run("InvalidPosition", func(t *testing.T) {
+		s.setDocument(service, "Class: Service\n")
+		assert.Empty(t, s.completion(at(service, -1, 0)))
+		assert.Empty(t, s.completion(at(service, 0, -1)))
+		assert.Empty(t, s.completion(at(service, 2, 0)))
+		assert.Equal(t, []string{"Category", "Provider"}, labels(s.completion(at(service, 0, 100))))
+	})
+
+	t.Run("UTF16Position", func(t *testing.T) {
+		// the emoji is two UTF-16 code units but four bytes, so character 11 is after the closing quote
+		s.setDocument(service, "Class: '😀'\n")
+		assert.Equal(t, []string{"Category", "Provider"}, labels(s.completion(at(service, 0, 10))))
+		assert.Empty(t, s.completion(at(service, 0, 11)))
+	})
+}
+
+func Test_byteOffset(t *testing.T) {
+	assert.Equal(t, 0, byteOffset("ID: a", 0))
+	assert.Equal(t, 3, byteOffset("ID: a", 3))
+	assert.Equal(t, 5, byteOffset("ID: a", 10))
+	assert.Equal(t, 3, byteOffset("é: a", 2))
+	assert.Equal(t, 4, byteOffset("😀 a", 2))
+	assert.Equal(t, 5, byteOffset("😀 a", 3))
+}
+
+func Test_publishDiagnostics(t *testing.T) {
+	df := &parser.DefinitionFormat{
+		ClassFormat: map[string]*parser.ClassDefinitionFormat{
+			"Provider": {MandatoryFields: map[string]parser.ClassField{"Name": {}}},
+			"Service":  {MandatoryFields: map[string]parser.ClassField{"Name": {}}},
+		},
+		Severities: map[string]parser.Severity{parser.RuleUnknownDefinition: parser.SeverityWarning},
+	}
+	s, out := newTestServer(t, df)
+	service := pathToURI("_test/Workspace/service.yaml")
+
+	s.publishDiagnostics()
+	diagnostics := readDiagnostics(t, out)
+	assert.Equal(t, []diagnostic{}, diagnostics["provider.yaml"])
+	assert.Equal(t, []diagnostic{{
+		Range:    rangeOf(9, 2, 6),
+		Severity: diagnosticSeverityWarning,
+		Code:     parser.RuleUnknownDefinition,
+		Source:   serverName,
+		Message:  "cannot find definition ID [oracle] for class [Provider]",
+	}}, diagnostics["service.yaml"])
+
+	s.setDocument(service, "Class: Service\nDefinitions: [\n")
+	s.publishDiagnostics()
+	diagnostics = readDiagnostics(t, out)
+	assert.Len(t, diagnostics["service.yaml"], 1)
+	assert.Equal(t, diagnosticSeverityError, diagnostics["service.yaml"][0].Severity)
+}
+
+func Test_Run(t *testing.T) {
+	var in, out bytes.Buffer
+	send := func(id int, method, params string) {
+		idField := ""
+		if id > 0 {
+			idField = fmt.Sprintf(`"id":%d,`, id)
+		}
+		body := fmt.Sprintf(`{"jsonrpc":"2.0",%s"method":"%s","params":%s}`, idField, method, params)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	send(1, methodInitialize, `{"rootUri":"`+pathToURI("_test/Workspace")+`"}`)
+	send(0, methodInitialized, `{}`)
+	send(2, methodDefinition, `{"textDocument":{"uri":"`+pathToURI("_test/Workspace/service.yaml")+
+		`"},"position":{"line":7,"character":12}}`)
+	send(3, "workspace/symbol", `{}`)
+	send(4, methodShutdown, `null`)
+	send(0, methodExit, `null`)
+
+	s := NewServer(&in, &out, nil, "yaml", nil)
+	assert.Nil(t, s.Run())
+
+	var responses []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		var m map[string]interface{}
+		assert.Nil(t, json.Unmarshal(body, &m))
+		if m["id"] != nil {
+			responses = append(responses, m)
+		}
+	}
+
+	assert.Len(t, responses, 4)
+	assert.Equal(t, true, responses[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})["definitionProvider"])
+	assert.Len(t, responses[1]["result"], 1)
+	assert.Equal(t, float64(codeMethodNotFound), responses[2]["error"].(map[string]interface{})["code"])
+	assert.Contains(t, responses[3], "result")
+	assert.Nil(t, responses[3]["result"])
+
+	t.Run("Panic", func(t *testing.T) {
+		s, _ := newTestServer(t, nil)
+		service := pathToURI("_test/Workspace/service.yaml")
+		params := `{"textDocument":{"uri":"` + service + `"},"position":{"line":7,"character":12}}`
+		s.documents["file:///broken.yaml"] = nil
+
+		result, err := s.safeHandle(message{Method: methodDefinition, Params: json.RawMessage(params)})
+		assert.Nil(t, result)
+		assert.Equal(t, codeInternalError, err.Code)
+
+		delete(s.documents, "file:///broken.yaml")
+		result, err = s.safeHandle(message{Method: methodDefinition, Params: json.RawMessage(params)})
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+	})
+
+	t.Run("ExitWithoutShutdown", func(t *testing.T) {
+		in.Reset()
+		send(0, methodExit, `null`)
+		assert.NotNil(t, NewServer(&in, &out, nil, "yaml", nil).Run())
+	})
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	logWarnInvalidComputedField = "cannot compute field [%s] in definition ID [%s] for class [%s]: %s"
+)
+
+// ComputeFields evaluates the ComputedFields of each class in the DefinitionFormat against every definition of that
+// class, storing the results in the Fields of the definitions within the Dictionary
+func ComputeFields(d Dictionary, df *DefinitionFormat) error {
+	errorsFound := 0
+	if df == nil {
+		return nil
+	}
+
+	ctx := newDictionaryContext(d, df)
+	for _, class := range sortedClassFormats(df) {
+		if df.ClassFormat[class] == nil {
+			continue
+		}
+		computedFields := df.ClassFormat[class].ComputedFields
+		names := make([]string, 0, len(computedFields))
+		for name := range computedFields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, id := range sortedIDs(d[class]) {
+			for _, name := range names {
+				if _, err := ctx.field(DefinitionKey{Class: class, ID: id}, name); err != nil {
+					log.Warn().Msgf(logWarnInvalidComputedField, name, id, class, err)
+					errorsFound++
+				}
+			}
+		}
+	}
+
+	if errorsFound > 0 {
+		log.Error().Msg(fmt.Sprintf(errorDefinitionErrorsFound, errorsFound))
+		return fmt.Errorf(errorDefinitionErrorsFound, errorsFound)
+	}
+
+	return nil
+}
+
+// ApplyComputedFields copies the computed fields held in the Dictionary onto the definitions within the
+// Specification (and any sub-definitions), so that they are created alongside the other fields when loaded
+func ApplyComputedFields(spec *definition.Specification, d Dictionary, df *DefinitionFormat) {
+	if df == nil || df.ClassFormat[spec.Class] == nil {
+		applyComputedSubDefinitionFields(spec, d, df)
+		return
+	}
+
+	for dfnID, dfn := range spec.Definitions {
+		if d[spec.Class] == nil || d[spec.Class][dfnID] == nil {
+			continue
+		}
+		for name := range df.ClassFormat[spec.Class].ComputedFields {
+			if v, ok := d[spec.Class][dfnID].Fields[name]; ok {
+				if dfn.Fields == nil {
+					dfn.Fields = definition.Fields{}
+				}
+				dfn.Fields[name] = v
+			}
+		}
+		spec.Definitions[dfnID] = dfn
+	}
+
+	applyComputedSubDefinitionFields(spec, d, df)
+}
+
+func applyComputedSubDefinitionFields(spec *definition.Specification, d Dictionary, df *DefinitionFormat) {
+	for _, dfn := range spec.Definitions {
+		for relationship, subSpec := range dfn.SubDefinitions {
+			ApplyComputedFields(&subSpec, d, df)
+			dfn.SubDefinitions[relationship] = subSpec
+		}
+	}
+}
+
+func sortedClassFormats(df *DefinitionFormat) []string {
+	classes := make([]string, 0, len(df.ClassFormat))
+	for class := range df.ClassFormat {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ComputeFields(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		d := LoadDictionary([]string{"_test/computeFields"}, "yaml")
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"Service": {ComputedFields: map[string]ComputedField{
+				"Slug":          {Expression: `slug(default(Name, ID))`},
+				"Title":         {Expression: `refs("PROVIDED_BY")[0].Name + " " + default(Name, ID)`},
+				"CategoryCount": {Expression: `size(refs("TYPE_OF"))`},
+			}},
+			"Provider": {ComputedFields: map[string]ComputedField{
+				"ServiceSlugs": {Expression: `incoming("PROVIDED_BY").map(s, s.Slug)`},
+			}},
+			"Category": nil,
+		}}
+
+		assert.Nil(t, ComputeFields(d, df))
+		assert.Equal(t, definition.Fields{
+			"Name":          "Virtual Machine",
+			"Slug":          "virtual-machine",
+			"Title":         "Azure Virtual Machine",
+			"CategoryCount": 1,
+		}, d["Service"]["virtual-machine"].Fields)
+		assert.Equal(t, definition.Fields{
+			"Slug":          "app-service",
+			"Title":         "Azure app-service",
+			"CategoryCount": 0,
+		}, d["Service"]["app-service"].Fields)
+		assert.Equal(t, definition.Fields{
+			"Name":         "Azure",
+			"ServiceSlugs": []interface{}{"app-service", "virtual-machine"},
+		}, d["Provider"]["azure"].Fields)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		d := LoadDictionary([]string{"_test/computeFields"}, "yaml")
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"Service": {ComputedFields: map[string]ComputedField{
+				"Broken": {Expression: `Name +`},
+			}},
+			"Provider": {ComputedFields: map[string]ComputedField{
+				"Cycle": {Expression: `Cycle + 1`},
+			}},
+		}}
+
+		assert.NotNil(t, ComputeFields(d, df))
+		assert.Nil(t, d["Service"]["virtual-machine"].Fields["Broken"])
+		assert.Nil(t, d["Provider"]["azure"].Fields["Cycle"])
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		d := LoadDictionary([]string{"_test/computeFields"}, "yaml")
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"Service": {ComputedFields: map[string]ComputedField{
+				"Providers":     {Expression: `refs("PROVIDED_BY")`},
+				"ProviderNames": {Expression: `refs("PROVIDED_BY").map(p, p.Name)`},
+			}},
+		}}
+
+		_, err := newDictionaryContext(d, df).field(DefinitionKey{Class: "Service", ID: "app-service"}, "Providers")
+		assert.EqualError(t, err, "computed field [Providers] in definition ID [app-service] for class [Service] "+
+			"must be a string, number, boolean or a list of them, not [[]interface {}]")
+		assert.NotNil(t, ComputeFields(d, df))
+		assert.Nil(t, d["Service"]["virtual-machine"].Fields["Providers"])
+		assert.Equal(t, []interface{}{"Azure"}, d["Service"]["virtual-machine"].Fields["ProviderNames"])
+	})
+
+	t.Run("MutualReferences", func(t *testing.T) {
+		// the result must not depend on the order in which the classes are computed
+		for _, providerClass := range []string{"Provider", "ZProvider"} {
+			d := Dictionary{
+				providerClass: {"azure": {Fields: definition.Fields{"Name": "Azure"}}},
+				"Service": {"vm": {
+					Fields:     definition.Fields{"Name": "VM"},
+					References: []definition.Reference{{Class: providerClass, ID: "azure", Relationship: "PROVIDED_BY"}},
+				}},
+			}
+			df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+				"Service": {ComputedFields: map[string]ComputedField{
+					"Title": {Expression: `refs("PROVIDED_BY")[0].Name + " " + Name`},
+				}},
+				providerClass: {ComputedFields: map[string]ComputedField{
+					"Titles": {Expression: `incoming("PROVIDED_BY").map(s, s.Title)`},
+				}},
+			}}
+
+			assert.Nil(t, ComputeFields(d, df), providerClass)
+			assert.Equal(t, "Azure VM", d["Service"]["vm"].Fields["Title"], providerClass)
+			assert.Equal(t, []interface{}{"Azure VM"}, d[providerClass]["azure"].Fields["Titles"], providerClass)
+		}
+	})
+
+	t.Run("MutualCycle", func(t *testing.T) {
+		d := Dictionary{
+			"Provider": {"azure": {}},
+			"Service": {"vm": {
+				References: []definition.Reference{{Class: "Provider", ID: "azure", Relationship: "PROVIDED_BY"}},
+			}},
+		}
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"Service":  {ComputedFields: map[string]ComputedField{"A": {Expression: `refs()[0].B`}}},
+			"Provider": {ComputedFields: map[string]ComputedField{"B": {Expression: `incoming()[0].A`}}},
+		}}
+
+		ctx := newDictionaryContext(d, df)
+		_, err := ctx.field(DefinitionKey{Class: "Service", ID: "vm"}, "A")
+		assert.EqualError(t, err, "computed field [A] in definition ID [vm] for class [Service] depends on itself")
+		assert.NotNil(t, ComputeFields(d, df))
+		assert.Empty(t, d["Service"]["vm"].Fields)
+		assert.Empty(t, d["Provider"]["azure"].Fields)
+	})
+
+	t.Run("NestedEvaluationKeepsError", func(t *testing.T) {
+		d := Dictionary{
+			"Provider": {"azure": {Fields: definition.Fields{"Name": "Azure"}}},
+			"Service":  {"vm": {}},
+		}
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"Service": {ComputedFields: map[string]ComputedField{
+				"Bad":   {Expression: `1 / 0`},
+				"Outer": {Expression: `string(Bad) + lookup("Provider", "azure").Label`},
+			}},
+			"Provider": {ComputedFields: map[string]ComputedField{"Label": {Expression: `Name`}}},
+		}}
+
+		_, err := newDictionaryContext(d, df).field(DefinitionKey{Class: "Service", ID: "vm"}, "Outer")
+		assert.EqualError(t, err, "division by zero")
+	})
+
+	t.Run("NilFormat", func(t *testing.T) {
+		assert.Nil(t, ComputeFields(LoadDictionary([]string{"_test/computeFields"}, "yaml"), nil))
+	})
+}
+
+func Test_ApplyComputedFields(t *testing.T) {
+	d := Dictionary{
+		"Parent": {"p1": {Fields: definition.Fields{"Name": "P", "Slug": "p"}}},
+		"Child":  {"c1": {Fields: definition.Fields{"Slug": "c"}}},
+	}
+	df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+		"Parent": {ComputedFields: map[string]ComputedField{"Slug": {Expression: `slug(Name)`}}},
+		"Child":  {ComputedFields: map[string]ComputedField{"Slug": {Expression: `slug(ID)`}}},
+	}}
+	spec := definition.Specification{
+		Class: "Parent",
+		Definitions: map[string]definition.Definition{
+			"p1": {
+				Fields: definition.Fields{"Name": "P"},
+				SubDefinitions: map[string]definition.Specification{
+					"CHILD_OF": {Class: "Child", Definitions: map[string]definition.Definition{"c1": {}}},
+				},
+			},
+		},
+	}
+
+	ApplyComputedFields(&spec, d, df)
+	assert.Equal(t, definition.Fields{"Name": "P", "Slug": "p"}, spec.Definitions["p1"].Fields)
+	assert.Equal(t, definition.Fields{"Slug": "c"},
+		spec.Definitions["p1"].SubDefinitions["CHILD_OF"].Definitions["c1"].Fields)
+}
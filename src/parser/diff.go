@@ -0,0 +1,160 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+)
+
+type (
+	// FieldChange is a field whose value differs between two definitions; Old is nil for added fields and New is nil
+	// for removed fields
+	FieldChange struct {
+		Field string
+		Old   interface{} `json:",omitempty"`
+		New   interface{} `json:",omitempty"`
+	}
+
+	// DefinitionChange holds the changes to a single definition; for added and removed definitions every field and
+	// reference is included
+	DefinitionChange struct {
+		Class string
+		ID    string
+
+		Fields            []FieldChange          `json:",omitempty"`
+		AddedReferences   []definition.Reference `json:",omitempty"`
+		RemovedReferences []definition.Reference `json:",omitempty"`
+	}
+
+	// DictionaryDiff holds the differences between two Dictionary values, sorted by class and then by ID
+	DictionaryDiff struct {
+		Added    []DefinitionChange
+		Removed  []DefinitionChange
+		Modified []DefinitionChange
+	}
+)
+
+// Empty returns true if there are no differences
+func (dd *DictionaryDiff) Empty() bool {
+	return len(dd.Added) == 0 && len(dd.Removed) == 0 && len(dd.Modified) == 0
+}
+
+// DiffDictionaries compares the definitions in the before and after Dictionary values
+func DiffDictionaries(before, after Dictionary) *DictionaryDiff {
+	dd := &DictionaryDiff{}
+
+	classes := map[string]bool{}
+	for class := range before {
+		classes[class] = true
+	}
+	for class := range after {
+		classes[class] = true
+	}
+	sorted := make([]string, 0, len(classes))
+	for class := range classes {
+		sorted = append(sorted, class)
+	}
+	sort.Strings(sorted)
+
+	for _, class := range sorted {
+		ids := map[string]bool{}
+		for id := range before[class] {
+			ids[id] = true
+		}
+		for id := range after[class] {
+			ids[id] = true
+		}
+		allIDs := make([]string, 0, len(ids))
+		for id := range ids {
+			allIDs = append(allIDs, id)
+		}
+		sort.Strings(allIDs)
+
+		for _, id := range allIDs {
+			b, a := before[class][id], after[class][id]
+			c := diffDefinition(class, id, b, a)
+			switch {
+			case b == nil:
+				dd.Added = append(dd.Added, c)
+			case a == nil:
+				dd.Removed = append(dd.Removed, c)
+			case len(c.Fields) > 0 || len(c.AddedReferences) > 0 || len(c.RemovedReferences) > 0:
+				dd.Modified = append(dd.Modified, c)
+			}
+		}
+	}
+
+	return dd
+}
+
+// diffDefinition compares two versions of a definition, either of which may be nil
+func diffDefinition(class, id string, before, after *DictionaryDefinition) DefinitionChange {
+	c := DefinitionChange{Class: class, ID: id}
+	if before == nil {
+		before = &DictionaryDefinition{}
+	}
+	if after == nil {
+		after = &DictionaryDefinition{}
+	}
+
+	fields := map[string]bool{}
+	for f := range before.Fields {
+		fields[f] = true
+	}
+	for f := range after.Fields {
+		fields[f] = true
+	}
+	sortedFields := make([]string, 0, len(fields))
+	for f := range fields {
+		sortedFields = append(sortedFields, f)
+	}
+	sort.Strings(sortedFields)
+
+	for _, f := range sortedFields {
+		if !reflect.DeepEqual(before.Fields[f], after.Fields[f]) {
+			c.Fields = append(c.Fields, FieldChange{Field: f, Old: before.Fields[f], New: after.Fields[f]})
+		}
+	}
+
+	c.RemovedReferences = subtractReferences(before.References, after.References)
+	c.AddedReferences = subtractReferences(after.References, before.References)
+
+	return c
+}
+
+// subtractReferences returns the references in a which are not in b, treating each as a multiset so that a
+// duplicated reference which has been removed once is still reported
+func subtractReferences(a, b []definition.Reference) (refs []definition.Reference) {
+	matched := make([]bool, len(b))
+	for _, ra := range a {
+		found := false
+		for i, rb := range b {
+			if !matched[i] && reflect.DeepEqual(ra, rb) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			refs = append(refs, ra)
+		}
+	}
+	return refs
+}
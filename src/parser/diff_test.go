@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiffDictionaries(t *testing.T) {
+	hostedBy := definition.Reference{Class: "Provider", ID: "azure", Relationship: "HOSTED_BY", RelationshipTo: true}
+	before := Dictionary{
+		"Provider": {
+			"azure":  {Fields: definition.Fields{"Name": "Azure"}},
+			"oracle": {Fields: definition.Fields{"Name": "Oracle"}},
+		},
+		"Service": {
+			"vm": {Fields: definition.Fields{"Name": "Virtual Machine", "Cores": 4},
+				References: []definition.Reference{hostedBy, hostedBy}},
+			"unchanged": {Fields: definition.Fields{"Name": "Unchanged"}},
+		},
+	}
+	after := Dictionary{
+		"Provider": {
+			"azure": {Fields: definition.Fields{"Name": "Azure"}},
+			"gcp":   {Fields: definition.Fields{"Name": "Google Cloud"}},
+		},
+		"Service": {
+			"vm": {Fields: definition.Fields{"Name": "VM", "Managed": true},
+				References: []definition.Reference{hostedBy,
+					{Class: "Provider", ID: "gcp", Relationship: "HOSTED_BY", RelationshipTo: true}}},
+			"unchanged": {Fields: definition.Fields{"Name": "Unchanged"}},
+		},
+	}
+
+	dd := DiffDictionaries(before, after)
+	assert.False(t, dd.Empty())
+	assert.Equal(t, []DefinitionChange{{Class: "Provider", ID: "gcp",
+		Fields: []FieldChange{{Field: "Name", New: "Google Cloud"}}}}, dd.Added)
+	assert.Equal(t, []DefinitionChange{{Class: "Provider", ID: "oracle",
+		Fields: []FieldChange{{Field: "Name", Old: "Oracle"}}}}, dd.Removed)
+	assert.Equal(t, []DefinitionChange{{
+		Class: "Service",
+		ID:    "vm",
+		Fields: []FieldChange{
+			{Field: "Cores", Old: 4},
+			{Field: "Managed", New: true},
+			{Field: "Name", Old: "Virtual Machine", New: "VM"},
+		},
+		AddedReferences:   []definition.Reference{{Class: "Provider", ID: "gcp", Relationship: "HOSTED_BY", RelationshipTo: true}},
+		RemovedReferences: []definition.Reference{hostedBy},
+	}}, dd.Modified)
+
+	assert.True(t, DiffDictionaries(before, before).Empty())
+	assert.True(t, DiffDictionaries(nil, Dictionary{}).Empty())
+}
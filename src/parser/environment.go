@@ -0,0 +1,413 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/expression"
+)
+
+const (
+	variableID     = "ID"
+	variableClass  = "Class"
+	variableFields = "Fields"
+
+	funcRefs        = "refs"
+	funcIncoming    = "incoming"
+	funcLookup      = "lookup"
+	funcDefinitions = "definitions"
+
+	errorInvalidFunctionArguments = "invalid arguments for function [%s]"
+	errorComputedFieldCycle       = "computed field [%s] in definition ID [%s] for class [%s] depends on itself"
+	errorComputedFieldType        = "computed field [%s] in definition ID [%s] for class [%s] must be a string, number, boolean or a list of them, not [%T]"
+)
+
+type (
+	// DefinitionKey uniquely identifies a definition within a Dictionary
+	DefinitionKey struct {
+		Class string
+		ID    string
+	}
+
+	// dictionaryContext holds the state shared by all expressions evaluated over a single Dictionary
+	dictionaryContext struct {
+		d  Dictionary
+		df *DefinitionFormat
+
+		// incoming is lazily built, mapping each definition to the definitions which reference it
+		incoming map[DefinitionKey][]incomingReference
+
+		// computing holds the computed fields currently being evaluated, to detect cycles
+		computing map[string]bool
+
+		// expressions caches parsed expressions by source
+		expressions map[string]*expression.Expression
+	}
+
+	incomingReference struct {
+		from      DefinitionKey
+		reference definition.Reference
+	}
+
+	// definitionEnvironment exposes a single definition, and the Dictionary it belongs to, to an expression
+	definitionEnvironment struct {
+		ctx *dictionaryContext
+		key DefinitionKey
+
+		// err holds the first error encountered when resolving an identifier, as Resolve cannot return one
+		err *error
+	}
+
+	// definitionObject is the representation of a definition used within expressions: its fields together with its
+	// ID and Class, with any computed fields evaluated only when they are accessed
+	definitionObject struct {
+		ctx *dictionaryContext
+		key DefinitionKey
+	}
+)
+
+func newDictionaryContext(d Dictionary, df *DefinitionFormat) *dictionaryContext {
+	return &dictionaryContext{
+		d:           d,
+		df:          df,
+		computing:   map[string]bool{},
+		expressions: map[string]*expression.Expression{},
+	}
+}
+
+func (ctx *dictionaryContext) parse(source string) (*expression.Expression, error) {
+	if e, ok := ctx.expressions[source]; ok {
+		return e, nil
+	}
+	e, err := expression.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	ctx.expressions[source] = e
+	return e, nil
+}
+
+func (ctx *dictionaryContext) environment(class, id string) definitionEnvironment {
+	return definitionEnvironment{ctx: ctx, key: DefinitionKey{Class: class, ID: id}, err: new(error)}
+}
+
+// evaluate evaluates the expression against the definition, returning any error encountered when resolving an
+// identifier
+func (ctx *dictionaryContext) evaluate(e *expression.Expression, key DefinitionKey) (interface{}, error) {
+	env := ctx.environment(key.Class, key.ID)
+	v, err := e.Evaluate(env)
+	if err == nil {
+		err = *env.err
+	}
+	return v, err
+}
+
+// evaluateBool evaluates the expression against the definition, returning an error if the result is not a boolean
+func (ctx *dictionaryContext) evaluateBool(e *expression.Expression, key DefinitionKey) (bool, error) {
+	env := ctx.environment(key.Class, key.ID)
+	b, err := e.EvaluateBool(env)
+	if err == nil {
+		err = *env.err
+	}
+	return b, err
+}
+
+func (ctx *dictionaryContext) definition(key DefinitionKey) *DictionaryDefinition {
+	if ctx.d[key.Class] == nil {
+		return nil
+	}
+	return ctx.d[key.Class][key.ID]
+}
+
+// computedField returns the format of the named computed field for the class, if one has been declared
+func (ctx *dictionaryContext) computedField(class, name string) (ComputedField, bool) {
+	if ctx.df == nil || ctx.df.ClassFormat[class] == nil {
+		return ComputedField{}, false
+	}
+	cf, ok := ctx.df.ClassFormat[class].ComputedFields[name]
+	return cf, ok
+}
+
+// field returns the value of a field, evaluating it first if it is a computed field which has not yet been computed
+func (ctx *dictionaryContext) field(key DefinitionKey, name string) (interface{}, error) {
+	dfn := ctx.definition(key)
+	if dfn == nil {
+		return nil, nil
+	}
+	if v, ok := dfn.Fields[name]; ok {
+		return v, nil
+	}
+
+	cf, ok := ctx.computedField(key.Class, name)
+	if !ok {
+		return nil, nil
+	}
+
+	computingKey := key.Class + "/" + key.ID + "/" + name
+	if ctx.computing[computingKey] {
+		return nil, fmt.Errorf(errorComputedFieldCycle, name, key.ID, key.Class)
+	}
+	ctx.computing[computingKey] = true
+	defer delete(ctx.computing, computingKey)
+
+	e, err := ctx.parse(cf.Expression)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctx.evaluate(e, key)
+	if err == nil {
+		v, err = materialise(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the value is stored as a node property, so must be a scalar or a list of scalars
+	if v != nil && !fieldTypeValid(v) {
+		return nil, fmt.Errorf(errorComputedFieldType, name, key.ID, key.Class, v)
+	}
+
+	if dfn.Fields == nil {
+		dfn.Fields = definition.Fields{}
+	}
+	if dfn.computed == nil {
+		dfn.computed = map[string]bool{}
+	}
+	dfn.Fields[name] = v
+	dfn.computed[name] = true
+	return v, nil
+}
+
+// value returns the representation of a definition used within expressions, or nil if there is no such definition
+func (ctx *dictionaryContext) value(key DefinitionKey) interface{} {
+	if ctx.definition(key) == nil {
+		return nil
+	}
+	return definitionObject{ctx: ctx, key: key}
+}
+
+// Field returns the named field of the definition, computing it first if necessary
+func (o definitionObject) Field(name string) (interface{}, error) {
+	switch name {
+	case variableID:
+		return o.key.ID, nil
+	case variableClass:
+		return o.key.Class, nil
+	}
+	return o.ctx.field(o.key, name)
+}
+
+// Fields returns every field of the definition, together with its ID and Class, computing any computed fields
+func (o definitionObject) Fields() (map[string]interface{}, error) {
+	if o.ctx.df != nil && o.ctx.df.ClassFormat[o.key.Class] != nil {
+		for name := range o.ctx.df.ClassFormat[o.key.Class].ComputedFields {
+			if _, err := o.ctx.field(o.key, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dfn := o.ctx.definition(o.key)
+	v := make(map[string]interface{}, len(dfn.Fields)+2)
+	for name, f := range dfn.Fields {
+		v[name] = f
+	}
+	v[variableID] = o.key.ID
+	v[variableClass] = o.key.Class
+
+	return v, nil
+}
+
+// materialise converts any definitions within the result of an expression into maps of their fields, so that the
+// result can be stored with the other fields of a definition
+func materialise(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case expression.Object:
+		return t.Fields()
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i := range t {
+			var err error
+			if l[i], err = materialise(t[i]); err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k := range t {
+			var err error
+			if m[k], err = materialise(t[k]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return v, nil
+}
+
+func (ctx *dictionaryContext) buildIncoming() {
+	if ctx.incoming != nil {
+		return
+	}
+
+	ctx.incoming = map[DefinitionKey][]incomingReference{}
+	for _, class := range sortedClasses(ctx.d) {
+		for _, id := range sortedIDs(ctx.d[class]) {
+			for _, ref := range ctx.d[class][id].References {
+				to, _ := ctx.d.Resolve(ctx.df, DefinitionKey{Class: ref.Class, ID: ref.ID})
+				ctx.incoming[to] = append(ctx.incoming[to], incomingReference{
+					from:      DefinitionKey{Class: class, ID: id},
+					reference: ref,
+				})
+			}
+		}
+	}
+}
+
+// Resolve resolves ID, Class and Fields; any other name resolves to the field of that name
+func (env definitionEnvironment) Resolve(name string) (interface{}, bool) {
+	switch name {
+	case variableID:
+		return env.key.ID, true
+	case variableClass:
+		return env.key.Class, true
+	case variableFields:
+		return env.ctx.value(env.key), true
+	}
+
+	v, err := env.ctx.field(env.key, name)
+	if err != nil {
+		if *env.err == nil {
+			*env.err = err
+		}
+		return nil, false
+	}
+	return v, v != nil
+}
+
+// targetAndRelationship parses the optional definition and relationship arguments of refs and incoming
+func (env definitionEnvironment) targetAndRelationship(name string, args []interface{}) (DefinitionKey, string, error) {
+	key := env.key
+	if len(args) > 0 {
+		if o, ok := args[0].(definitionObject); ok {
+			key = o.key
+			args = args[1:]
+		} else if m, ok := args[0].(map[string]interface{}); ok {
+			class, classOK := m[variableClass].(string)
+			id, idOK := m[variableID].(string)
+			if !classOK || !idOK {
+				return key, "", fmt.Errorf(errorInvalidFunctionArguments, name)
+			}
+			key = DefinitionKey{Class: class, ID: id}
+			args = args[1:]
+		}
+	}
+
+	switch len(args) {
+	case 0:
+		return key, "", nil
+	case 1:
+		if relationship, ok := args[0].(string); ok {
+			return key, relationship, nil
+		}
+	}
+
+	return key, "", fmt.Errorf(errorInvalidFunctionArguments, name)
+}
+
+// Call provides the graph functions refs, incoming, lookup and definitions
+func (env definitionEnvironment) Call(name string, args []interface{}) (interface{}, bool, error) {
+	switch name {
+	case funcRefs:
+		key, relationship, err := env.targetAndRelationship(name, args)
+		if err != nil {
+			return nil, true, err
+		}
+		result := []interface{}{}
+		if dfn := env.ctx.definition(key); dfn != nil {
+			for _, ref := range dfn.References {
+				if relationship == "" || ref.Relationship == relationship {
+					to, _ := env.ctx.d.Resolve(env.ctx.df, DefinitionKey{Class: ref.Class, ID: ref.ID})
+					if v := env.ctx.value(to); v != nil {
+						result = append(result, v)
+					}
+				}
+			}
+		}
+		return result, true, nil
+
+	case funcIncoming:
+		key, relationship, err := env.targetAndRelationship(name, args)
+		if err != nil {
+			return nil, true, err
+		}
+		env.ctx.buildIncoming()
+		result := []interface{}{}
+		for _, in := range env.ctx.incoming[key] {
+			if relationship == "" || in.reference.Relationship == relationship {
+				result = append(result, env.ctx.value(in.from))
+			}
+		}
+		return result, true, nil
+
+	case funcLookup:
+		if len(args) != 2 {
+			return nil, true, fmt.Errorf(errorInvalidFunctionArguments, name)
+		}
+		key, _ := env.ctx.d.Resolve(env.ctx.df,
+			DefinitionKey{Class: expression.ToString(args[0]), ID: expression.ToString(args[1])})
+		v := env.ctx.value(key)
+		if v == nil {
+			return nil, true, nil
+		}
+		return v, true, nil
+
+	case funcDefinitions:
+		if len(args) != 1 {
+			return nil, true, fmt.Errorf(errorInvalidFunctionArguments, name)
+		}
+		class := expression.ToString(args[0])
+		result := []interface{}{}
+		for _, id := range sortedIDs(env.ctx.d[class]) {
+			result = append(result, env.ctx.value(DefinitionKey{Class: class, ID: id}))
+		}
+		return result, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func sortedClasses(d Dictionary) []string {
+	classes := make([]string, 0, len(d))
+	for class := range d {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+func sortedIDs(definitions map[string]*DictionaryDefinition) []string {
+	ids := make([]string, 0, len(definitions))
+	for id := range definitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
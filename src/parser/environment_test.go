@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/expression"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_definitionEnvironment(t *testing.T) {
+	ctx := newDictionaryContext(LoadDictionary([]string{"_test/computeFields"}, "yaml"), nil)
+	env := ctx.environment("Service", "virtual-machine")
+
+	valid := map[string]interface{}{
+		`ID + ":" + Class`:                    "virtual-machine:Service",
+		`Fields.Name`:                         "Virtual Machine",
+		`Missing`:                             nil,
+		`refs().map(r, r.ID)`:                 []interface{}{"azure", "compute"},
+		`refs("TYPE_OF")[0].Class`:            "Category",
+		`lookup("Provider", "azure").Name`:    "Azure",
+		`lookup("Provider", "gcp")`:           nil,
+		`definitions("Service").map(s, s.ID)`: []interface{}{"app-service", "virtual-machine"},
+		`incoming(lookup("Provider", "azure"), "PROVIDED_BY").size()`: 2,
+		`refs(lookup("Service", "app-service")).size()`:               1,
+		`lookup("Provider", "azure").incoming()`: []interface{}{
+			map[string]interface{}{"ID": "app-service", "Class": "Service"},
+			map[string]interface{}{"ID": "virtual-machine", "Class": "Service", "Name": "Virtual Machine"},
+		},
+	}
+
+	for source, expected := range valid {
+		e, err := expression.Parse(source)
+		assert.Nil(t, err, source)
+		v, err := e.Evaluate(env)
+		assert.Nil(t, err, source)
+		v, err = materialise(v)
+		assert.Nil(t, err, source)
+		assert.Equal(t, expected, v, source)
+	}
+
+	for _, source := range []string{`refs(1)`, `refs("a", "b")`, `lookup("a")`, `definitions()`} {
+		e, err := expression.Parse(source)
+		assert.Nil(t, err, source)
+		_, err = e.Evaluate(env)
+		assert.NotNil(t, err, source)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"sort"
+)
+
+type (
+	// ImpactOptions restricts the definitions visited during impact analysis
+	ImpactOptions struct {
+		// Depth is the maximum number of relationships followed from the definition; zero means no limit
+		Depth int
+
+		// Relationships restricts the relationships followed; defaults to all relationships
+		Relationships []string
+	}
+
+	// ImpactNode is a definition reached during impact analysis, together with the definitions reached from it
+	ImpactNode struct {
+		Class        string
+		ID           string
+		Relationship string
+
+		// Missing is set for references to definitions which do not exist
+		Missing bool `json:",omitempty"`
+
+		Children []*ImpactNode `json:",omitempty"`
+	}
+
+	// ImpactAnalysis holds the definitions which depend on a definition, via incoming references, and the
+	// definitions it depends on, via outgoing references
+	ImpactAnalysis struct {
+		Class string
+		ID    string
+
+		// Missing is set if the definition itself does not exist, e.g. because it has been removed
+		Missing bool `json:",omitempty"`
+
+		Incoming []*ImpactNode `json:",omitempty"`
+		Outgoing []*ImpactNode `json:",omitempty"`
+
+		// Counts holds the number of distinct definitions reached in either direction, keyed by class
+		Counts map[string]int
+	}
+)
+
+// Referenced returns true if any other definition refers to the definition
+func (ia *ImpactAnalysis) Referenced() bool {
+	return len(ia.Incoming) > 0
+}
+
+// Impact walks the references to and from the definition, breadth first, so that each definition appears once in
+// each direction at the shallowest depth at which it is reached. The DefinitionFormat is optional, and resolves
+// references to a base class to the definitions of its subclasses.
+func Impact(d Dictionary, df *DefinitionFormat, class, id string, opts ImpactOptions) *ImpactAnalysis {
+	root, found := d.Resolve(df, DefinitionKey{Class: class, ID: id})
+	ia := &ImpactAnalysis{Class: root.Class, ID: id, Missing: !found, Counts: map[string]int{}}
+
+	gc := &GraphCheck{Relationships: opts.Relationships}
+	incoming := map[DefinitionKey][]edge{}
+	outgoing := map[DefinitionKey][]edge{}
+	for _, e := range getEdges(d, df) {
+		if gc.includesRelationship(e.relationship) {
+			incoming[e.to] = append(incoming[e.to], e)
+			outgoing[e.from] = append(outgoing[e.from], e)
+		}
+	}
+
+	reached := map[DefinitionKey]bool{}
+	ia.Incoming = walkImpact(d, root, opts.Depth, reached, func(k DefinitionKey) (keys []DefinitionKey,
+		relationships []string) {
+		for _, e := range incoming[k] {
+			keys, relationships = append(keys, e.from), append(relationships, e.relationship)
+		}
+		return keys, relationships
+	})
+	ia.Outgoing = walkImpact(d, root, opts.Depth, reached, func(k DefinitionKey) (keys []DefinitionKey,
+		relationships []string) {
+		for _, e := range outgoing[k] {
+			keys, relationships = append(keys, e.to), append(relationships, e.relationship)
+		}
+		return keys, relationships
+	})
+
+	for k := range reached {
+		ia.Counts[k.Class]++
+	}
+	return ia
+}
+
+// walkImpact performs a breadth first walk from the root using next to find the adjacent definitions, adding each
+// definition visited to reached
+func walkImpact(d Dictionary, root DefinitionKey, depth int, reached map[DefinitionKey]bool,
+	next func(DefinitionKey) ([]DefinitionKey, []string)) (nodes []*ImpactNode) {
+	visited := map[DefinitionKey]bool{root: true}
+
+	type item struct {
+		key      DefinitionKey
+		children *[]*ImpactNode
+	}
+	level := []item{{key: root, children: &nodes}}
+
+	for l := 1; len(level) > 0 && (depth <= 0 || l <= depth); l++ {
+		var nextLevel []item
+		for _, it := range level {
+			keys, relationships := next(it.key)
+			order := make([]int, len(keys))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(i, j int) bool { return keys[order[i]].less(keys[order[j]]) })
+
+			for _, i := range order {
+				k := keys[i]
+				if visited[k] {
+					continue
+				}
+				visited[k] = true
+				reached[k] = true
+
+				n := &ImpactNode{Class: k.Class, ID: k.ID, Relationship: relationships[i], Missing: d[k.Class][k.ID] == nil}
+				*it.children = append(*it.children, n)
+				if !n.Missing {
+					nextLevel = append(nextLevel, item{key: k, children: &n.Children})
+				}
+			}
+		}
+		level = nextLevel
+	}
+
+	return nodes
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Impact(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+
+	t.Run("All", func(t *testing.T) {
+		ia := Impact(d, nil, "Provider", "azure", ImpactOptions{})
+		assert.False(t, ia.Missing)
+		assert.True(t, ia.Referenced())
+		assert.Equal(t, []*ImpactNode{
+			{Class: "Service", ID: "aks", Relationship: "HOSTED_BY"},
+			{Class: "Service", ID: "vm", Relationship: "HOSTED_BY", Children: []*ImpactNode{
+				{Class: "Component", ID: "disk", Relationship: "PART_OF"},
+			}},
+		}, ia.Incoming)
+		assert.Equal(t, []*ImpactNode{{Class: "Category", ID: "compute", Relationship: "IN"}}, ia.Outgoing)
+		assert.Equal(t, map[string]int{"Category": 1, "Component": 1, "Service": 2}, ia.Counts)
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		ia := Impact(d, nil, "Provider", "azure", ImpactOptions{Depth: 1})
+		assert.Len(t, ia.Incoming, 2)
+		assert.Nil(t, ia.Incoming[1].Children)
+		assert.Equal(t, map[string]int{"Category": 1, "Service": 2}, ia.Counts)
+	})
+
+	t.Run("Relationships", func(t *testing.T) {
+		ia := Impact(d, nil, "Service", "vm", ImpactOptions{Relationships: []string{"PART_OF"}})
+		assert.Equal(t, []*ImpactNode{{Class: "Component", ID: "disk", Relationship: "PART_OF"}}, ia.Incoming)
+		assert.Nil(t, ia.Outgoing)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		ia := Impact(d, nil, "Service", "gone", ImpactOptions{})
+		assert.True(t, ia.Missing)
+		assert.True(t, ia.Referenced())
+		assert.Equal(t, "disk", ia.Incoming[0].ID)
+
+		ia = Impact(d, nil, "Component", "disk", ImpactOptions{Depth: 1})
+		assert.Equal(t, []*ImpactNode{
+			{Class: "Service", ID: "gone", Relationship: "PART_OF", Missing: true},
+			{Class: "Service", ID: "vm", Relationship: "PART_OF"},
+		}, ia.Outgoing)
+		assert.False(t, ia.Referenced())
+	})
+}
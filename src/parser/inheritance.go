@@ -0,0 +1,175 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	errorUnknownBaseClass    = "class [%s] extends unknown class [%s]"
+	errorInheritanceCycle    = "class [%s] extends itself via [%s]"
+	logWarnAbstractClassUsed = "definition ID [%s] found for abstract class [%s]"
+)
+
+// ResolveInheritance copies the fields, computed fields and rules of each base class into the classes which extend
+// it; a class overrides any field or rule of the same name or ID declared by its bases. Resolving a format more than
+// once has no further effect.
+func (df *DefinitionFormat) ResolveInheritance() error {
+	if df == nil {
+		return nil
+	}
+
+	resolved := map[string]bool{}
+	for _, class := range sortedClassFormats(df) {
+		if err := df.resolveClass(class, resolved, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (df *DefinitionFormat) resolveClass(class string, resolved map[string]bool, path []string) error {
+	if resolved[class] {
+		return nil
+	}
+	for _, c := range path {
+		if c == class {
+			return fmt.Errorf(errorInheritanceCycle, class, strings.Join(append(path, class), " -> "))
+		}
+	}
+
+	cf := df.ClassFormat[class]
+	if cf == nil || cf.Extends == "" {
+		resolved[class] = true
+		return nil
+	}
+
+	base := df.ClassFormat[cf.Extends]
+	if base == nil {
+		return fmt.Errorf(errorUnknownBaseClass, class, cf.Extends)
+	}
+	if err := df.resolveClass(cf.Extends, resolved, append(path, class)); err != nil {
+		return err
+	}
+
+	cf.MandatoryFields = inheritFields(base.MandatoryFields, cf.MandatoryFields)
+	cf.OptionalFields = inheritFields(base.OptionalFields, cf.OptionalFields)
+
+	for name, field := range base.ComputedFields {
+		if _, ok := cf.ComputedFields[name]; !ok {
+			if cf.ComputedFields == nil {
+				cf.ComputedFields = map[string]ComputedField{}
+			}
+			cf.ComputedFields[name] = field
+		}
+	}
+
+	var rules []Rule
+	for _, rule := range base.Rules {
+		if !hasRule(cf.Rules, rule.ID) {
+			rules = append(rules, rule)
+		}
+	}
+	cf.Rules = append(rules, cf.Rules...)
+
+	resolved[class] = true
+	return nil
+}
+
+func inheritFields(base, fields map[string]ClassField) map[string]ClassField {
+	for name, field := range base {
+		if _, ok := fields[name]; !ok {
+			if fields == nil {
+				fields = map[string]ClassField{}
+			}
+			fields[name] = field
+		}
+	}
+	return fields
+}
+
+func hasRule(rules []Rule, id string) bool {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// BaseClasses returns the classes the class extends, nearest first
+func (df *DefinitionFormat) BaseClasses(class string) (bases []string) {
+	if df == nil {
+		return nil
+	}
+
+	for cf := df.ClassFormat[class]; cf != nil && cf.Extends != ""; cf = df.ClassFormat[cf.Extends] {
+		if cf.Extends == class || contains(bases, cf.Extends) {
+			// guard against cycles in an unresolved format
+			break
+		}
+		bases = append(bases, cf.Extends)
+	}
+
+	return bases
+}
+
+// SubClasses returns every class which extends the class, directly or indirectly, in name order
+func (df *DefinitionFormat) SubClasses(class string) (subClasses []string) {
+	if df == nil {
+		return nil
+	}
+
+	for _, c := range sortedClassFormats(df) {
+		if c != class && contains(df.BaseClasses(c), class) {
+			subClasses = append(subClasses, c)
+		}
+	}
+
+	return subClasses
+}
+
+// Resolve returns the key of the definition with the class and ID, or otherwise of the definition with the ID of the
+// first subclass which has one, as a reference to a base class refers to a definition of any of its subclasses. The
+// key is returned unchanged if there is no such definition.
+func (d Dictionary) Resolve(df *DefinitionFormat, key DefinitionKey) (DefinitionKey, bool) {
+	for _, class := range append([]string{key.Class}, df.SubClasses(key.Class)...) {
+		if d[class][key.ID] != nil {
+			return DefinitionKey{Class: class, ID: key.ID}, true
+		}
+	}
+	return key, false
+}
+
+// ClassLabels returns the additional labels for each class which extends another, keyed by class name
+func (df *DefinitionFormat) ClassLabels() map[string][]string {
+	if df == nil {
+		return nil
+	}
+
+	labels := map[string][]string{}
+	for class := range df.ClassFormat {
+		if bases := df.BaseClasses(class); len(bases) > 0 {
+			labels[class] = bases
+		}
+	}
+
+	return labels
+}
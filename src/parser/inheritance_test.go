@@ -0,0 +1,196 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/stretchr/testify/assert"
+)
+
+func getInheritanceFormat() *DefinitionFormat {
+	return &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+		"Named": {
+			Abstract:        true,
+			MandatoryFields: map[string]ClassField{"Name": {Description: "name"}},
+			OptionalFields:  map[string]ClassField{"Description": {}},
+			ComputedFields:  map[string]ComputedField{"Slug": {Expression: "slug(Name)"}},
+			Rules: []Rule{
+				{ID: "name-not-id", Expression: "Name != ID"},
+				{ID: "short-name", Expression: "size(Name) < 20"},
+			},
+		},
+		"Resource": {
+			Extends:         "Named",
+			Abstract:        true,
+			MandatoryFields: map[string]ClassField{"Name": {Description: "resource name"}},
+			Rules:           []Rule{{ID: "short-name", Expression: "size(Name) < 10"}},
+		},
+		"Service": {
+			Extends:         "Resource",
+			MandatoryFields: map[string]ClassField{"Category": {}},
+		},
+		"Provider": {
+			Extends: "Named",
+		},
+	}}
+}
+
+func Test_ResolveInheritance(t *testing.T) {
+	t.Run("Resolved", func(t *testing.T) {
+		df := getInheritanceFormat()
+		assert.Nil(t, df.ResolveInheritance())
+
+		service := df.ClassFormat["Service"]
+		assert.Equal(t, map[string]ClassField{"Name": {Description: "resource name"}, "Category": {}},
+			service.MandatoryFields)
+		assert.Equal(t, map[string]ClassField{"Description": {}}, service.OptionalFields)
+		assert.Equal(t, map[string]ComputedField{"Slug": {Expression: "slug(Name)"}}, service.ComputedFields)
+		assert.Equal(t, []Rule{
+			{ID: "name-not-id", Expression: "Name != ID"},
+			{ID: "short-name", Expression: "size(Name) < 10"},
+		}, service.Rules)
+
+		assert.Equal(t, map[string]ClassField{"Name": {Description: "name"}},
+			df.ClassFormat["Provider"].MandatoryFields)
+		assert.Equal(t, df.ClassFormat["Named"].Rules, df.ClassFormat["Provider"].Rules)
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		df := getInheritanceFormat()
+		assert.Nil(t, df.ResolveInheritance())
+		rules := df.ClassFormat["Service"].Rules
+		assert.Nil(t, df.ResolveInheritance())
+		assert.Equal(t, rules, df.ClassFormat["Service"].Rules)
+	})
+
+	t.Run("UnknownBaseClass", func(t *testing.T) {
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{"Service": {Extends: "Missing"}}}
+		assert.NotNil(t, df.ResolveInheritance())
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+			"A": {Extends: "B"},
+			"B": {Extends: "A"},
+		}}
+		assert.EqualError(t, df.ResolveInheritance(), "class [A] extends itself via [A -> B -> A]")
+	})
+
+	t.Run("NilFormat", func(t *testing.T) {
+		var df *DefinitionFormat
+		assert.Nil(t, df.ResolveInheritance())
+	})
+}
+
+func Test_BaseAndSubClasses(t *testing.T) {
+	df := getInheritanceFormat()
+
+	assert.Equal(t, []string{"Resource", "Named"}, df.BaseClasses("Service"))
+	assert.Nil(t, df.BaseClasses("Named"))
+	assert.Equal(t, []string{"Provider", "Resource", "Service"}, df.SubClasses("Named"))
+	assert.Nil(t, df.SubClasses("Service"))
+	assert.Equal(t, map[string][]string{
+		"Resource": {"Named"},
+		"Service":  {"Resource", "Named"},
+		"Provider": {"Named"},
+	}, df.ClassLabels())
+}
+
+func Test_ValidateInheritance(t *testing.T) {
+	df := getInheritanceFormat()
+	assert.Nil(t, df.ResolveInheritance())
+
+	d := Dictionary{
+		"Named": {
+			"stray": {Fields: definition.Fields{"Name": "Stray"}},
+		},
+		"Provider": {
+			"azure": {Fields: definition.Fields{"Name": "Azure"}},
+		},
+		"Service": {
+			"vm": {
+				Fields: definition.Fields{"Name": "VM", "Category": "compute"},
+				// references to a base class resolve to definitions of its subclasses
+				References: []definition.Reference{
+					{Class: "Named", ID: "azure", Relationship: "PROVIDED_BY"},
+					{Class: "Resource", ID: "vm", Relationship: "SELF"},
+					{Class: "Resource", ID: "missing", Relationship: "DEPENDS_ON"},
+				},
+			},
+			"app": {Fields: definition.Fields{"Name": "Application Service"}},
+		},
+	}
+
+	result := Validate(d, df)
+	var ruleIDs []string
+	for _, f := range result.Findings {
+		ruleIDs = append(ruleIDs, f.RuleID)
+	}
+	assert.ElementsMatch(t, []string{
+		RuleAbstractClass,
+		RuleMandatoryFieldMissing,
+		RuleUnknownDefinition,
+		"short-name",
+	}, ruleIDs)
+}
+
+func Test_DictionaryResolve(t *testing.T) {
+	df := getInheritanceFormat()
+	df.ClassFormat["Service"].ComputedFields = map[string]ComputedField{
+		"Providers": {Expression: `refs("PROVIDED_BY").map(p, p.Name)`},
+		"Consumers": {Expression: `incoming(lookup("Named", "azure")).map(s, s.ID)`},
+	}
+	d := Dictionary{
+		"Provider": {
+			"azure": {Fields: definition.Fields{"Name": "Azure"}},
+		},
+		"Service": {
+			"vm": {
+				Fields:     definition.Fields{"Name": "VM"},
+				References: []definition.Reference{{Class: "Named", ID: "azure", Relationship: "PROVIDED_BY"}},
+			},
+		},
+	}
+
+	key, found := d.Resolve(df, DefinitionKey{Class: "Named", ID: "azure"})
+	assert.True(t, found)
+	assert.Equal(t, DefinitionKey{Class: "Provider", ID: "azure"}, key)
+	key, found = d.Resolve(df, DefinitionKey{Class: "Named", ID: "missing"})
+	assert.False(t, found)
+	assert.Equal(t, DefinitionKey{Class: "Named", ID: "missing"}, key)
+	_, found = d.Resolve(nil, DefinitionKey{Class: "Named", ID: "azure"})
+	assert.False(t, found)
+
+	assert.Nil(t, ComputeFields(d, df))
+	assert.Equal(t, []interface{}{"Azure"}, d["Service"]["vm"].Fields["Providers"])
+	assert.Equal(t, []interface{}{"vm"}, d["Service"]["vm"].Fields["Consumers"])
+
+	ia := Impact(d, df, "Named", "azure", ImpactOptions{})
+	assert.False(t, ia.Missing)
+	assert.Equal(t, "Provider", ia.Class)
+	assert.Equal(t, 1, ia.Counts["Service"])
+
+	p := ShortestPath(d, df, DefinitionKey{Class: "Service", ID: "vm"}, DefinitionKey{Class: "Named", ID: "azure"},
+		PathOptions{})
+	if assert.NotNil(t, p) {
+		assert.Equal(t, DefinitionKey{Class: "Provider", ID: "azure"}, p.Steps[len(p.Steps)-1].To)
+	}
+	assert.Nil(t, ShortestPath(d, nil, DefinitionKey{Class: "Service", ID: "vm"},
+		DefinitionKey{Class: "Provider", ID: "azure"}, PathOptions{}))
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	logCycleFound             = "cycle found in relationships %v: %s"
+	logOrphanFound            = "definition ID [%s] for class [%s] has no relationships"
+	logUnreachableFound       = "definition ID [%s] for class [%s] is not reachable from root classes %v"
+	logDuplicateEdgeFound     = "duplicate relationship [%s] from definition ID [%s] for class [%s] to definition ID [%s] for class [%s]"
+	logWarnInvalidCheckConfig = "graph check [%s] has invalid severity [%s]"
+
+	cycleSeparator = " -> "
+
+	checkCycles         = "Cycles"
+	checkOrphans        = "Orphans"
+	checkUnreachable    = "Unreachable"
+	checkDuplicateEdges = "DuplicateEdges"
+)
+
+type (
+	// GraphCheck holds the options common to each of the graph integrity checks
+	GraphCheck struct {
+		// Severity is one of error, warning or info; defaults to error
+		Severity Severity `yaml:"Severity,omitempty"`
+
+		// Classes restricts the check to definitions of the classes listed; defaults to all classes
+		Classes []string `yaml:"Classes,omitempty"`
+
+		// Relationships restricts the relationships considered by the check; defaults to all relationships
+		Relationships []string `yaml:"Relationships,omitempty"`
+	}
+
+	// UnreachableCheck reports definitions which cannot be reached from any definition of the RootClasses
+	UnreachableCheck struct {
+		GraphCheck `yaml:",inline"`
+
+		RootClasses []string `yaml:"RootClasses"`
+	}
+
+	// GraphChecks configures the optional graph-level checks performed during validation; a nil check is not performed
+	GraphChecks struct {
+		// Cycles reports cycles within the Relationships listed, which are treated as directed from the definition
+		// containing the reference to the definition referred to, e.g. from a sub-definition to its parent
+		Cycles *GraphCheck `yaml:"Cycles,omitempty"`
+
+		// Orphans reports definitions which have no incoming or outgoing relationships
+		Orphans *GraphCheck `yaml:"Orphans,omitempty"`
+
+		// Unreachable reports definitions which cannot be reached, in either direction, from the RootClasses
+		Unreachable *UnreachableCheck `yaml:"Unreachable,omitempty"`
+
+		// DuplicateEdges reports relationships of the same type declared more than once between two definitions
+		DuplicateEdges *GraphCheck `yaml:"DuplicateEdges,omitempty"`
+	}
+
+	// edge is a single relationship between two definitions, directed from the definition containing the reference
+	edge struct {
+		from, to     DefinitionKey
+		relationship string
+		undirected   bool
+	}
+)
+
+func (gc *GraphCheck) includesClass(class string) bool {
+	return len(gc.Classes) == 0 || contains(gc.Classes, class)
+}
+
+func (gc *GraphCheck) includesRelationship(relationship string) bool {
+	return len(gc.Relationships) == 0 || contains(gc.Relationships, relationship)
+}
+
+// getEdges returns every relationship in the Dictionary in a deterministic order, with any reference to a base class
+// resolved to the definition of its subclass
+func getEdges(d Dictionary, df *DefinitionFormat) (edges []edge) {
+	for _, class := range sortedClasses(d) {
+		for _, id := range sortedIDs(d[class]) {
+			for _, ref := range d[class][id].References {
+				to, _ := d.Resolve(df, DefinitionKey{Class: ref.Class, ID: ref.ID})
+				edges = append(edges, edge{
+					from:         DefinitionKey{Class: class, ID: id},
+					to:           to,
+					relationship: ref.Relationship,
+					undirected:   !ref.RelationshipFrom && !ref.RelationshipTo,
+				})
+			}
+		}
+	}
+	return edges
+}
+
+func (k DefinitionKey) String() string {
+	return k.Class + "/" + k.ID
+}
+
+func (k DefinitionKey) less(other DefinitionKey) bool {
+	return k.String() < other.String()
+}
+
+// findCycles performs a depth-first search over the edges, returning each distinct cycle found
+func findCycles(d Dictionary, df *DefinitionFormat, gc *GraphCheck) (cycles [][]DefinitionKey) {
+	adjacency := map[DefinitionKey][]DefinitionKey{}
+	for _, e := range getEdges(d, df) {
+		if gc.includesRelationship(e.relationship) && d[e.to.Class][e.to.ID] != nil {
+			adjacency[e.from] = append(adjacency[e.from], e.to)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[DefinitionKey]int{}
+	seen := map[string]bool{}
+	var path []DefinitionKey
+
+	var visit func(k DefinitionKey)
+	visit = func(k DefinitionKey) {
+		state[k] = visiting
+		path = append(path, k)
+
+		for _, next := range adjacency[k] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				// extract the cycle from the path and rotate it to start at its lowest key so it is only reported once
+				start := len(path) - 1
+				for path[start] != next {
+					start--
+				}
+				cycle := append([]DefinitionKey{}, path[start:]...)
+				lowest := 0
+				for i := range cycle {
+					if cycle[i].less(cycle[lowest]) {
+						lowest = i
+					}
+				}
+				cycle = append(cycle[lowest:], cycle[:lowest]...)
+
+				if signature := formatCycle(cycle); !seen[signature] {
+					seen[signature] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[k] = visited
+	}
+
+	for _, class := range sortedClasses(d) {
+		if !gc.includesClass(class) {
+			continue
+		}
+		for _, id := range sortedIDs(d[class]) {
+			if k := (DefinitionKey{Class: class, ID: id}); state[k] == unvisited {
+				visit(k)
+			}
+		}
+	}
+
+	return cycles
+}
+
+func formatCycle(cycle []DefinitionKey) string {
+	s := make([]string, 0, len(cycle)+1)
+	for _, k := range cycle {
+		s = append(s, k.String())
+	}
+	return strings.Join(append(s, cycle[0].String()), cycleSeparator)
+}
+
+// findOrphans returns the definitions which have no relationships in either direction
+func findOrphans(d Dictionary, df *DefinitionFormat, gc *GraphCheck) (orphans []DefinitionKey) {
+	connected := map[DefinitionKey]bool{}
+	for _, e := range getEdges(d, df) {
+		if gc.includesRelationship(e.relationship) {
+			connected[e.from] = true
+			connected[e.to] = true
+		}
+	}
+
+	for _, class := range sortedClasses(d) {
+		if !gc.includesClass(class) {
+			continue
+		}
+		for _, id := range sortedIDs(d[class]) {
+			if k := (DefinitionKey{Class: class, ID: id}); !connected[k] {
+				orphans = append(orphans, k)
+			}
+		}
+	}
+	return orphans
+}
+
+// findUnreachable performs a breadth-first search, ignoring direction, from each definition of the root classes
+func findUnreachable(d Dictionary, df *DefinitionFormat, uc *UnreachableCheck) (unreachable []DefinitionKey) {
+	adjacency := map[DefinitionKey][]DefinitionKey{}
+	for _, e := range getEdges(d, df) {
+		if uc.includesRelationship(e.relationship) {
+			adjacency[e.from] = append(adjacency[e.from], e.to)
+			adjacency[e.to] = append(adjacency[e.to], e.from)
+		}
+	}
+
+	reached := map[DefinitionKey]bool{}
+	var queue []DefinitionKey
+	for _, class := range uc.RootClasses {
+		for _, id := range sortedIDs(d[class]) {
+			k := DefinitionKey{Class: class, ID: id}
+			reached[k] = true
+			queue = append(queue, k)
+		}
+	}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacency[k] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for _, class := range sortedClasses(d) {
+		if !uc.includesClass(class) {
+			continue
+		}
+		for _, id := range sortedIDs(d[class]) {
+			if k := (DefinitionKey{Class: class, ID: id}); !reached[k] {
+				unreachable = append(unreachable, k)
+			}
+		}
+	}
+	return unreachable
+}
+
+// findDuplicateEdges returns any edge which duplicates an earlier edge; undirected edges are considered duplicates
+// regardless of which definition declared them
+func findDuplicateEdges(d Dictionary, df *DefinitionFormat, gc *GraphCheck) (duplicates []edge) {
+	seen := map[string]bool{}
+	for _, e := range getEdges(d, df) {
+		if !gc.includesRelationship(e.relationship) || !gc.includesClass(e.from.Class) {
+			continue
+		}
+
+		from, to := e.from, e.to
+		if e.undirected && to.less(from) {
+			from, to = to, from
+		}
+		signature := fmt.Sprintf("%s|%s|%s|%t", from, to, e.relationship, e.undirected)
+		if seen[signature] {
+			duplicates = append(duplicates, e)
+		}
+		seen[signature] = true
+	}
+	return duplicates
+}
+
+// validateGraph performs each of the configured graph checks; the Severity of each check is used as the default
+// severity for its findings
+func (v *validator) validateGraph() {
+	if v.df == nil || v.df.GraphChecks == nil {
+		return
+	}
+	gcs := v.df.GraphChecks
+
+	if gc := gcs.Cycles; gc != nil && v.validCheckSeverity(checkCycles, gc) {
+		for _, cycle := range findCycles(v.d, v.df, gc) {
+			v.report(RuleCycle, gc.Severity, fmt.Sprintf(logCycleFound, gc.Relationships, formatCycle(cycle)), cycle...)
+		}
+	}
+
+	if gc := gcs.Orphans; gc != nil && v.validCheckSeverity(checkOrphans, gc) {
+		for _, k := range findOrphans(v.d, v.df, gc) {
+			v.report(RuleOrphan, gc.Severity, fmt.Sprintf(logOrphanFound, k.ID, k.Class), k)
+		}
+	}
+
+	if uc := gcs.Unreachable; uc != nil && v.validCheckSeverity(checkUnreachable, &uc.GraphCheck) {
+		for _, k := range findUnreachable(v.d, v.df, uc) {
+			v.report(RuleUnreachable, uc.Severity, fmt.Sprintf(logUnreachableFound, k.ID, k.Class, uc.RootClasses), k)
+		}
+	}
+
+	if gc := gcs.DuplicateEdges; gc != nil && v.validCheckSeverity(checkDuplicateEdges, gc) {
+		for _, e := range findDuplicateEdges(v.d, v.df, gc) {
+			v.report(RuleDuplicateEdge, gc.Severity, fmt.Sprintf(logDuplicateEdgeFound, e.relationship, e.from.ID,
+				e.from.Class, e.to.ID, e.to.Class), e.from)
+		}
+	}
+}
+
+func (v *validator) validCheckSeverity(name string, gc *GraphCheck) bool {
+	if !gc.Severity.valid() {
+		v.report(RuleInvalidFormat, SeverityError, fmt.Sprintf(logWarnInvalidCheckConfig, name, gc.Severity))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_findCycles(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graphChecks"}, "yaml")
+	cycles := findCycles(d, nil, &GraphCheck{Relationships: []string{"CHILD_OF"}})
+	assert.Equal(t, [][]DefinitionKey{
+		{{Class: "Capability", ID: "a"}, {Class: "Capability", ID: "b"}, {Class: "Capability", ID: "c"}},
+		{{Class: "Capability", ID: "d"}},
+	}, cycles)
+	assert.Equal(t, "Capability/a -> Capability/b -> Capability/c -> Capability/a", formatCycle(cycles[0]))
+
+	assert.Equal(t, [][]DefinitionKey{{{Class: "Capability", ID: "d"}, {Class: "Provider", ID: "azure"}}},
+		findCycles(d, nil, &GraphCheck{Relationships: []string{"PROVIDED_BY"}}))
+	assert.Nil(t, findCycles(d, nil, &GraphCheck{Relationships: []string{"OTHER"}}))
+}
+
+func Test_findOrphans(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graphChecks"}, "yaml")
+	assert.Equal(t, []DefinitionKey{{Class: "Provider", ID: "aws"}},
+		findOrphans(d, nil, &GraphCheck{}))
+	assert.Equal(t, []DefinitionKey{
+		{Class: "Provider", ID: "aws"}, {Class: "Provider", ID: "azure"}, {Class: "Service", ID: "vm"},
+	}, findOrphans(d, nil, &GraphCheck{Relationships: []string{"CHILD_OF"}}))
+	assert.Nil(t, findOrphans(d, nil, &GraphCheck{Classes: []string{"Capability"}}))
+}
+
+func Test_findUnreachable(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graphChecks"}, "yaml")
+	assert.Equal(t, []DefinitionKey{
+		{Class: "Capability", ID: "a"}, {Class: "Capability", ID: "b"}, {Class: "Capability", ID: "c"},
+		{Class: "Provider", ID: "aws"},
+	}, findUnreachable(d, nil, &UnreachableCheck{RootClasses: []string{"Service"}}))
+	assert.Equal(t, []DefinitionKey{{Class: "Provider", ID: "aws"}},
+		findUnreachable(d, nil, &UnreachableCheck{
+			GraphCheck:  GraphCheck{Classes: []string{"Provider"}},
+			RootClasses: []string{"Service"},
+		}))
+}
+
+func Test_findDuplicateEdges(t *testing.T) {
+	assert.Equal(t, []edge{
+		{
+			from:         DefinitionKey{Class: "Capability", ID: "d"},
+			to:           DefinitionKey{Class: "Provider", ID: "azure"},
+			relationship: "PROVIDED_BY",
+			undirected:   true,
+		},
+		{
+			from:         DefinitionKey{Class: "Provider", ID: "azure"},
+			to:           DefinitionKey{Class: "Capability", ID: "d"},
+			relationship: "PROVIDED_BY",
+			undirected:   true,
+		},
+	}, findDuplicateEdges(LoadDictionary([]string{"_test/graphChecks"}, "yaml"), nil, &GraphCheck{}))
+}
+
+func Test_validateGraph(t *testing.T) {
+	validateGraph := func(gcs *GraphChecks) *ValidationResult {
+		v := newValidator(LoadDictionary([]string{"_test/graphChecks"}, "yaml"), &DefinitionFormat{GraphChecks: gcs})
+		v.validateGraph()
+		return v.result
+	}
+
+	assert.Empty(t, validateGraph(nil).Findings)
+	assert.Equal(t, 2, validateGraph(&GraphChecks{Cycles: &GraphCheck{Relationships: []string{"CHILD_OF"}}}).Errors())
+
+	result := validateGraph(&GraphChecks{
+		Cycles:         &GraphCheck{Relationships: []string{"CHILD_OF"}, Severity: SeverityWarning},
+		Orphans:        &GraphCheck{Severity: SeverityInfo},
+		Unreachable:    &UnreachableCheck{GraphCheck: GraphCheck{Severity: SeverityWarning}, RootClasses: []string{"Service"}},
+		DuplicateEdges: &GraphCheck{Severity: SeverityWarning},
+	})
+	assert.Equal(t, 0, result.Errors())
+	assert.Equal(t, 8, result.Warnings())
+	assert.Equal(t, 1, result.Count(SeverityInfo))
+
+	assert.Equal(t, 7, validateGraph(&GraphChecks{
+		Orphans:        &GraphCheck{},
+		Unreachable:    &UnreachableCheck{RootClasses: []string{"Service"}},
+		DuplicateEdges: &GraphCheck{},
+	}).Errors())
+	assert.Equal(t, 0, validateGraph(&GraphChecks{
+		Orphans: &GraphCheck{Severity: SeverityOff},
+	}).Errors())
+
+	result = validateGraph(&GraphChecks{Orphans: &GraphCheck{Severity: "fatal"}})
+	assert.Equal(t, 1, result.Errors())
+	assert.Equal(t, RuleInvalidFormat, result.Findings[0].RuleID)
+}
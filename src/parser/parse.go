@@ -38,6 +38,7 @@ const (
 	logWarnMandatoryFieldMissing    = "mandatory field [%s] missing in definition ID [%s] for class [%s]"
 	logWarnMandatoryFieldNotAString = "mandatory field [%s] is not a string in definition ID [%s] for class [%s]"
 	logWarnAdditionalFieldFound     = "field [%s] is not a valid field in definition ID [%s] for class [%s]"
+	logWarnComputedFieldDefined     = "computed field [%s] is defined in definition ID [%s] for class [%s], so is never computed"
 	logWarnDuplicateDefinitionFound = "duplicate ID [%s] for class [%s] found; only the most recent definition will be kept"
 	logWarnSubdefinitionErrorsFound = "errors loading subdefinitions for ID [%s] for class [%s]"
 
@@ -55,6 +56,12 @@ type (
 	DictionaryDefinition struct {
 		Fields     definition.Fields
 		References []definition.Reference
+
+		// Suppressions holds the validation rule IDs ignored for this definition
+		Suppressions []string
+
+		// computed holds the names of the Fields which were computed rather than defined
+		computed map[string]bool
 	}
 
 	// Dictionary is a map of classes, keyed by class name; the value is a map of definitions keyed by
@@ -67,6 +74,12 @@ type (
 	DefinitionFormat struct {
 		// ClassFormat is a map of classes keyed by class name; the value is format of each class
 		ClassFormat map[string]*ClassDefinitionFormat `yaml:"Class"`
+
+		// GraphChecks configures the optional graph integrity checks
+		GraphChecks *GraphChecks `yaml:"GraphChecks,omitempty"`
+
+		// Severities overrides the default severity of validation rules, keyed by rule ID
+		Severities map[string]Severity `yaml:"Severities,omitempty"`
 	}
 
 	// ClassField TODO
@@ -74,11 +87,30 @@ type (
 		Description string `yaml:"Description,omitempty"`
 	}
 
+	// ComputedField is a field whose value is derived from an expression when the definitions are loaded
+	ComputedField struct {
+		Description string `yaml:"Description,omitempty"`
+
+		// Expression is evaluated over the fields and references of each definition of the class
+		Expression string `yaml:"Expression"`
+	}
+
 	// ClassDefinitionFormat TODO
 	ClassDefinitionFormat struct {
-		Description     string                `yaml:"Description,omitempty"`
-		MandatoryFields map[string]ClassField `yaml:"MandatoryFields"`
-		OptionalFields  map[string]ClassField `yaml:"OptionalFields"`
+		Description string `yaml:"Description,omitempty"`
+
+		// Extends names the base class whose fields, computed fields and rules are inherited by this class
+		Extends string `yaml:"Extends,omitempty"`
+
+		// Abstract classes are only used as bases for other classes and cannot have definitions of their own
+		Abstract bool `yaml:"Abstract,omitempty"`
+
+		MandatoryFields map[string]ClassField    `yaml:"MandatoryFields"`
+		OptionalFields  map[string]ClassField    `yaml:"OptionalFields"`
+		ComputedFields  map[string]ComputedField `yaml:"ComputedFields,omitempty"`
+
+		// Rules are evaluated against each definition of the class during validation
+		Rules []Rule `yaml:"Rules,omitempty"`
 	}
 )
 
@@ -103,8 +135,9 @@ func loadSpecification(s definition.Specification, d Dictionary, parentRef *defi
 		}
 
 		d[s.Class][dfnID] = &DictionaryDefinition{
-			Fields:     dfn.Fields,
-			References: dfn.References,
+			Fields:       dfn.Fields,
+			References:   dfn.References,
+			Suppressions: dfn.Suppressions,
 		}
 	}
 
@@ -145,6 +178,11 @@ func loadSpecification(s definition.Specification, d Dictionary, parentRef *defi
 	return nil
 }
 
+// AddSpecification adds the definitions within the Specification, and any sub-definitions, to the Dictionary
+func AddSpecification(d Dictionary, s definition.Specification) error {
+	return loadSpecification(s, d, nil)
+}
+
 // LoadDictionary TODO
 func LoadDictionary(sourceDir []string, fileExtension string) Dictionary {
 	d := make(Dictionary)
@@ -169,7 +207,20 @@ func LoadDictionary(sourceDir []string, fileExtension string) Dictionary {
 	return d
 }
 
+// fieldTypeValid indicates whether the field is a scalar, or a list of scalars, which can be a node property
 func fieldTypeValid(f interface{}) bool {
+	if l, ok := f.([]interface{}); ok {
+		for _, v := range l {
+			if !scalarTypeValid(v) {
+				return false
+			}
+		}
+		return true
+	}
+	return scalarTypeValid(f)
+}
+
+func scalarTypeValid(f interface{}) bool {
 	if f == nil {
 		return false
 	}
@@ -204,40 +255,66 @@ func fieldValidForType(f interface{}, ft fieldType) bool {
 
 // ValidateDictionary TODO
 func ValidateDictionary(d Dictionary, df *DefinitionFormat) error {
-	errorsFound := 0
+	return Validate(d, df).Err(-1)
+}
+
+// Validate validates the Dictionary against the DefinitionFormat, returning all of the findings
+func Validate(d Dictionary, df *DefinitionFormat) *ValidationResult {
+	v := newValidator(d, df)
+	v.validateSeverities()
 
 	if df != nil {
-		for class, classFormat := range df.ClassFormat {
-			for dID, definition := range d[class] {
+		for _, class := range sortedClassFormats(df) {
+			classFormat := df.ClassFormat[class]
+			if classFormat == nil {
+				continue
+			}
+
+			if classFormat.Abstract {
+				for _, dID := range sortedIDs(d[class]) {
+					v.report(RuleAbstractClass, SeverityError, fmt.Sprintf(logWarnAbstractClassUsed, dID, class),
+						DefinitionKey{Class: class, ID: dID})
+				}
+			}
+
+			for _, dID := range sortedIDs(d[class]) {
+				definition := d[class][dID]
+				key := DefinitionKey{Class: class, ID: dID}
+
 				// first check that each field in the definition is either a mandatory or optional field...
 				for defField := range definition.Fields {
 					_, isMandatoryField := classFormat.MandatoryFields[defField]
 					_, isOptionalField := classFormat.OptionalFields[defField]
-					if !isMandatoryField && !isOptionalField {
-						log.Warn().Msg(fmt.Sprintf(logWarnAdditionalFieldFound, defField, dID, class))
-						errorsFound++
+					_, isComputedField := classFormat.ComputedFields[defField]
+					if !isMandatoryField && !isOptionalField && !isComputedField {
+						v.report(RuleAdditionalField, SeverityError,
+							fmt.Sprintf(logWarnAdditionalFieldFound, defField, dID, class), key)
+					}
+					if isComputedField && !definition.computed[defField] {
+						v.report(RuleComputedFieldDefined, SeverityError,
+							fmt.Sprintf(logWarnComputedFieldDefined, defField, dID, class), key)
 					}
 				}
 
 				// ...then validate each of the mandatory fields exists within the definition
 				for f := range classFormat.MandatoryFields {
 					if definition.Fields[f] == nil {
-						log.Warn().Msg(fmt.Sprintf(logWarnMandatoryFieldMissing, f, dID, class))
-						errorsFound++
+						v.report(RuleMandatoryFieldMissing, SeverityError,
+							fmt.Sprintf(logWarnMandatoryFieldMissing, f, dID, class), key)
 					} else {
 						// mandatory field exists - now check its type is valid
 						// TODO add checking for other types
 						if !fieldValidForType(definition.Fields[f], stringField) {
-							log.Warn().Msg(fmt.Sprintf(logWarnMandatoryFieldNotAString, f, dID, class))
-							errorsFound++
+							v.report(RuleMandatoryFieldNotString, SeverityError,
+								fmt.Sprintf(logWarnMandatoryFieldNotAString, f, dID, class), key)
 						}
 
 						// additional 'empty string' check for mandatory string fields
 						s, ok := definition.Fields[f].(string)
 						if ok {
 							if strings.TrimSpace(s) == "" {
-								log.Warn().Msg(fmt.Sprintf(logWarnMandatoryFieldMissing, f, dID, class))
-								errorsFound++
+								v.report(RuleMandatoryFieldMissing, SeverityError,
+									fmt.Sprintf(logWarnMandatoryFieldMissing, f, dID, class), key)
 							}
 						}
 					}
@@ -247,24 +324,24 @@ func ValidateDictionary(d Dictionary, df *DefinitionFormat) error {
 	}
 
 	// for each definition in the dictionary, ensure that the references are valid
-	for _, definitions := range d {
-		for _, definition := range definitions {
-			for _, ref := range definition.References {
-				if d[ref.Class] == nil {
-					log.Warn().Msg(fmt.Sprintf(logWarnCannotFindClass, ref.Class))
-					errorsFound++
-				} else if d[ref.Class][ref.ID] == nil {
-					log.Warn().Msg(fmt.Sprintf(logWarnCannotFindDefinition, ref.ID, ref.Class))
-					errorsFound++
+	for _, class := range sortedClasses(d) {
+		for _, dID := range sortedIDs(d[class]) {
+			key := DefinitionKey{Class: class, ID: dID}
+			for _, ref := range d[class][dID].References {
+				classFound, definitionFound := v.resolveReference(ref)
+				if !classFound {
+					v.report(RuleUnknownClass, SeverityError, fmt.Sprintf(logWarnCannotFindClass, ref.Class), key)
+				} else if !definitionFound {
+					v.report(RuleUnknownDefinition, SeverityError,
+						fmt.Sprintf(logWarnCannotFindDefinition, ref.ID, ref.Class), key)
 				}
 			}
 		}
 	}
 
-	if errorsFound > 0 {
-		log.Error().Msg(fmt.Sprintf(errorDefinitionErrorsFound, errorsFound))
-		return fmt.Errorf(errorDefinitionErrorsFound, errorsFound)
-	}
+	// finally evaluate any rules and graph checks declared in the definition format
+	v.validateRules()
+	v.validateGraph()
 
-	return nil
+	return v.result
 }
@@ -549,6 +549,14 @@ func Test_fieldTypeValid(t *testing.T) {
 		assert.False(t, fieldTypeValid(nil))
 		assert.False(t, fieldTypeValid(time.Now()))
 	})
+	t.Run("ListType", func(t *testing.T) {
+		assert.True(t, fieldTypeValid([]interface{}{"a", "b"}))
+		assert.True(t, fieldTypeValid([]interface{}{int64(1), 2.5}))
+		assert.True(t, fieldTypeValid([]interface{}{}))
+		assert.False(t, fieldTypeValid([]interface{}{"a", nil}))
+		assert.False(t, fieldTypeValid([]interface{}{map[string]interface{}{"Name": "a"}}))
+		assert.False(t, fieldTypeValid([]interface{}{[]interface{}{"a"}}))
+	})
 }
 
 func Test_fieldValidForType(t *testing.T) {
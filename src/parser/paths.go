@@ -0,0 +1,250 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	pathRelationshipTo   = " -[%s]-> "
+	pathRelationshipFrom = " <-[%s]- "
+)
+
+type (
+	// PathOptions restricts the paths found between two definitions
+	PathOptions struct {
+		// MaxLength is the maximum number of relationships in a path; zero means no limit
+		MaxLength int
+
+		// Relationships restricts the relationships followed; defaults to all relationships
+		Relationships []string
+	}
+
+	// PathStep is a single relationship followed within a path
+	PathStep struct {
+		Relationship string
+
+		// Reversed is set if the reference is held by the definition the step leads to, rather than the one it
+		// leads from, i.e. the relationship was followed against its direction
+		Reversed bool `json:",omitempty"`
+
+		To DefinitionKey
+	}
+
+	// Path is a sequence of relationships from the Start definition
+	Path struct {
+		Start DefinitionKey
+		Steps []PathStep
+	}
+
+	// Relationship is a single relationship between two definitions, directed from the definition holding the
+	// reference
+	Relationship struct {
+		From DefinitionKey
+		To   DefinitionKey
+		Name string
+	}
+
+	// Neighbour is a definition within the neighbourhood of another, together with the number of relationships
+	// followed to reach it
+	Neighbour struct {
+		DefinitionKey
+		Depth int
+
+		// Missing is set for references to definitions which do not exist
+		Missing bool `json:",omitempty"`
+	}
+
+	// Neighbourhood holds the definitions within a number of relationships of a definition, in either direction,
+	// together with every relationship between them
+	Neighbourhood struct {
+		Definitions   []Neighbour
+		Relationships []Relationship
+	}
+
+	// adjacent is a definition reachable in a single step
+	adjacent struct {
+		key  DefinitionKey
+		step PathStep
+	}
+)
+
+// Relationships returns each of the relationships in the path
+func (p Path) Relationships() []Relationship {
+	rels := make([]Relationship, 0, len(p.Steps))
+	from := p.Start
+	for _, s := range p.Steps {
+		r := Relationship{From: from, To: s.To, Name: s.Relationship}
+		if s.Reversed {
+			r.From, r.To = r.To, r.From
+		}
+		rels = append(rels, r)
+		from = s.To
+	}
+	return rels
+}
+
+func (p Path) String() string {
+	var sb strings.Builder
+	sb.WriteString(p.Start.String())
+	for _, s := range p.Steps {
+		format := pathRelationshipTo
+		if s.Reversed {
+			format = pathRelationshipFrom
+		}
+		sb.WriteString(fmt.Sprintf(format, s.Relationship))
+		sb.WriteString(s.To.String())
+	}
+	return sb.String()
+}
+
+// getAdjacency returns the definitions reachable in a single step from each definition, following relationships in
+// either direction, in a deterministic order
+func getAdjacency(d Dictionary, df *DefinitionFormat, relationships []string) map[DefinitionKey][]adjacent {
+	gc := &GraphCheck{Relationships: relationships}
+	adjacency := map[DefinitionKey][]adjacent{}
+	for _, e := range getEdges(d, df) {
+		if !gc.includesRelationship(e.relationship) {
+			continue
+		}
+		adjacency[e.from] = append(adjacency[e.from], adjacent{key: e.to,
+			step: PathStep{Relationship: e.relationship, To: e.to}})
+		adjacency[e.to] = append(adjacency[e.to], adjacent{key: e.from,
+			step: PathStep{Relationship: e.relationship, Reversed: true, To: e.from}})
+	}
+	for k := range adjacency {
+		a := adjacency[k]
+		sort.SliceStable(a, func(i, j int) bool { return a[i].key.less(a[j].key) })
+	}
+	return adjacency
+}
+
+// ShortestPath returns a shortest path between the definitions, following relationships in either direction, or nil
+// if there is none. The DefinitionFormat is optional, and resolves references to a base class to the definitions of
+// its subclasses.
+func ShortestPath(d Dictionary, df *DefinitionFormat, from, to DefinitionKey, opts PathOptions) *Path {
+	adjacency := getAdjacency(d, df, opts.Relationships)
+	from, _ = d.Resolve(df, from)
+	to, _ = d.Resolve(df, to)
+
+	previous := map[DefinitionKey]adjacent{}
+	visited := map[DefinitionKey]bool{from: true}
+	level := []DefinitionKey{from}
+	for length := 1; len(level) > 0 && !visited[to] && (opts.MaxLength <= 0 || length <= opts.MaxLength); length++ {
+		var next []DefinitionKey
+		for _, k := range level {
+			for _, a := range adjacency[k] {
+				if visited[a.key] {
+					continue
+				}
+				visited[a.key] = true
+				previous[a.key] = adjacent{key: k, step: a.step}
+				next = append(next, a.key)
+			}
+		}
+		level = next
+	}
+
+	if !visited[to] {
+		return nil
+	}
+	p := &Path{Start: from}
+	for k := to; k != from; k = previous[k].key {
+		p.Steps = append([]PathStep{previous[k].step}, p.Steps...)
+	}
+	return p
+}
+
+// AllPaths returns every simple path between the definitions, following relationships in either direction, sorted
+// by length
+func AllPaths(d Dictionary, df *DefinitionFormat, from, to DefinitionKey, opts PathOptions) (paths []Path) {
+	adjacency := getAdjacency(d, df, opts.Relationships)
+	from, _ = d.Resolve(df, from)
+	to, _ = d.Resolve(df, to)
+
+	onPath := map[DefinitionKey]bool{from: true}
+	var steps []PathStep
+	var visit func(k DefinitionKey)
+	visit = func(k DefinitionKey) {
+		if k == to && len(steps) > 0 {
+			paths = append(paths, Path{Start: from, Steps: append([]PathStep{}, steps...)})
+			return
+		}
+		if opts.MaxLength > 0 && len(steps) >= opts.MaxLength {
+			return
+		}
+		for _, a := range adjacency[k] {
+			if onPath[a.key] {
+				continue
+			}
+			onPath[a.key] = true
+			steps = append(steps, a.step)
+			visit(a.key)
+			steps = steps[:len(steps)-1]
+			onPath[a.key] = false
+		}
+	}
+	if from != to {
+		visit(from)
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i].Steps) < len(paths[j].Steps) })
+	return paths
+}
+
+// Neighbours returns the definitions within depth relationships of the definition, following relationships in either
+// direction; a depth of zero means no limit
+func Neighbours(d Dictionary, df *DefinitionFormat, key DefinitionKey, depth int,
+	relationships []string) *Neighbourhood {
+	adjacency := getAdjacency(d, df, relationships)
+	key, _ = d.Resolve(df, key)
+
+	n := &Neighbourhood{}
+	depths := map[DefinitionKey]int{key: 0}
+	level := []DefinitionKey{key}
+	n.Definitions = append(n.Definitions, Neighbour{DefinitionKey: key, Missing: d[key.Class][key.ID] == nil})
+	for l := 1; len(level) > 0 && (depth <= 0 || l <= depth); l++ {
+		var next []DefinitionKey
+		for _, k := range level {
+			for _, a := range adjacency[k] {
+				if _, found := depths[a.key]; found {
+					continue
+				}
+				depths[a.key] = l
+				n.Definitions = append(n.Definitions, Neighbour{DefinitionKey: a.key, Depth: l,
+					Missing: d[a.key.Class][a.key.ID] == nil})
+				if d[a.key.Class][a.key.ID] != nil {
+					next = append(next, a.key)
+				}
+			}
+		}
+		level = next
+	}
+
+	for _, e := range getEdges(d, df) {
+		_, fromFound := depths[e.from]
+		_, toFound := depths[e.to]
+		if fromFound && toFound && (len(relationships) == 0 || contains(relationships, e.relationship)) {
+			n.Relationships = append(n.Relationships, Relationship{From: e.from, To: e.to, Name: e.relationship})
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShortestPath(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+	disk := DefinitionKey{Class: "Component", ID: "disk"}
+	compute := DefinitionKey{Class: "Category", ID: "compute"}
+
+	p := ShortestPath(d, nil, disk, compute, PathOptions{})
+	assert.Equal(t, "Component/disk -[PART_OF]-> Service/vm -[HOSTED_BY]-> Provider/azure -[IN]-> Category/compute",
+		p.String())
+	assert.Equal(t, []Relationship{
+		{From: disk, To: DefinitionKey{Class: "Service", ID: "vm"}, Name: "PART_OF"},
+		{From: DefinitionKey{Class: "Service", ID: "vm"}, To: DefinitionKey{Class: "Provider", ID: "azure"}, Name: "HOSTED_BY"},
+		{From: DefinitionKey{Class: "Provider", ID: "azure"}, To: compute, Name: "IN"},
+	}, p.Relationships())
+
+	// relationships are followed in either direction
+	p = ShortestPath(d, nil, compute, disk, PathOptions{})
+	assert.Equal(t, "Category/compute <-[IN]- Provider/azure <-[HOSTED_BY]- Service/vm <-[PART_OF]- Component/disk",
+		p.String())
+	assert.Equal(t, disk, p.Relationships()[2].From)
+
+	assert.Nil(t, ShortestPath(d, nil, disk, compute, PathOptions{MaxLength: 2}))
+	assert.Nil(t, ShortestPath(d, nil, disk, compute, PathOptions{Relationships: []string{"PART_OF", "HOSTED_BY"}}))
+	assert.Nil(t, ShortestPath(d, nil, disk, DefinitionKey{Class: "Provider", ID: "gcp"}, PathOptions{}))
+}
+
+func Test_AllPaths(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+	disk := DefinitionKey{Class: "Component", ID: "disk"}
+	azure := DefinitionKey{Class: "Provider", ID: "azure"}
+
+	var paths []string
+	for _, p := range AllPaths(d, nil, disk, azure, PathOptions{}) {
+		paths = append(paths, p.String())
+	}
+	assert.Equal(t, []string{
+		"Component/disk -[PART_OF]-> Service/vm -[HOSTED_BY]-> Provider/azure",
+		"Component/disk -[PART_OF]-> Service/vm <-[USES]- Service/aks -[HOSTED_BY]-> Provider/azure",
+	}, paths)
+
+	assert.Len(t, AllPaths(d, nil, disk, azure, PathOptions{MaxLength: 2}), 1)
+	assert.Len(t, AllPaths(d, nil, disk, azure, PathOptions{Relationships: []string{"PART_OF", "USES"}}), 0)
+	assert.Len(t, AllPaths(d, nil, disk, disk, PathOptions{}), 0)
+}
+
+func Test_Neighbours(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+	vm := DefinitionKey{Class: "Service", ID: "vm"}
+
+	n := Neighbours(d, nil, vm, 1, nil)
+	assert.Equal(t, []Neighbour{
+		{DefinitionKey: vm},
+		{DefinitionKey: DefinitionKey{Class: "Component", ID: "disk"}, Depth: 1},
+		{DefinitionKey: DefinitionKey{Class: "Provider", ID: "azure"}, Depth: 1},
+		{DefinitionKey: DefinitionKey{Class: "Service", ID: "aks"}, Depth: 1},
+	}, n.Definitions)
+	assert.Equal(t, []Relationship{
+		{From: DefinitionKey{Class: "Component", ID: "disk"}, To: vm, Name: "PART_OF"},
+		{From: DefinitionKey{Class: "Service", ID: "aks"}, To: DefinitionKey{Class: "Provider", ID: "azure"}, Name: "HOSTED_BY"},
+		{From: DefinitionKey{Class: "Service", ID: "aks"}, To: vm, Name: "USES"},
+		{From: vm, To: DefinitionKey{Class: "Provider", ID: "azure"}, Name: "HOSTED_BY"},
+	}, n.Relationships)
+
+	n = Neighbours(d, nil, vm, 0, []string{"PART_OF"})
+	assert.Len(t, n.Definitions, 3)
+	assert.Equal(t, Neighbour{DefinitionKey: DefinitionKey{Class: "Service", ID: "gone"}, Depth: 2, Missing: true},
+		n.Definitions[2])
+	assert.Len(t, n.Relationships, 2)
+}
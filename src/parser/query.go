@@ -0,0 +1,550 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nextmetaphor/yaml-graph/expression"
+)
+
+const (
+	queryAnyClass      = "*"
+	queryAllFields     = "*"
+	queryExpression    = '?'
+	queryOutgoing      = "->"
+	queryIncoming      = "<-"
+	queryEitherWay     = "-"
+	queryOperatorChars = "=!~<>"
+
+	errorQueryEmpty              = "query is empty"
+	errorQueryUnexpected         = "unexpected [%s] at position %d in query"
+	errorQueryExpected           = "expected %s at position %d in query"
+	errorQueryUnterminatedString = "unterminated string starting at position %d in query"
+	errorQueryInvalidOperator    = "invalid operator [%s] at position %d in query"
+	errorQueryInvalidRegExp      = "invalid regular expression [%s] in query"
+)
+
+// queryDirection indicates which way relationships are followed between the steps of a query
+type queryDirection int
+
+const (
+	directionOutgoing queryDirection = iota
+	directionIncoming
+	directionEither
+)
+
+type (
+	// queryPredicate compares a field, or tests a relationship to a definition of a class, e.g. Name=vm or
+	// Provider=azure; a predicate without an operator tests for the presence of the field or relationship
+	queryPredicate struct {
+		name     string
+		operator string
+		value    string
+		quoted   bool
+		regExp   *regexp.Regexp
+	}
+
+	// queryStep selects the definitions of a class which satisfy all of its predicates
+	queryStep struct {
+		class      string
+		predicates []queryPredicate
+		expression *expression.Expression
+
+		// relationship and direction describe how this step is reached from the previous step
+		relationship string
+		direction    queryDirection
+	}
+
+	// Query is a parsed selector, such as Service[Provider=azure]->TYPE_OF->Category{ID, Name}, which can be
+	// evaluated against any Dictionary
+	Query struct {
+		source  string
+		steps   []queryStep
+		columns []string
+	}
+
+	// QueryResult holds a row for each definition selected by a query, with a value for each column
+	QueryResult struct {
+		Columns []string
+		Rows    [][]interface{}
+	}
+
+	queryParser struct {
+		source []rune
+		pos    int
+	}
+)
+
+func isQueryNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func (p *queryParser) skipSpace() {
+	for p.pos < len(p.source) && unicode.IsSpace(p.source[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *queryParser) peek(s string) bool {
+	p.skipSpace()
+	return strings.HasPrefix(string(p.source[p.pos:]), s)
+}
+
+func (p *queryParser) consume(s string) bool {
+	if p.peek(s) {
+		p.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(s string) error {
+	if !p.consume(s) {
+		return fmt.Errorf(errorQueryExpected, "["+s+"]", p.pos)
+	}
+	return nil
+}
+
+func (p *queryParser) name(allowAny bool) (string, error) {
+	p.skipSpace()
+	if allowAny && p.consume(queryAnyClass) {
+		return queryAnyClass, nil
+	}
+	start := p.pos
+	for p.pos < len(p.source) && isQueryNameRune(p.source[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", fmt.Errorf(errorQueryExpected, "a name", start)
+	}
+	return string(p.source[start:p.pos]), nil
+}
+
+// value parses a quoted string or a bare value, which extends to the next comma or closing bracket
+func (p *queryParser) value() (string, bool, error) {
+	p.skipSpace()
+	if p.pos < len(p.source) && (p.source[p.pos] == '"' || p.source[p.pos] == '\'') {
+		quote, start := p.source[p.pos], p.pos
+		var sb strings.Builder
+		for p.pos++; p.pos < len(p.source); p.pos++ {
+			switch r := p.source[p.pos]; {
+			case r == '\\' && p.pos+1 < len(p.source):
+				p.pos++
+				sb.WriteRune(p.source[p.pos])
+			case r == quote:
+				p.pos++
+				return sb.String(), true, nil
+			default:
+				sb.WriteRune(r)
+			}
+		}
+		return "", false, fmt.Errorf(errorQueryUnterminatedString, start)
+	}
+
+	start := p.pos
+	for p.pos < len(p.source) && p.source[p.pos] != ',' && p.source[p.pos] != ']' {
+		p.pos++
+	}
+	return strings.TrimSpace(string(p.source[start:p.pos])), false, nil
+}
+
+func (p *queryParser) predicate() (pr queryPredicate, err error) {
+	if pr.name, err = p.name(false); err != nil {
+		return pr, err
+	}
+
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.source) && strings.ContainsRune(queryOperatorChars, p.source[p.pos]) {
+		p.pos++
+	}
+	pr.operator = string(p.source[start:p.pos])
+	switch pr.operator {
+	case "":
+		return pr, nil
+	case "=", "!=", "~", "<", "<=", ">", ">=":
+	default:
+		return pr, fmt.Errorf(errorQueryInvalidOperator, pr.operator, start)
+	}
+
+	if pr.value, pr.quoted, err = p.value(); err != nil {
+		return pr, err
+	}
+	if pr.operator == "~" {
+		if pr.regExp, err = regexp.Compile(pr.value); err != nil {
+			return pr, fmt.Errorf(errorQueryInvalidRegExp, pr.value)
+		}
+	}
+	return pr, nil
+}
+
+// expression parses the source of an expression up to the closing bracket, skipping over brackets and strings
+// within it
+func (p *queryParser) expression() (*expression.Expression, error) {
+	start, depth := p.pos, 0
+	var quote rune
+	for ; p.pos < len(p.source); p.pos++ {
+		r := p.source[p.pos]
+		switch {
+		case quote != 0:
+			if r == '\\' {
+				p.pos++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ']':
+			if depth == 0 {
+				return expression.Parse(string(p.source[start:p.pos]))
+			}
+			depth--
+		}
+	}
+	return nil, fmt.Errorf(errorQueryExpected, "[]]", p.pos)
+}
+
+func (p *queryParser) step() (s queryStep, err error) {
+	if s.class, err = p.name(true); err != nil {
+		return s, err
+	}
+	if !p.consume("[") {
+		return s, nil
+	}
+
+	if p.consume(string(queryExpression)) {
+		if s.expression, err = p.expression(); err != nil {
+			return s, err
+		}
+		return s, p.expect("]")
+	}
+
+	for {
+		pr, err := p.predicate()
+		if err != nil {
+			return s, err
+		}
+		s.predicates = append(s.predicates, pr)
+		if !p.consume(",") {
+			break
+		}
+	}
+	return s, p.expect("]")
+}
+
+// link parses the relationship between two steps: ->, <- or - optionally naming the relationship, e.g. ->TYPE_OF->
+func (p *queryParser) link() (direction queryDirection, relationship string, found bool) {
+	var marker string
+	switch {
+	case p.consume(queryOutgoing):
+		direction, marker = directionOutgoing, queryOutgoing
+	case p.consume(queryIncoming):
+		direction, marker = directionIncoming, queryIncoming
+	case p.consume(queryEitherWay):
+		direction, marker = directionEither, queryEitherWay
+	default:
+		return 0, "", false
+	}
+
+	// a relationship name is only present if it is followed by a second marker
+	save := p.pos
+	if name, nameErr := p.name(false); nameErr == nil && p.consume(marker) {
+		return direction, name, true
+	}
+	p.pos = save
+	return direction, "", true
+}
+
+// ParseQuery parses a selector of the form Class[predicates]->RELATIONSHIP->Class[predicates]{columns}
+func ParseQuery(source string) (*Query, error) {
+	q := &Query{source: source}
+	p := &queryParser{source: []rune(source)}
+	if p.skipSpace(); p.pos == len(p.source) {
+		return nil, fmt.Errorf(errorQueryEmpty)
+	}
+
+	direction, relationship := directionOutgoing, ""
+	for {
+		s, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		s.direction, s.relationship = direction, relationship
+		q.steps = append(q.steps, s)
+
+		var found bool
+		if direction, relationship, found = p.link(); !found {
+			break
+		}
+	}
+
+	if p.consume("{") {
+		for {
+			column, err := p.name(true)
+			if err != nil {
+				return nil, err
+			}
+			q.columns = append(q.columns, column)
+			if !p.consume(",") {
+				break
+			}
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.skipSpace(); p.pos < len(p.source) {
+		return nil, fmt.Errorf(errorQueryUnexpected, string(p.source[p.pos]), p.pos)
+	}
+	return q, nil
+}
+
+func (q *Query) String() string {
+	return q.source
+}
+
+// literal converts a bare value into a number or boolean where possible
+func (pr queryPredicate) literal() interface{} {
+	if pr.quoted {
+		return pr.value
+	}
+	if i, err := strconv.Atoi(pr.value); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(pr.value, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(pr.value); err == nil {
+		return b
+	}
+	return pr.value
+}
+
+// compare applies the operator to a single value
+func (pr queryPredicate) compare(v interface{}) bool {
+	if v == nil {
+		return pr.operator == "!="
+	}
+
+	switch pr.operator {
+	case "":
+		return true
+	case "=":
+		return expression.Equal(v, pr.literal()) || expression.ToString(v) == pr.value
+	case "!=":
+		return !expression.Equal(v, pr.literal()) && expression.ToString(v) != pr.value
+	case "~":
+		return pr.regExp.MatchString(expression.ToString(v))
+	}
+
+	c, ok := expression.Compare(v, pr.literal())
+	if !ok {
+		c = strings.Compare(expression.ToString(v), pr.value)
+	}
+	switch pr.operator {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+// matches tests the predicate against a definition; if the definition has no field of the predicate's name but the
+// name is a class, then the predicate tests the IDs of the definitions of that class related to it
+func (pr queryPredicate) matches(d Dictionary, related map[DefinitionKey][]DefinitionKey, key DefinitionKey) bool {
+	switch pr.name {
+	case variableID:
+		return pr.compare(key.ID)
+	case variableClass:
+		return pr.compare(key.Class)
+	}
+
+	dfn := d[key.Class][key.ID]
+	if v, found := dfn.Fields[pr.name]; found || d[pr.name] == nil {
+		return pr.compare(v)
+	}
+
+	var ids []interface{}
+	for _, r := range related[key] {
+		if r.Class == pr.name {
+			ids = append(ids, r.ID)
+		}
+	}
+	if pr.operator == "!=" {
+		for _, id := range ids {
+			if !pr.compare(id) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, id := range ids {
+		if pr.compare(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *Query) matches(ctx *dictionaryContext, related map[DefinitionKey][]DefinitionKey, s queryStep,
+	key DefinitionKey) (bool, error) {
+	if s.class != queryAnyClass && s.class != key.Class {
+		return false, nil
+	}
+	if ctx.d[key.Class][key.ID] == nil {
+		return false, nil
+	}
+	for _, pr := range s.predicates {
+		if !pr.matches(ctx.d, related, key) {
+			return false, nil
+		}
+	}
+	if s.expression == nil {
+		return true, nil
+	}
+
+	return ctx.evaluateBool(s.expression, key)
+}
+
+// Evaluate returns the definitions selected by the final step of the query, sorted by class and ID. The
+// DefinitionFormat is optional, and resolves references to a base class to the definitions of its subclasses.
+func (q *Query) Evaluate(d Dictionary, df *DefinitionFormat) (*QueryResult, error) {
+	ctx := newDictionaryContext(d, df)
+
+	related := map[DefinitionKey][]DefinitionKey{}
+	outgoing := map[DefinitionKey][]edge{}
+	incoming := map[DefinitionKey][]edge{}
+	for _, e := range getEdges(d, df) {
+		related[e.from] = append(related[e.from], e.to)
+		related[e.to] = append(related[e.to], e.from)
+		outgoing[e.from] = append(outgoing[e.from], e)
+		incoming[e.to] = append(incoming[e.to], e)
+	}
+
+	var current []DefinitionKey
+	for _, class := range sortedClasses(d) {
+		for _, id := range sortedIDs(d[class]) {
+			k := DefinitionKey{Class: class, ID: id}
+			ok, err := q.matches(ctx, related, q.steps[0], k)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				current = append(current, k)
+			}
+		}
+	}
+
+	for _, s := range q.steps[1:] {
+		seen := map[DefinitionKey]bool{}
+		var next []DefinitionKey
+		for _, k := range current {
+			var candidates []DefinitionKey
+			if s.direction != directionIncoming {
+				for _, e := range outgoing[k] {
+					if s.relationship == "" || e.relationship == s.relationship {
+						candidates = append(candidates, e.to)
+					}
+				}
+			}
+			if s.direction != directionOutgoing {
+				for _, e := range incoming[k] {
+					if s.relationship == "" || e.relationship == s.relationship {
+						candidates = append(candidates, e.from)
+					}
+				}
+			}
+
+			for _, c := range candidates {
+				if seen[c] {
+					continue
+				}
+				ok, err := q.matches(ctx, related, s, c)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					seen[c] = true
+					next = append(next, c)
+				}
+			}
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].less(next[j]) })
+		current = next
+	}
+
+	return q.project(d, current), nil
+}
+
+// project returns the columns of the query for each definition; without columns the Class and ID are returned, and
+// a column of * is expanded into every field of the definitions selected
+func (q *Query) project(d Dictionary, keys []DefinitionKey) *QueryResult {
+	columns := q.columns
+	if len(columns) == 0 {
+		columns = []string{variableClass, variableID}
+	}
+
+	r := &QueryResult{Rows: [][]interface{}{}}
+	for _, c := range columns {
+		if c != queryAllFields {
+			r.Columns = append(r.Columns, c)
+			continue
+		}
+		fields := map[string]bool{}
+		for _, k := range keys {
+			for f := range d[k.Class][k.ID].Fields {
+				fields[f] = true
+			}
+		}
+		sorted := make([]string, 0, len(fields))
+		for f := range fields {
+			sorted = append(sorted, f)
+		}
+		sort.Strings(sorted)
+		r.Columns = append(r.Columns, sorted...)
+	}
+
+	for _, k := range keys {
+		row := make([]interface{}, 0, len(r.Columns))
+		for _, c := range r.Columns {
+			switch c {
+			case variableClass:
+				row = append(row, k.Class)
+			case variableID:
+				row = append(row, k.ID)
+			default:
+				row = append(row, d[k.Class][k.ID].Fields[c])
+			}
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	return r
+}
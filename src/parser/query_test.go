@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queryIDs(t *testing.T, d Dictionary, source string) []string {
+	q, err := ParseQuery(source)
+	if !assert.Nil(t, err, source) {
+		return nil
+	}
+	r, err := q.Evaluate(d, nil)
+	if !assert.Nil(t, err, source) {
+		return nil
+	}
+	ids := []string{}
+	for _, row := range r.Rows {
+		ids = append(ids, row[0].(string)+"/"+row[1].(string))
+	}
+	return ids
+}
+
+func Test_Query(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+
+	for source, expected := range map[string][]string{
+		"Service":                                  {"Service/aks", "Service/ec2", "Service/vm"},
+		"Service[ID=vm]":                           {"Service/vm"},
+		"Service[Name~^K]":                         {"Service/aks"},
+		"Service[Cost]":                            {"Service/aks", "Service/vm"},
+		"Service[Cost<10]":                         {"Service/vm"},
+		"Service[Cost>=12]":                        {"Service/aks"},
+		"Service[Cost!=4.5]":                       {"Service/aks", "Service/ec2"},
+		`Service[Name="EC2"]`:                      {"Service/ec2"},
+		"Service[Provider=azure]":                  {"Service/aks", "Service/vm"},
+		"Service[Provider!=azure]":                 {"Service/ec2"},
+		"Service[Provider=aws, Name=EC2]":          {"Service/ec2"},
+		"Service[Provider=azure]->HOSTED_BY->*":    {"Provider/azure"},
+		"Service[Provider=azure]->IN->Category":    {},
+		"Service->HOSTED_BY->Provider->IN->*":      {"Category/compute"},
+		"Provider[Regions>40]<-HOSTED_BY<-Service": {"Service/aks", "Service/vm"},
+		"Service[ID=vm]-Service":                   {"Service/aks"},
+		"Service[ID=vm]-USES-*":                    {"Service/aks"},
+		"*[Component]":                             {"Service/vm"},
+		`Service[? Name.startsWith("Virtual") || size(refs("USES")) > 0]`: {"Service/aks", "Service/vm"},
+	} {
+		assert.Equal(t, expected, queryIDs(t, d, source), source)
+	}
+}
+
+func Test_QueryProjection(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+
+	q, err := ParseQuery("Provider { ID, Name }")
+	assert.Nil(t, err)
+	r, err := q.Evaluate(d, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &QueryResult{Columns: []string{"ID", "Name"}, Rows: [][]interface{}{
+		{"aws", "Amazon Web Services"},
+		{"azure", "Microsoft Azure"},
+	}}, r)
+
+	q, err = ParseQuery("Service[ID=ec2]{ID, *}")
+	assert.Nil(t, err)
+	r, err = q.Evaluate(d, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ID", "Name"}, r.Columns)
+
+	q, err = ParseQuery("Category[ID=none]")
+	assert.Nil(t, err)
+	r, err = q.Evaluate(d, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]interface{}{}, r.Rows)
+}
+
+func Test_ParseQuery(t *testing.T) {
+	for source, expected := range map[string]string{
+		"":                      "query is empty",
+		"Service[":              "expected a name at position 8 in query",
+		"Service[Name=":         "expected []] at position 13 in query",
+		"Service[Name=>1]":      "invalid operator [=>] at position 12 in query",
+		`Service[Name="x]`:      "unterminated string starting at position 13 in query",
+		"Service[Name~(]":       "invalid regular expression [(] in query",
+		"Service->":             "expected a name at position 9 in query",
+		"Service{ID":            "expected [}] at position 10 in query",
+		"Service Provider":      "unexpected [P] at position 8 in query",
+		"Service[? Name == 'x'": "expected []] at position 21 in query",
+	} {
+		_, err := ParseQuery(source)
+		assert.EqualError(t, err, expected, source)
+	}
+
+	q, err := ParseQuery("Service[? Name ==]")
+	assert.Nil(t, q)
+	assert.NotNil(t, err)
+
+	// expressions which do not evaluate to a boolean are reported when evaluated
+	q, err = ParseQuery("Service[? Name]")
+	assert.Nil(t, err)
+	_, err = q.Evaluate(LoadDictionary([]string{"_test/graph"}, "yaml"), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/nextmetaphor/yaml-graph/graph"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// TemplateEngineAuto selects html/template for HTML templates or output files, and text/template otherwise
+	TemplateEngineAuto = "auto"
+	// TemplateEngineText renders with text/template, which does not escape field values
+	TemplateEngineText = "text"
+	// TemplateEngineHTML renders with html/template, which escapes field values according to their context
+	TemplateEngineHTML = "html"
+
+	templateOptionMissingKey = "missingkey=zero"
+	reportFileName           = "filename"
+
+	outputDirPerm  = 0755
+	outputFilePerm = 0644
+
+	logInfoWritingReport = "writing report [%s]"
+
+	errorNoTemplates               = "no report templates provided"
+	errorInvalidTemplateEngine     = "invalid template engine [%s]; must be one of auto, text or html"
+	errorTemplateNameRequired      = "a template name must be provided when the first template is a directory"
+	errorTemplateNotFound          = "cannot find template [%s]"
+	errorOutputDirRequired         = "an output directory must be provided to write one file per definition"
+	errorReportFileNameEmpty       = "report file name for definition ID [%s] of class [%s] is empty"
+	errorReportFileNameEmptyReport = "report file name is empty"
+	errorReportFileNameNotLocal    = "report file name [%s] is not within the output directory"
+)
+
+type (
+	// ReportOptions determines how a report is rendered from the templates
+	ReportOptions struct {
+		// Templates holds template files, or directories of templates, which are parsed together so that templates
+		// can be shared using define and template actions
+		Templates []string
+
+		// TemplateName names the template to execute; defaults to the name of the first template file
+		TemplateName string
+
+		// OutputDir is the directory to write the report into; if empty the report is written to the writer
+		OutputDir string
+
+		// PerDefinition renders the template once for each top level SectionDefinition, each to its own file
+		PerDefinition bool
+
+		// FileName is a template for the name of each file written to the output directory, executed with the
+		// SectionDefinition when rendering per definition, or with every SectionDefinition otherwise. Defaults to the
+		// template name, or the definition ID followed by the extension of the template name.
+		FileName string
+
+		// Engine is one of TemplateEngineAuto, TemplateEngineText or TemplateEngineHTML; defaults to auto
+		Engine string
+
+		// Params holds the values of the parameters referred to by Where predicates in the template configuration
+		Params map[string]interface{}
+
+		// Format is optional, and determines the Class returned by the graph template functions for definitions
+		// which are also labelled with the classes their class extends
+		Format *DefinitionFormat
+	}
+
+	// reportTemplate is satisfied by both text/template and html/template templates
+	reportTemplate interface {
+		Name() string
+		Execute(w io.Writer, data interface{}) error
+	}
+)
+
+var (
+	// htmlExtensions identify templates, and output files, which are rendered with html/template by default
+	htmlExtensions = []string{".gohtml", ".html", ".htm"}
+)
+
+// templateFiles returns the files within each path, expanding directories to every file they contain
+func templateFiles(paths []string) (files []string, err error) {
+	for _, p := range paths {
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// templateName returns the name of the template to execute
+func (o ReportOptions) templateName() (string, error) {
+	if o.TemplateName != "" {
+		return o.TemplateName, nil
+	}
+	if len(o.Templates) == 0 {
+		return "", fmt.Errorf(errorNoTemplates)
+	}
+	info, err := os.Stat(o.Templates[0])
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf(errorTemplateNameRequired)
+	}
+	return filepath.Base(o.Templates[0]), nil
+}
+
+// fileName returns the template for the name of each output file
+func (o ReportOptions) fileName(templateName string) string {
+	switch {
+	case o.FileName != "":
+		return o.FileName
+	case o.PerDefinition:
+		return "{{ .ID }}" + filepath.Ext(templateName)
+	}
+	return templateName
+}
+
+// isHTML reports whether the template, or the files written from it, are HTML
+func (o ReportOptions) isHTML(templateName string) bool {
+	names := []string{templateName}
+	if o.OutputDir != "" {
+		names = append(names, o.fileName(templateName))
+	}
+	for _, name := range names {
+		if contains(htmlExtensions, strings.ToLower(filepath.Ext(name))) {
+			return true
+		}
+	}
+	return false
+}
+
+// engine returns the template engine to render with
+func (o ReportOptions) engine(templateName string) (string, error) {
+	switch o.Engine {
+	case TemplateEngineText, TemplateEngineHTML:
+		return o.Engine, nil
+	case "", TemplateEngineAuto:
+		if o.isHTML(templateName) {
+			return TemplateEngineHTML, nil
+		}
+		return TemplateEngineText, nil
+	}
+	return "", fmt.Errorf(errorInvalidTemplateEngine, o.Engine)
+}
+
+// loadTemplates parses every template with the template engine, returning the one to execute
+func loadTemplates(o ReportOptions, resolver templateResolver) (reportTemplate, error) {
+	name, err := o.templateName()
+	if err != nil {
+		return nil, err
+	}
+	engine, err := o.engine(name)
+	if err != nil {
+		return nil, err
+	}
+	files, err := templateFiles(o.Templates)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf(errorNoTemplates)
+	}
+
+	if engine == TemplateEngineHTML {
+		t, err := htmltemplate.New(name).Option(templateOptionMissingKey).
+			Funcs(htmltemplate.FuncMap(getTemplateFuncs(resolver))).ParseFiles(files...)
+		if err != nil {
+			return nil, err
+		}
+		if t = t.Lookup(name); t == nil {
+			return nil, fmt.Errorf(errorTemplateNotFound, name)
+		}
+		return t, nil
+	}
+
+	t, err := texttemplate.New(name).Option(templateOptionMissingKey).Funcs(getTemplateFuncs(resolver)).ParseFiles(files...)
+	if err != nil {
+		return nil, err
+	}
+	if t = t.Lookup(name); t == nil {
+		return nil, fmt.Errorf(errorTemplateNotFound, name)
+	}
+	return t, nil
+}
+
+// writeReportFile executes the template with the data, writing it to the file named by executing the file name
+// template with the same data
+func writeReportFile(t reportTemplate, fileName *texttemplate.Template, dir string, data interface{}) error {
+	var name bytes.Buffer
+	if err := fileName.Execute(&name, data); err != nil {
+		return err
+	}
+	path := strings.TrimSpace(name.String())
+	if path == "" {
+		if sd, ok := data.(SectionDefinition); ok {
+			return fmt.Errorf(errorReportFileNameEmpty, sd.ID, sd.Class)
+		}
+		return fmt.Errorf(errorReportFileNameEmptyReport)
+	}
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf(errorReportFileNameNotLocal, path)
+	}
+
+	path = filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), outputDirPerm); err != nil {
+		return err
+	}
+	log.Info().Msgf(logInfoWritingReport, path)
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), outputFilePerm)
+}
+
+// renderReport renders the definitions with the templates, either to the writer or to files in the output directory
+func renderReport(definitions []SectionDefinition, o ReportOptions, resolver templateResolver,
+	writer io.Writer) error {
+	t, err := loadTemplates(o, resolver)
+	if err != nil {
+		return err
+	}
+	if o.OutputDir == "" {
+		if o.PerDefinition {
+			return fmt.Errorf(errorOutputDirRequired)
+		}
+		return t.Execute(writer, definitions)
+	}
+
+	fileName, err := texttemplate.New(reportFileName).Funcs(getTemplateFuncs(resolver)).Parse(o.fileName(t.Name()))
+	if err != nil {
+		return err
+	}
+	if !o.PerDefinition {
+		return writeReportFile(t, fileName, o.OutputDir, definitions)
+	}
+	for _, definition := range definitions {
+		if err = writeReportFile(t, fileName, o.OutputDir, definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateReport loads the definitions selected by the template configuration from the graph database and renders
+// them with the templates
+func GenerateReport(dbURL, username, password, templateConf string, o ReportOptions, writer io.Writer) error {
+	if err := validateParams(o.Params); err != nil {
+		log.Error().Err(err).Msg(logErrorParsingTemplateDefinitions)
+		return err
+	}
+
+	// first load the template configuration
+	templateSection, err := loadTemplateConf(templateConf)
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorGraphDatabaseConnectionFailed)
+		return err
+	}
+
+	// then connect to the graph database
+	driver, session, err := graph.Init(dbURL, username, password)
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorGraphDatabaseConnectionFailed)
+		return err
+	}
+
+	defer driver.Close()
+	defer session.Close()
+
+	// now recurse through the sections
+	definitions, err := recurseTemplateSection(session, *templateSection, nil, nil, o.Params)
+	if err != nil {
+		log.Error().Err(err).Msg(logErrorParsingTemplateDefinitions)
+		return err
+	}
+
+	if err = renderReport(definitions, o, graphResolver{session: session, df: o.Format}, writer); err != nil {
+		log.Error().Err(err).Msg(logErrorParsingTemplate)
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getReportDefinitions() []SectionDefinition {
+	return []SectionDefinition{
+		{Class: "Service", ID: "vm", Fields: map[string]interface{}{"Service.Name": "Virtual Machine"}},
+		{Class: "Service", ID: "s3", Fields: map[string]interface{}{"Service.Name": "Object Storage"}},
+	}
+}
+
+func Test_ReportOptions_templateName(t *testing.T) {
+	name, err := ReportOptions{Templates: []string{"_test/report/single.gotmpl"}}.templateName()
+	assert.Nil(t, err)
+	assert.Equal(t, "single.gotmpl", name)
+
+	name, err = ReportOptions{Templates: []string{"_test/report/templates"}, TemplateName: "report.gotmpl"}.templateName()
+	assert.Nil(t, err)
+	assert.Equal(t, "report.gotmpl", name)
+
+	_, err = ReportOptions{Templates: []string{"_test/report/templates"}}.templateName()
+	assert.EqualError(t, err, errorTemplateNameRequired)
+
+	_, err = ReportOptions{}.templateName()
+	assert.EqualError(t, err, errorNoTemplates)
+}
+
+func Test_ReportOptions_fileName(t *testing.T) {
+	assert.Equal(t, "report.html", ReportOptions{}.fileName("report.html"))
+	assert.Equal(t, "{{ .ID }}.html", ReportOptions{PerDefinition: true}.fileName("report.html"))
+	assert.Equal(t, "{{ .Class }}.md", ReportOptions{FileName: "{{ .Class }}.md"}.fileName("report.html"))
+}
+
+func Test_renderReport(t *testing.T) {
+	t.Run("SingleTemplate", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, renderReport(getReportDefinitions(), ReportOptions{Templates: []string{"_test/report/single.gotmpl"}},
+			nil, &buf))
+		assert.Equal(t, "2 definitions\n", buf.String())
+	})
+
+	t.Run("Partials", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(t, renderReport(getReportDefinitions(), ReportOptions{Templates: []string{"_test/report/templates"},
+			TemplateName: "report.gotmpl"}, nil, &buf))
+		assert.Equal(t, "# Services\n- Virtual Machine\n- Object Storage\n", buf.String())
+	})
+
+	t.Run("OutputDir", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, renderReport(getReportDefinitions(), ReportOptions{Templates: []string{"_test/report/single.gotmpl"},
+			OutputDir: dir}, nil, nil))
+		b, err := os.ReadFile(filepath.Join(dir, "single.gotmpl"))
+		assert.Nil(t, err)
+		assert.Equal(t, "2 definitions\n", string(b))
+	})
+
+	t.Run("PerDefinition", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.Nil(t, renderReport(getReportDefinitions(), ReportOptions{Templates: []string{"_test/report/templates"},
+			TemplateName: "definition.gotmpl", OutputDir: dir, PerDefinition: true,
+			FileName: "{{ .Class }}/{{ .ID }}.md"}, nil, nil))
+		b, err := os.ReadFile(filepath.Join(dir, "Service", "vm.md"))
+		assert.Nil(t, err)
+		assert.Equal(t, "# vm\nVirtual Machine\n", string(b))
+		_, err = os.Stat(filepath.Join(dir, "Service", "s3.md"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		o := ReportOptions{Templates: []string{"_test/report/templates"}, TemplateName: "definition.gotmpl",
+			PerDefinition: true}
+		assert.EqualError(t, renderReport(getReportDefinitions(), o, nil, nil), errorOutputDirRequired)
+
+		o.OutputDir = t.TempDir()
+		o.FileName = "../{{ .ID }}"
+		assert.EqualError(t, renderReport(getReportDefinitions(), o, nil, nil),
+			"report file name [../vm] is not within the output directory")
+
+		o.FileName = "{{ .Missing }}"
+		assert.NotNil(t, renderReport(getReportDefinitions(), o, nil, nil))
+
+		o.FileName = "{{ if false }}x{{ end }}"
+		assert.EqualError(t, renderReport(getReportDefinitions(), o, nil, nil),
+			"report file name for definition ID [vm] of class [Service] is empty")
+
+		o.TemplateName = "missing.gotmpl"
+		assert.EqualError(t, renderReport(getReportDefinitions(), o, nil, nil), "cannot find template [missing.gotmpl]")
+	})
+}
+
+func Test_ReportOptions_engine(t *testing.T) {
+	for _, tc := range []struct {
+		options      ReportOptions
+		templateName string
+		engine       string
+	}{
+		{ReportOptions{}, "report.gohtml", TemplateEngineHTML},
+		{ReportOptions{Engine: TemplateEngineAuto}, "report.HTML", TemplateEngineHTML},
+		{ReportOptions{}, "report.gotmpl", TemplateEngineText},
+		{ReportOptions{OutputDir: "out", PerDefinition: true, FileName: "{{ .ID }}.html"}, "page.gotmpl",
+			TemplateEngineHTML},
+		{ReportOptions{FileName: "{{ .ID }}.html"}, "page.gotmpl", TemplateEngineText},
+		{ReportOptions{Engine: TemplateEngineText}, "report.gohtml", TemplateEngineText},
+		{ReportOptions{Engine: TemplateEngineHTML}, "report.md", TemplateEngineHTML},
+	} {
+		engine, err := tc.options.engine(tc.templateName)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.engine, engine, tc.templateName)
+	}
+
+	_, err := ReportOptions{Engine: "pdf"}.engine("report.gohtml")
+	assert.EqualError(t, err, "invalid template engine [pdf]; must be one of auto, text or html")
+}
+
+func Test_renderReport_escaping(t *testing.T) {
+	definitions := []SectionDefinition{{Class: "Service", ID: "vm", Fields: map[string]interface{}{
+		"Service.Name":        "<script>alert(1)</script>",
+		"Service.Description": "**bold**",
+	}}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, renderReport(definitions, ReportOptions{Templates: []string{"_test/report/escape.gohtml"}}, nil, &buf))
+	assert.Equal(t, "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p><p><strong>bold</strong></p>\n\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, renderReport(definitions, ReportOptions{Templates: []string{"_test/report/escape.gohtml"},
+		Engine: TemplateEngineText}, nil, &buf))
+	assert.Equal(t, "<p><script>alert(1)</script></p><p><strong>bold</strong></p>\n\n", buf.String())
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+)
+
+const (
+	logWarnInvalidRule          = "rule [%s] for class [%s] is invalid: %s"
+	logWarnInvalidRuleSeverity  = "rule [%s] for class [%s] has invalid severity [%s]"
+	logWarnRuleEvaluationFailed = "rule [%s] could not be evaluated for definition ID [%s] for class [%s]: %s"
+	logRuleFailed               = "rule [%s] failed for definition ID [%s] for class [%s]: %s"
+)
+
+type (
+	// Rule is a boolean expression which must evaluate to true for every definition of the class it is declared on
+	Rule struct {
+		// ID uniquely identifies the rule
+		ID string `yaml:"ID"`
+
+		Description string `yaml:"Description,omitempty"`
+
+		// Expression is evaluated over the fields and references of each definition, and must return a boolean
+		Expression string `yaml:"Expression"`
+
+		// Message is reported when the expression evaluates to false
+		Message string `yaml:"Message,omitempty"`
+
+		// Severity is one of error, warning or info; defaults to error
+		Severity Severity `yaml:"Severity,omitempty"`
+	}
+)
+
+// validateRules evaluates each Rule of each class against all of the definitions of that class
+func (v *validator) validateRules() {
+	if v.df == nil {
+		return
+	}
+
+	ctx := newDictionaryContext(v.d, v.df)
+	for _, class := range sortedClassFormats(v.df) {
+		if v.df.ClassFormat[class] == nil {
+			continue
+		}
+
+		for _, rule := range v.df.ClassFormat[class].Rules {
+			if !rule.Severity.valid() {
+				v.report(RuleInvalidFormat, SeverityError, fmt.Sprintf(logWarnInvalidRuleSeverity, rule.ID, class,
+					rule.Severity))
+				continue
+			}
+
+			e, err := ctx.parse(rule.Expression)
+			if err != nil {
+				v.report(RuleInvalidFormat, SeverityError, fmt.Sprintf(logWarnInvalidRule, rule.ID, class, err))
+				continue
+			}
+
+			message := rule.Message
+			if message == "" {
+				message = rule.Expression
+			}
+
+			for _, id := range sortedIDs(v.d[class]) {
+				key := DefinitionKey{Class: class, ID: id}
+				passed, err := ctx.evaluateBool(e, key)
+				if err != nil {
+					v.report(RuleInvalidFormat, SeverityError, fmt.Sprintf(logWarnRuleEvaluationFailed, rule.ID, id,
+						class, err), key)
+				} else if !passed {
+					v.report(rule.ID, rule.Severity, fmt.Sprintf(logRuleFailed, rule.ID, id, class, message), key)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateRules(t *testing.T) {
+	linkRule := Rule{
+		ID:         "public-link",
+		Expression: `Tenancy != "Public" || has(Link)`,
+		Message:    "public services must have a link",
+	}
+	uniqueNameRule := Rule{
+		ID:         "unique-name",
+		Expression: `definitions(Class).filter(d, d.Name == Name).size() == 1`,
+	}
+	singleProviderRule := Rule{
+		ID:         "single-provider",
+		Expression: `refs("TYPE_OF").map(c, c.refs("PROVIDED_BY")).map(p, p[0].ID).uniq().size() <= 1`,
+	}
+
+	testCases := map[string]struct {
+		rules    []Rule
+		expected int
+	}{
+		"NoRules":           {rules: nil, expected: 0},
+		"LinkRule":          {rules: []Rule{linkRule}, expected: 1},
+		"UniqueNameRule":    {rules: []Rule{uniqueNameRule}, expected: 2},
+		"SingleProvider":    {rules: []Rule{singleProviderRule}, expected: 1},
+		"AllRules":          {rules: []Rule{linkRule, uniqueNameRule, singleProviderRule}, expected: 4},
+		"WarningSeverity":   {rules: []Rule{{ID: "w", Expression: "false", Severity: SeverityWarning}}, expected: 0},
+		"InfoSeverity":      {rules: []Rule{{ID: "i", Expression: "false", Severity: SeverityInfo}}, expected: 0},
+		"ErrorSeverity":     {rules: []Rule{{ID: "e", Expression: "false", Severity: SeverityError}}, expected: 2},
+		"InvalidSeverity":   {rules: []Rule{{ID: "s", Expression: "true", Severity: "fatal"}}, expected: 1},
+		"InvalidExpression": {rules: []Rule{{ID: "x", Expression: "Name =="}}, expected: 1},
+		"NonBoolean":        {rules: []Rule{{ID: "n", Expression: "Name"}}, expected: 2},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+				"Service":  {Rules: tc.rules},
+				"Provider": nil,
+			}}
+			v := newValidator(LoadDictionary([]string{"_test/validateRules"}, "yaml"), df)
+			v.validateRules()
+			assert.Equal(t, tc.expected, v.result.Errors())
+		})
+	}
+
+	t.Run("NilFormat", func(t *testing.T) {
+		v := newValidator(LoadDictionary([]string{"_test/validateRules"}, "yaml"), nil)
+		v.validateRules()
+		assert.Empty(t, v.result.Findings)
+	})
+}
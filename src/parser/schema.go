@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	schemaVersion = "http://json-schema.org/draft-07/schema#"
+
+	schemaTitle             = "yaml-graph definition file"
+	schemaClassTitle        = "yaml-graph definition file for class %s"
+	schemaComputedFieldDesc = "computed from [%s]"
+
+	schemaRefSpecification = "#/definitions/specification"
+	schemaRefReference     = "#/definitions/reference"
+	schemaRefFileFields    = "#/definitions/fileFields"
+	schemaRefDefinition    = "#/definitions/%s"
+
+	schemaNonBlankPattern = `\S`
+
+	errorUnknownSchemaClass = "class [%s] is not declared in the definition format"
+)
+
+type (
+	// JSONSchema is the subset of JSON Schema (draft-07) used to describe definition files
+	JSONSchema struct {
+		Schema      string `json:"$schema,omitempty"`
+		Ref         string `json:"$ref,omitempty"`
+		Title       string `json:"title,omitempty"`
+		Description string `json:"description,omitempty"`
+
+		// Type is either a single type name or a list of type names
+		Type interface{} `json:"type,omitempty"`
+
+		Const   string   `json:"const,omitempty"`
+		Enum    []string `json:"enum,omitempty"`
+		Pattern string   `json:"pattern,omitempty"`
+
+		Properties map[string]*JSONSchema `json:"properties,omitempty"`
+		Required   []string               `json:"required,omitempty"`
+
+		// AdditionalProperties is either a boolean or a *JSONSchema
+		AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+		PropertyNames        *JSONSchema `json:"propertyNames,omitempty"`
+		Items                *JSONSchema `json:"items,omitempty"`
+
+		AllOf []*JSONSchema `json:"allOf,omitempty"`
+		AnyOf []*JSONSchema `json:"anyOf,omitempty"`
+		If    *JSONSchema   `json:"if,omitempty"`
+		Then  *JSONSchema   `json:"then,omitempty"`
+
+		Definitions map[string]*JSONSchema `json:"definitions,omitempty"`
+	}
+)
+
+// scalarTypes are the JSON Schema equivalents of the field types accepted by scalarTypeValid
+var scalarTypes = []string{"string", "number", "integer", "boolean"}
+
+// GenerateSchema returns a JSON Schema describing the layout of a definition file; if a class is provided then the
+// schema only accepts definition files for that class, otherwise it accepts definition files for any class
+func GenerateSchema(df *DefinitionFormat, class string) (*JSONSchema, error) {
+	if df == nil {
+		df = &DefinitionFormat{}
+	}
+
+	schema := &JSONSchema{
+		Schema:      schemaVersion,
+		Title:       schemaTitle,
+		AllOf:       []*JSONSchema{{Ref: schemaRefSpecification}},
+		Definitions: map[string]*JSONSchema{},
+	}
+
+	if class != "" {
+		if df.ClassFormat[class] == nil {
+			return nil, fmt.Errorf(errorUnknownSchemaClass, class)
+		}
+		schema.Title = fmt.Sprintf(schemaClassTitle, class)
+		schema.Description = df.ClassFormat[class].Description
+		schema.AllOf = append(schema.AllOf, &JSONSchema{
+			Properties: map[string]*JSONSchema{"Class": {Const: class}},
+			Required:   []string{"Class"},
+		})
+	}
+
+	var classes, concreteClasses []string
+	for _, c := range sortedClassFormats(df) {
+		if df.ClassFormat[c] == nil {
+			continue
+		}
+		classes = append(classes, c)
+		if !df.ClassFormat[c].Abstract {
+			concreteClasses = append(concreteClasses, c)
+		}
+	}
+
+	schema.Definitions["specification"] = specificationSchema(concreteClasses)
+	schema.Definitions["reference"] = referenceSchema(classes)
+	schema.Definitions["fileFields"] = fileFieldsSchema()
+	for _, c := range concreteClasses {
+		schema.Definitions[c] = definitionSchema(df.ClassFormat[c])
+	}
+
+	return schema, nil
+}
+
+func specificationSchema(classes []string) *JSONSchema {
+	spec := &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"Class":      {Type: "string", Enum: classes},
+			"References": {Type: "array", Items: &JSONSchema{Ref: schemaRefReference}},
+			"Definitions": {
+				Type:                 "object",
+				AdditionalProperties: &JSONSchema{Type: "object"},
+			},
+		},
+		Required:             []string{"Class"},
+		AdditionalProperties: false,
+	}
+
+	// the layout of each definition depends upon the class of the specification
+	for _, class := range classes {
+		spec.AllOf = append(spec.AllOf, &JSONSchema{
+			If: &JSONSchema{Properties: map[string]*JSONSchema{"Class": {Const: class}}},
+			Then: &JSONSchema{Properties: map[string]*JSONSchema{
+				"Definitions": {AdditionalProperties: &JSONSchema{Ref: fmt.Sprintf(schemaRefDefinition, class)}},
+			}},
+		})
+	}
+
+	return spec
+}
+
+func referenceSchema(classes []string) *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"Class":            {Type: "string", Enum: classes},
+			"ID":               {Type: "string", Pattern: schemaNonBlankPattern},
+			"Relationship":     {Type: "string"},
+			"RelationshipFrom": {Type: "boolean"},
+			"RelationshipTo":   {Type: "boolean"},
+			"Fields":           {Type: "object", AdditionalProperties: &JSONSchema{Type: scalarTypes}},
+		},
+		Required:             []string{"Class", "ID", "Relationship"},
+		AdditionalProperties: false,
+	}
+}
+
+func fileFieldsSchema() *JSONSchema {
+	return &JSONSchema{
+		Type: "object",
+		AdditionalProperties: &JSONSchema{
+			Type: "object",
+			Properties: map[string]*JSONSchema{
+				"Path":                 {Type: "string"},
+				"Prefix":               {Type: "string"},
+				"Encoding":             {Type: "string"},
+				"RenderHTML":           {Type: "boolean"},
+				"TableOfContentsField": {Type: "string"},
+			},
+			Required:             []string{"Path"},
+			AdditionalProperties: false,
+		},
+	}
+}
+
+// definitionSchema describes a single definition of the class; as a mandatory field can be provided either as a
+// field or a file field, each mandatory field must be present in at least one of them
+func definitionSchema(cf *ClassDefinitionFormat) *JSONSchema {
+	fields := &JSONSchema{
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: false,
+	}
+
+	var mandatory []string
+	for name, f := range cf.MandatoryFields {
+		fields.Properties[name] = &JSONSchema{Type: "string", Pattern: schemaNonBlankPattern, Description: f.Description}
+		mandatory = append(mandatory, name)
+	}
+	for name, f := range cf.OptionalFields {
+		if _, ok := fields.Properties[name]; !ok {
+			fields.Properties[name] = &JSONSchema{Type: scalarTypes, Description: f.Description}
+		}
+	}
+	for name, f := range cf.ComputedFields {
+		if _, ok := fields.Properties[name]; !ok {
+			description := f.Description
+			if description == "" {
+				description = fmt.Sprintf(schemaComputedFieldDesc, f.Expression)
+			}
+			fields.Properties[name] = &JSONSchema{Type: scalarTypes, Description: description}
+		}
+	}
+	sort.Strings(mandatory)
+
+	names := make([]string, 0, len(fields.Properties))
+	for name := range fields.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dfn := &JSONSchema{
+		Type:        "object",
+		Description: cf.Description,
+		Properties: map[string]*JSONSchema{
+			"Fields": fields,
+			"FileFields": {
+				AllOf:         []*JSONSchema{{Ref: schemaRefFileFields}},
+				PropertyNames: &JSONSchema{Enum: names},
+			},
+			"References": {Type: "array", Items: &JSONSchema{Ref: schemaRefReference}},
+			"SubDefinitions": {
+				Type:                 "object",
+				AdditionalProperties: &JSONSchema{Ref: schemaRefSpecification},
+			},
+		},
+		AdditionalProperties: false,
+	}
+
+	for _, name := range mandatory {
+		dfn.AllOf = append(dfn.AllOf, &JSONSchema{AnyOf: []*JSONSchema{
+			{
+				Properties: map[string]*JSONSchema{"Fields": {Required: []string{name}}},
+				Required:   []string{"Fields"},
+			},
+			{
+				Properties: map[string]*JSONSchema{"FileFields": {Required: []string{name}}},
+				Required:   []string{"FileFields"},
+			},
+		}})
+	}
+
+	return dfn
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GenerateSchema(t *testing.T) {
+	df := getInheritanceFormat()
+	assert.Nil(t, df.ResolveInheritance())
+
+	t.Run("AllClasses", func(t *testing.T) {
+		s, err := GenerateSchema(df, "")
+		assert.Nil(t, err)
+
+		assert.Equal(t, schemaVersion, s.Schema)
+		assert.Equal(t, []*JSONSchema{{Ref: schemaRefSpecification}}, s.AllOf)
+
+		// abstract classes cannot be used for definitions, but can be referenced
+		assert.Equal(t, []string{"Provider", "Service"}, s.Definitions["specification"].Properties["Class"].Enum)
+		assert.Equal(t, []string{"Named", "Provider", "Resource", "Service"},
+			s.Definitions["reference"].Properties["Class"].Enum)
+		assert.Nil(t, s.Definitions["Named"])
+		assert.Len(t, s.Definitions["specification"].AllOf, 2)
+	})
+
+	t.Run("SingleClass", func(t *testing.T) {
+		s, err := GenerateSchema(df, "Service")
+		assert.Nil(t, err)
+
+		assert.Equal(t, "Service", s.AllOf[1].Properties["Class"].Const)
+
+		service := s.Definitions["Service"]
+		fields := service.Properties["Fields"]
+		assert.Equal(t, false, fields.AdditionalProperties)
+		assert.Equal(t, "string", fields.Properties["Name"].Type)
+		assert.Equal(t, "string", fields.Properties["Category"].Type)
+		assert.Equal(t, scalarTypes, fields.Properties["Description"].Type)
+		assert.Equal(t, "computed from [slug(Name)]", fields.Properties["Slug"].Description)
+		assert.Equal(t, []string{"Category", "Description", "Name", "Slug"},
+			service.Properties["FileFields"].PropertyNames.Enum)
+
+		// one anyOf for each mandatory field, in name order
+		assert.Len(t, service.AllOf, 2)
+		assert.Equal(t, []string{"Category"}, service.AllOf[0].AnyOf[0].Properties["Fields"].Required)
+		assert.Equal(t, []string{"Name"}, service.AllOf[1].AnyOf[1].Properties["FileFields"].Required)
+	})
+
+	t.Run("UnknownClass", func(t *testing.T) {
+		_, err := GenerateSchema(df, "Missing")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NilFormat", func(t *testing.T) {
+		s, err := GenerateSchema(nil, "")
+		assert.Nil(t, err)
+		assert.Nil(t, s.Definitions["specification"].Properties["Class"].Enum)
+	})
+
+	t.Run("Marshal", func(t *testing.T) {
+		s, err := GenerateSchema(df, "Provider")
+		assert.Nil(t, err)
+
+		b, err := json.Marshal(s)
+		assert.Nil(t, err)
+
+		var m map[string]interface{}
+		assert.Nil(t, json.Unmarshal(b, &m))
+		assert.Equal(t, schemaVersion, m["$schema"])
+		assert.Equal(t, false, m["definitions"].(map[string]interface{})["reference"].(map[string]interface{})["additionalProperties"])
+	})
+}
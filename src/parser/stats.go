@@ -0,0 +1,232 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/expression"
+)
+
+const (
+	// FieldMandatory indicates a field declared as mandatory in the definition format
+	FieldMandatory = "mandatory"
+	// FieldOptional indicates a field declared as optional in the definition format
+	FieldOptional = "optional"
+	// FieldComputed indicates a field declared as computed in the definition format
+	FieldComputed = "computed"
+	// FieldUndeclared indicates a field which is not declared in the definition format
+	FieldUndeclared = "undeclared"
+)
+
+type (
+	// FieldStatistics holds the number of definitions of a class in which a field is populated
+	FieldStatistics struct {
+		Field     string
+		Kind      string
+		Populated int
+		FillRate  float64
+	}
+
+	// ClassStatistics summarises the definitions of a single class
+	ClassStatistics struct {
+		Class       string
+		Definitions int
+		Fields      []FieldStatistics
+	}
+
+	// RelationshipStatistics holds the number of relationships of a type between two classes
+	RelationshipStatistics struct {
+		Relationship string
+		FromClass    string
+		ToClass      string
+		Count        int
+	}
+
+	// DegreeStatistics holds the number of definitions with a given number of relationships
+	DegreeStatistics struct {
+		Degree      int
+		Definitions int
+	}
+
+	// DefinitionStatistics describes the size and connectedness of a single definition
+	DefinitionStatistics struct {
+		Class    string
+		ID       string
+		Fields   int
+		Size     int
+		Incoming int
+		Outgoing int
+		Degree   int
+	}
+
+	// Statistics summarises a Dictionary
+	Statistics struct {
+		Definitions        int
+		TotalRelationships int
+
+		// BrokenRelationships counts references to definitions which do not exist; these are excluded from the
+		// other relationship statistics
+		BrokenRelationships int
+
+		Classes            []ClassStatistics
+		Relationships      []RelationshipStatistics
+		DegreeDistribution []DegreeStatistics
+		MostConnected      []DefinitionStatistics
+		Largest            []DefinitionStatistics
+	}
+)
+
+// populated returns true if the field has a value which is not blank
+func populated(v interface{}) bool {
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s) != ""
+	}
+	return v != nil
+}
+
+// classFields returns the fields of the class, together with their kind, declared in the definition format or, for
+// undeclared fields, found in the definitions
+func classFields(definitions map[string]*DictionaryDefinition, cf *ClassDefinitionFormat) map[string]string {
+	fields := map[string]string{}
+	for _, dfn := range definitions {
+		for f := range dfn.Fields {
+			fields[f] = FieldUndeclared
+		}
+	}
+	if cf != nil {
+		for f := range cf.OptionalFields {
+			fields[f] = FieldOptional
+		}
+		for f := range cf.ComputedFields {
+			fields[f] = FieldComputed
+		}
+		for f := range cf.MandatoryFields {
+			fields[f] = FieldMandatory
+		}
+	}
+	return fields
+}
+
+// Stats summarises the Dictionary; fill rates are calculated for every field declared for each class in the
+// DefinitionFormat, which may be nil, and the top definitions are limited to the number provided
+func Stats(d Dictionary, df *DefinitionFormat, top int) *Statistics {
+	s := &Statistics{}
+
+	for _, class := range sortedClasses(d) {
+		cs := ClassStatistics{Class: class, Definitions: len(d[class])}
+		s.Definitions += cs.Definitions
+
+		var cf *ClassDefinitionFormat
+		if df != nil {
+			cf = df.ClassFormat[class]
+		}
+		fields := classFields(d[class], cf)
+		names := make([]string, 0, len(fields))
+		for f := range fields {
+			names = append(names, f)
+		}
+		sort.Strings(names)
+
+		for _, f := range names {
+			fs := FieldStatistics{Field: f, Kind: fields[f]}
+			for _, dfn := range d[class] {
+				if populated(dfn.Fields[f]) {
+					fs.Populated++
+				}
+			}
+			if cs.Definitions > 0 {
+				fs.FillRate = float64(fs.Populated) / float64(cs.Definitions)
+			}
+			cs.Fields = append(cs.Fields, fs)
+		}
+		s.Classes = append(s.Classes, cs)
+	}
+
+	relationships := map[RelationshipStatistics]int{}
+	incoming := map[DefinitionKey]int{}
+	outgoing := map[DefinitionKey]int{}
+	for _, e := range getEdges(d, df) {
+		s.TotalRelationships++
+		if d[e.to.Class][e.to.ID] == nil {
+			s.BrokenRelationships++
+			continue
+		}
+		relationships[RelationshipStatistics{Relationship: e.relationship, FromClass: e.from.Class,
+			ToClass: e.to.Class}]++
+		outgoing[e.from]++
+		incoming[e.to]++
+	}
+	for r, count := range relationships {
+		r.Count = count
+		s.Relationships = append(s.Relationships, r)
+	}
+	sort.Slice(s.Relationships, func(i, j int) bool {
+		a, b := s.Relationships[i], s.Relationships[j]
+		if a.Relationship != b.Relationship {
+			return a.Relationship < b.Relationship
+		}
+		if a.FromClass != b.FromClass {
+			return a.FromClass < b.FromClass
+		}
+		return a.ToClass < b.ToClass
+	})
+
+	var definitions []DefinitionStatistics
+	degrees := map[int]int{}
+	for _, class := range sortedClasses(d) {
+		for _, id := range sortedIDs(d[class]) {
+			k := DefinitionKey{Class: class, ID: id}
+			ds := DefinitionStatistics{Class: class, ID: id, Fields: len(d[class][id].Fields),
+				Incoming: incoming[k], Outgoing: outgoing[k]}
+			ds.Degree = ds.Incoming + ds.Outgoing
+			for _, v := range d[class][id].Fields {
+				ds.Size += len(expression.ToString(v))
+			}
+			definitions = append(definitions, ds)
+			degrees[ds.Degree]++
+		}
+	}
+
+	for degree, count := range degrees {
+		s.DegreeDistribution = append(s.DegreeDistribution, DegreeStatistics{Degree: degree, Definitions: count})
+	}
+	sort.Slice(s.DegreeDistribution, func(i, j int) bool {
+		return s.DegreeDistribution[i].Degree < s.DegreeDistribution[j].Degree
+	})
+
+	s.MostConnected = topDefinitions(definitions, top, func(ds DefinitionStatistics) int { return ds.Degree })
+	s.Largest = topDefinitions(definitions, top, func(ds DefinitionStatistics) int { return ds.Size })
+
+	return s
+}
+
+// topDefinitions returns up to top definitions with the highest non-zero measure, in descending order
+func topDefinitions(definitions []DefinitionStatistics, top int,
+	measure func(DefinitionStatistics) int) (result []DefinitionStatistics) {
+	for _, ds := range definitions {
+		if measure(ds) > 0 {
+			result = append(result, ds)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return measure(result[i]) > measure(result[j]) })
+	if top >= 0 && len(result) > top {
+		result = result[:top]
+	}
+	return result
+}
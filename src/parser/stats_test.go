@@ -0,0 +1,69 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Stats(t *testing.T) {
+	d := LoadDictionary([]string{"_test/graph"}, "yaml")
+	df := &DefinitionFormat{ClassFormat: map[string]*ClassDefinitionFormat{
+		"Service": {
+			MandatoryFields: map[string]ClassField{"Name": {}},
+			OptionalFields:  map[string]ClassField{"Cost": {}, "Owner": {}},
+		},
+	}}
+
+	s := Stats(d, df, 2)
+	assert.Equal(t, 7, s.Definitions)
+	assert.Equal(t, 7, s.TotalRelationships)
+	assert.Equal(t, 1, s.BrokenRelationships)
+
+	assert.Equal(t, ClassStatistics{Class: "Service", Definitions: 3, Fields: []FieldStatistics{
+		{Field: "Cost", Kind: FieldOptional, Populated: 2, FillRate: 2.0 / 3},
+		{Field: "Name", Kind: FieldMandatory, Populated: 3, FillRate: 1},
+		{Field: "Owner", Kind: FieldOptional, Populated: 0, FillRate: 0},
+	}}, s.Classes[3])
+	assert.Equal(t, ClassStatistics{Class: "Category", Definitions: 1}, s.Classes[0])
+	assert.Equal(t, FieldStatistics{Field: "Regions", Kind: FieldUndeclared, Populated: 2, FillRate: 1},
+		s.Classes[2].Fields[1])
+
+	assert.Equal(t, []RelationshipStatistics{
+		{Relationship: "HOSTED_BY", FromClass: "Service", ToClass: "Provider", Count: 3},
+		{Relationship: "IN", FromClass: "Provider", ToClass: "Category", Count: 1},
+		{Relationship: "PART_OF", FromClass: "Component", ToClass: "Service", Count: 1},
+		{Relationship: "USES", FromClass: "Service", ToClass: "Service", Count: 1},
+	}, s.Relationships)
+
+	assert.Equal(t, []DegreeStatistics{{Degree: 1, Definitions: 4}, {Degree: 2, Definitions: 1},
+		{Degree: 3, Definitions: 2}}, s.DegreeDistribution)
+
+	assert.Equal(t, []DefinitionStatistics{
+		{Class: "Provider", ID: "azure", Fields: 2, Size: 17, Incoming: 2, Outgoing: 1, Degree: 3},
+		{Class: "Service", ID: "vm", Fields: 2, Size: 18, Incoming: 2, Outgoing: 1, Degree: 3},
+	}, s.MostConnected)
+	assert.Equal(t, "Amazon Web Services", d[s.Largest[0].Class][s.Largest[0].ID].Fields["Name"])
+	assert.Len(t, s.Largest, 2)
+
+	// without a definition format only the fields found are reported, and the top definitions are unlimited
+	s = Stats(d, nil, -1)
+	assert.Len(t, s.Classes[3].Fields, 2)
+	assert.Len(t, s.MostConnected, 7)
+}
@@ -17,32 +17,43 @@
 package parser
 
 import (
-	"bytes"
 	"fmt"
-	htmltemplate "html/template"
 	"io"
 	"os"
-	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
-	texttemplate "text/template"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/nextmetaphor/yaml-graph/graph"
 	"github.com/rs/zerolog/log"
-	"github.com/yuin/goldmark"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	funcMarkdown = "markdown"
-	funcNilToStr = "nilToStr"
-
 	orderClauseSingular        = "%s.%s"
 	orderClauseMultiple        = "%s,%s.%s"
-	baseTemplateCypher         = "match %s return %s order by %s"
+	baseTemplateCypher         = "match %s return %s"
+	orderByCypherClause        = " order by %s"
+	orderDescending            = " desc"
+	skipCypherClause           = " skip %d"
+	limitCypherClause          = " limit %d"
+	whereCypherClause          = " where %s"
+	whereCypherConjunction     = " and "
+	wherePredicateCypher       = "%s.%s %s $%s"
+	whereValueParameter        = "whereValue%d"
+	withCypherClause           = " with %s"
+	aggregationCypher          = "%s(%s%s) as `%s`"
+	aggregationDistinct        = "distinct "
+	aggregationCount           = "count"
+	aggregationVariable        = "`%s`"
 	rootCypherMatchClause      = "(%s:%s)"
-	compositeCypherMatchClause = "(%s:%s)%s-[:%s]-%s(%s:%s {ID:\"%s\"})"
-	aggregateCypherMatchClause = " optional match (%s:%s)-[:%s]-(%s:%s)"
+	compositeCypherMatchClause = "(%s:%s)%s-[:%s]-%s(%s:%s)"
+	parentIDsPredicate         = "%s.ID IN $%s"
+	parentVariable             = "parentNode"
+	parentIDsParameter         = "parentIDs"
+	aggregateCypherMatchClause = " optional match (%s:%s)%s-[:%s]-%s(%s:%s)"
 	aggregateCypherOrderClause = ",%s"
 
 	classFieldIdentifier = "%s.%s"
@@ -56,6 +67,21 @@ const (
 	logErrorGraphDatabaseConnectionFailed                 = "graph database connection failed"
 	logErrorNilDefinitionID                               = "definition ID is nil"
 	logErrorNonStringDefinitionID                         = "definition ID is not a string"
+
+	errorInvalidWhereOperator  = "invalid operator [%s] for field [%s]; must be one of =, !=, <, <=, >, >=, in or contains"
+	errorMissingWhereField     = "a Where predicate on class [%s] has no Field"
+	errorMissingParameter      = "parameter [%s] used by class [%s] has not been provided"
+	errorWhereValueAndParam    = "the Where predicate on field [%s] must have either a Value or a Param, not both"
+	errorWhereNoValue          = "the Where predicate on field [%s] must have a Value or a Param"
+	errorWhereInValue          = "the Where predicate on field [%s] with operator in must have a list Value"
+	errorInvalidAggregation    = "invalid aggregation function [%s] for class [%s]; must be one of count, collect, min, max, sum or avg"
+	errorAggregationField      = "aggregation function [%s] for class [%s] requires a Field"
+	errorRecursiveRelationship = "recursive section for class [%s] requires a Relationship or RecursiveRelationship"
+	errorRecursiveDirection    = "recursive section for class [%s] requires RelationshipFrom or RelationshipTo"
+	errorDuplicateVariable     = "class [%s] cannot be selected as [%s] more than once in a section; give each a different ClassAlias"
+	errorReservedParameter     = "parameter [%s] is reserved for the queries generated for each section; use a different name"
+	errorAggregatePage         = "aggregate class [%s] cannot have Skip or Limit; these apply only to the SectionClass"
+	errorAggregateOrder        = "aggregate class [%s] cannot have OrderFields as well as Aggregations"
 )
 
 type (
@@ -80,6 +106,48 @@ type (
 
 		// OrderField indicates the fields to order the classes retrieved
 		OrderFields []string `yaml:"OrderFields"`
+
+		// Descending orders by each of the OrderFields in descending order
+		Descending bool `yaml:"Descending,omitempty"`
+
+		// Where restricts the definitions selected to those matching every predicate
+		Where []WherePredicate `yaml:"Where,omitempty"`
+
+		// Skip and Limit select a page of the definitions, after ordering; a Limit of zero selects every definition
+		Skip  int `yaml:"Skip,omitempty"`
+		Limit int `yaml:"Limit,omitempty"`
+
+		// Aggregations summarise the definitions of an aggregate class related to each definition of the section,
+		// rather than returning a row for each of them
+		Aggregations []Aggregation `yaml:"Aggregations,omitempty"`
+	}
+
+	// Aggregation summarises a field of the definitions of an aggregate class; the result is added to the fields of
+	// each SectionDefinition keyed by Class.As
+	Aggregation struct {
+		// Function is one of count, collect, min, max, sum or avg
+		Function string `yaml:"Function"`
+
+		// Field is required for every function except count, which otherwise counts the definitions
+		Field string `yaml:"Field,omitempty"`
+
+		// Distinct only includes each distinct value once
+		Distinct bool `yaml:"Distinct,omitempty"`
+
+		// As names the result; defaults to the function followed by the field, such as collectName
+		As string `yaml:"As,omitempty"`
+	}
+
+	// WherePredicate compares a field of the definitions selected with either a Value, or a Param provided when the
+	// report is generated
+	WherePredicate struct {
+		Field string `yaml:"Field"`
+
+		// Operator is one of =, !=, <, <=, >, >=, in or contains; defaults to =
+		Operator string `yaml:"Operator,omitempty"`
+
+		Value interface{} `yaml:"Value,omitempty"`
+		Param string      `yaml:"Param,omitempty"`
 	}
 
 	// TemplateSection TODO
@@ -92,8 +160,22 @@ type (
 
 		// CompositeSections defines any sections for composite classes referenced by the section class
 		CompositeSections []TemplateSection `yaml:"CompositeSections,omitempty"`
+
+		// Recursive re-applies the section to the definitions related to each of its definitions by the
+		// RecursiveRelationship, in the direction given by RelationshipFrom or RelationshipTo, nesting them beneath it
+		// keyed by the RecursiveRelationship, to a depth of MaxDepth; a MaxDepth of zero continues until no further
+		// definitions are related
+		Recursive bool `yaml:"Recursive,omitempty"`
+		MaxDepth  int  `yaml:"MaxDepth,omitempty"`
+
+		// RecursiveRelationship defaults to the Relationship of the SectionClass, so that a recursive composite
+		// section can be related to its parent by a different relationship
+		RecursiveRelationship string `yaml:"RecursiveRelationship,omitempty"`
 	}
 
+	// cypherRunner runs a Cypher query, returning every record
+	cypherRunner func(cypher string, params map[string]interface{}) ([]*neo4j.Record, error)
+
 	// SectionDefinition TODO
 	SectionDefinition struct {
 		Class string
@@ -106,31 +188,255 @@ type (
 	}
 )
 
+var (
+	aggregationFunctions = []string{aggregationCount, "collect", "min", "max", "sum", "avg"}
+
+	// whereOperators maps each Where operator to its Cypher equivalent
+	whereOperators = map[string]string{
+		"=":        "=",
+		"!=":       "<>",
+		"<":        "<",
+		"<=":       "<=",
+		">":        ">",
+		">=":       ">=",
+		"in":       "IN",
+		"contains": "CONTAINS",
+	}
+
+	// reservedParameter matches the names of the parameters generated for the Cypher of each section
+	reservedParameter = regexp.MustCompile(`^(parentIDs|whereValue\d+)$`)
+)
+
+// name returns the name of the aggregation within the fields of each SectionDefinition
+func (a Aggregation) name(class string) string {
+	as := strings.TrimSpace(a.As)
+	if as == "" {
+		as = strings.ToLower(strings.TrimSpace(a.Function)) + strings.TrimSpace(a.Field)
+	}
+	return fmt.Sprintf(classFieldIdentifier, class, as)
+}
+
+// variable returns the variable for the class within the Cypher for a section, which is the ClassAlias if one is
+// provided; fields are identified by the variable
+func (s ClassFieldSelector) variable() string {
+	if alias := strings.TrimSpace(s.ClassAlias); alias != "" {
+		return alias
+	}
+	return strings.TrimSpace(s.Class)
+}
+
+// getAggregations returns the Cypher for each aggregation of the aggregate class
+func getAggregations(aggregateClass ClassFieldSelector) ([]string, error) {
+	var aggregations []string
+	for _, a := range aggregateClass.Aggregations {
+		function := strings.ToLower(strings.TrimSpace(a.Function))
+		if !contains(aggregationFunctions, function) {
+			return nil, fmt.Errorf(errorInvalidAggregation, a.Function, aggregateClass.Class)
+		}
+
+		argument := aggregateClass.variable()
+		if field := strings.TrimSpace(a.Field); field != "" {
+			argument = fmt.Sprintf(classFieldIdentifier, aggregateClass.variable(), field)
+		} else if function != aggregationCount {
+			return nil, fmt.Errorf(errorAggregationField, a.Function, aggregateClass.Class)
+		}
+
+		distinct := ""
+		if a.Distinct {
+			distinct = aggregationDistinct
+		}
+		aggregations = append(aggregations, fmt.Sprintf(aggregationCypher, function, distinct, argument,
+			a.name(aggregateClass.variable())))
+	}
+	return aggregations, nil
+}
+
+// getAggregateClauses returns the clauses to match each aggregate class, and the variables to return in addition to
+// those already returned. Aggregate classes with aggregations are each summarised by a with clause, so that a single
+// row is returned for each combination of the section class and any aggregate classes without aggregations.
+func getAggregateClauses(section TemplateSection, variable string, returned []string, params,
+	cypherParams map[string]interface{}) (string, []string, error) {
+	var matchClause string
+	returned = append([]string{}, returned...)
+	for _, aggregateClass := range section.AggregateClasses {
+		aggregateMatchClause, err := getAggregateMatchClause(section, variable, aggregateClass, params, cypherParams)
+		if err != nil {
+			return "", nil, err
+		}
+		matchClause += aggregateMatchClause
+		if len(aggregateClass.Aggregations) == 0 {
+			returned = append(returned, aggregateClass.variable())
+			continue
+		}
+
+		aggregations, err := getAggregations(aggregateClass)
+		if err != nil {
+			return "", nil, err
+		}
+		matchClause += fmt.Sprintf(withCypherClause, strings.Join(append(returned, aggregations...), ","))
+		for _, a := range aggregateClass.Aggregations {
+			returned = append(returned, fmt.Sprintf(aggregationVariable, a.name(aggregateClass.variable())))
+		}
+	}
+	return matchClause, returned, nil
+}
+
+// getAggregateMatchClause returns the clause to match the aggregate class; as for the section class, RelationshipFrom
+// and RelationshipTo give the direction of the relationship from the aggregate class. Any Where predicates restrict
+// the definitions of the aggregate class matched, without restricting the definitions of the section.
+func getAggregateMatchClause(section TemplateSection, variable string, aggregateClass ClassFieldSelector, params,
+	cypherParams map[string]interface{}) (string, error) {
+	relationshipTo := ""
+	if aggregateClass.RelationshipTo {
+		relationshipTo = "<"
+	}
+
+	relationshipFrom := ""
+	if aggregateClass.RelationshipFrom {
+		relationshipFrom = ">"
+	}
+
+	matchClause := fmt.Sprintf(aggregateCypherMatchClause, variable, strings.TrimSpace(section.SectionClass.Class),
+		relationshipTo, aggregateClass.Relationship, relationshipFrom, aggregateClass.variable(),
+		strings.TrimSpace(aggregateClass.Class))
+
+	predicates, err := getWherePredicates(aggregateClass.variable(), aggregateClass, params, cypherParams)
+	if err != nil {
+		return "", err
+	}
+	if len(predicates) > 0 {
+		matchClause += fmt.Sprintf(whereCypherClause, strings.Join(predicates, whereCypherConjunction))
+	}
+	return matchClause, nil
+}
+
+// validateVariables checks that the section class and each aggregate class can be identified by their variable, so
+// that the same class can only be selected more than once if each has a different ClassAlias. Aggregate classes are
+// matched for each definition of the section, so cannot be paged, and cannot be ordered once summarised.
+func validateVariables(section TemplateSection) error {
+	variables := []string{parentVariable, section.SectionClass.variable()}
+	for _, aggregateClass := range section.AggregateClasses {
+		if aggregateClass.Skip > 0 || aggregateClass.Limit > 0 {
+			return fmt.Errorf(errorAggregatePage, aggregateClass.Class)
+		}
+		if len(aggregateClass.OrderFields) > 0 && len(aggregateClass.Aggregations) > 0 {
+			return fmt.Errorf(errorAggregateOrder, aggregateClass.Class)
+		}
+
+		variable := aggregateClass.variable()
+		if contains(variables, variable) {
+			return fmt.Errorf(errorDuplicateVariable, aggregateClass.Class, variable)
+		}
+		variables = append(variables, variable)
+	}
+	return nil
+}
+
+// hasRepeatedRows indicates whether the Cypher for the section can return more than one row for a definition, which is
+// the case for any aggregate class without aggregations
+func hasRepeatedRows(section TemplateSection) bool {
+	for _, aggregateClass := range section.AggregateClasses {
+		if len(aggregateClass.Aggregations) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAggregations(section TemplateSection) bool {
+	for _, aggregateClass := range section.AggregateClasses {
+		if len(aggregateClass.Aggregations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getOrderClause orders by the OrderFields of the section class, and then by those of each aggregate class without
+// aggregations, which orders the rows returned for each definition
 func getOrderClause(section TemplateSection) (orderClause string) {
-	for i, field := range section.SectionClass.OrderFields {
-		if i == 0 {
-			orderClause = fmt.Sprintf(orderClauseSingular, section.SectionClass.Class, field)
-		} else {
-			orderClause = fmt.Sprintf(orderClauseMultiple, orderClause, section.SectionClass.Class, field)
+	selectors := []ClassFieldSelector{section.SectionClass}
+	for _, aggregateClass := range section.AggregateClasses {
+		if len(aggregateClass.Aggregations) == 0 {
+			selectors = append(selectors, aggregateClass)
+		}
+	}
+
+	for _, selector := range selectors {
+		for _, field := range selector.OrderFields {
+			if selector.Descending {
+				field += orderDescending
+			}
+			if orderClause == "" {
+				orderClause = fmt.Sprintf(orderClauseSingular, selector.variable(), field)
+			} else {
+				orderClause = fmt.Sprintf(orderClauseMultiple, orderClause, selector.variable(), field)
+			}
 		}
 	}
 
 	return
 }
 
-func getCypherForSection(parentClass string, parentID string, section TemplateSection) string {
+// getWherePredicates returns the where predicates for the selector, adding the value of each predicate to the Cypher
+// parameters; predicates referring to a report parameter use the parameter directly
+func getWherePredicates(variable string, selector ClassFieldSelector, params,
+	cypherParams map[string]interface{}) ([]string, error) {
+	var predicates []string
+	for _, w := range selector.Where {
+		if strings.TrimSpace(w.Field) == "" {
+			return nil, fmt.Errorf(errorMissingWhereField, selector.Class)
+		}
+		operator := strings.ToLower(strings.TrimSpace(w.Operator))
+		if operator == "" {
+			operator = "="
+		}
+		cypherOperator, ok := whereOperators[operator]
+		if !ok {
+			return nil, fmt.Errorf(errorInvalidWhereOperator, w.Operator, w.Field)
+		}
+
+		name := w.Param
+		switch {
+		case name != "" && w.Value != nil:
+			return nil, fmt.Errorf(errorWhereValueAndParam, w.Field)
+		case name == "" && w.Value == nil:
+			return nil, fmt.Errorf(errorWhereNoValue, w.Field)
+		case name == "" && operator == "in" && reflect.ValueOf(w.Value).Kind() != reflect.Slice:
+			return nil, fmt.Errorf(errorWhereInValue, w.Field)
+		case reservedParameter.MatchString(name):
+			return nil, fmt.Errorf(errorReservedParameter, name)
+		case name != "":
+			v, ok := params[name]
+			if !ok {
+				return nil, fmt.Errorf(errorMissingParameter, name, selector.Class)
+			}
+			cypherParams[name] = v
+		default:
+			name = fmt.Sprintf(whereValueParameter, len(cypherParams))
+			cypherParams[name] = w.Value
+		}
+
+		predicates = append(predicates, fmt.Sprintf(wherePredicateCypher, variable, strings.TrimSpace(w.Field),
+			cypherOperator, name))
+	}
+
+	return predicates, nil
+}
+
+// getCypherForSection returns the parameterised Cypher to select the definitions of the section related to any of
+// the parents, together with the parent of each, or every definition of the section class if there are no parents
+func getCypherForSection(parentClass string, parentIDs []string, section TemplateSection,
+	params map[string]interface{}) (string, map[string]interface{}, error) {
 	var matchClause, returnClause, orderClause string
 
 	sectionClass := strings.TrimSpace(section.SectionClass.Class)
-	sectionClassAlias := strings.TrimSpace(section.SectionClass.ClassAlias)
-
-	// default to the class name if no alias is provided
-	if sectionClassAlias == "" {
-		sectionClassAlias = sectionClass
+	variable := section.SectionClass.variable()
+	if err := validateVariables(section); err != nil {
+		return "", nil, err
 	}
 	parentClass = strings.TrimSpace(parentClass)
 
-	returnClause = sectionClassAlias
 	orderClause = getOrderClause(section)
 
 	relationshipFrom := ""
@@ -143,23 +449,85 @@ func getCypherForSection(parentClass string, parentID string, section TemplateSe
 		relationshipTo = ">"
 	}
 
+	returned := []string{variable}
 	if parentClass == "" {
-		matchClause = fmt.Sprintf(rootCypherMatchClause, sectionClass, sectionClass)
+		matchClause = fmt.Sprintf(rootCypherMatchClause, variable, sectionClass)
 	} else {
-		matchClause = fmt.Sprintf(compositeCypherMatchClause, sectionClassAlias, sectionClass,
-			relationshipFrom, section.SectionClass.Relationship, relationshipTo, parentClass, parentClass, strings.TrimSpace(parentID))
+		matchClause = fmt.Sprintf(compositeCypherMatchClause, variable, sectionClass,
+			relationshipFrom, section.SectionClass.Relationship, relationshipTo, parentVariable, parentClass)
+		returned = append(returned, parentVariable)
 	}
 
-	for _, aggregateClass := range section.AggregateClasses {
-		aggregateMatchClause := fmt.Sprintf(aggregateCypherMatchClause, sectionClass, sectionClass,
-			aggregateClass.Relationship, aggregateClass.Class, aggregateClass.Class)
-		matchClause = matchClause + aggregateMatchClause
+	cypherParams := map[string]interface{}{}
+	predicates, err := getWherePredicates(variable, section.SectionClass, params, cypherParams)
+	if err != nil {
+		return "", nil, err
+	}
+	if parentClass != "" {
+		predicates = append([]string{fmt.Sprintf(parentIDsPredicate, parentVariable, parentIDsParameter)},
+			predicates...)
+		cypherParams[parentIDsParameter] = parentIDs
+	}
+	if len(predicates) > 0 {
+		matchClause += fmt.Sprintf(whereCypherClause, strings.Join(predicates, whereCypherConjunction))
+	}
 
-		aggregateReturnClause := fmt.Sprintf(aggregateCypherOrderClause, aggregateClass.Class)
-		returnClause = returnClause + aggregateReturnClause
+	if hasAggregations(section) {
+		aggregateClause, aggregateReturned, err := getAggregateClauses(section, variable, returned, params,
+			cypherParams)
+		if err != nil {
+			return "", nil, err
+		}
+		matchClause += aggregateClause
+		returnClause = strings.Join(aggregateReturned, ",")
+	} else {
+		returnClause = strings.Join(returned, ",")
+		for _, aggregateClass := range section.AggregateClasses {
+			aggregateMatchClause, err := getAggregateMatchClause(section, variable, aggregateClass, params,
+				cypherParams)
+			if err != nil {
+				return "", nil, err
+			}
+			matchClause = matchClause + aggregateMatchClause
+
+			aggregateReturnClause := fmt.Sprintf(aggregateCypherOrderClause, aggregateClass.variable())
+			returnClause = returnClause + aggregateReturnClause
+		}
+	}
+
+	cypher := fmt.Sprintf(baseTemplateCypher, matchClause, returnClause)
+	if orderClause != "" {
+		cypher += fmt.Sprintf(orderByCypherClause, orderClause)
+	}
+
+	// definitions related to a parent are paged for each parent once they have been returned, as are definitions
+	// returned in more than one row
+	if parentClass == "" && !hasRepeatedRows(section) {
+		if section.SectionClass.Skip > 0 {
+			cypher += fmt.Sprintf(skipCypherClause, section.SectionClass.Skip)
+		}
+		if section.SectionClass.Limit > 0 {
+			cypher += fmt.Sprintf(limitCypherClause, section.SectionClass.Limit)
+		}
+	}
+
+	return cypher, cypherParams, nil
+}
+
+// validateParams checks that none of the report parameters have the name of a parameter generated for each section
+func validateParams(params map[string]interface{}) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	return fmt.Sprintf(baseTemplateCypher, matchClause, returnClause, orderClause)
+	for _, name := range names {
+		if reservedParameter.MatchString(name) {
+			return fmt.Errorf(errorReservedParameter, name)
+		}
+	}
+	return nil
 }
 
 func loadTemplateConf(cfgPath string) (ms *TemplateSection, err error) {
@@ -181,151 +549,298 @@ func loadTemplateConf(cfgPath string) (ms *TemplateSection, err error) {
 	return ms, nil
 }
 
-func fromMarkdown(inputString string) htmltemplate.HTML {
-	var buf bytes.Buffer
-	if err := goldmark.Convert([]byte(inputString), &buf); err != nil {
-		panic(err)
+// ParseTemplate renders the definitions selected by the template configuration with a single template
+func ParseTemplate(dbURL, username, password, templateConf, templatePath string, writer io.Writer) error {
+	return GenerateReport(dbURL, username, password, templateConf, ReportOptions{Templates: []string{templatePath}},
+		writer)
+}
+
+func contains(a []string, x string) bool {
+	for _, n := range a {
+		if x == n {
+			return true
+		}
 	}
+	return false
+}
 
-	return htmltemplate.HTML(buf.String())
+// sessionRunner returns a cypherRunner which runs each query in the session
+func sessionRunner(session neo4j.Session) cypherRunner {
+	return func(cypher string, params map[string]interface{}) ([]*neo4j.Record, error) {
+		res, err := graph.ExecuteCypher(session, cypher, params)
+		if err != nil {
+			return nil, err
+		}
+		return res.Collect()
+	}
 }
 
-func nilToStr(v interface{}) interface{} {
-	if v == nil {
-		return ""
+// recurseTemplateSection selects the definitions of the section, and of each of its composite sections, related to
+// the parent, or every definition of the section class if there is no parent
+func recurseTemplateSection(session neo4j.Session, section TemplateSection, parentClass, parentID *string,
+	params map[string]interface{}) ([]SectionDefinition, error) {
+	if parentClass == nil || parentID == nil {
+		definitions, err := loadSection(sessionRunner(session), section, "", nil, params)
+		return definitions[""], err
 	}
-	return v
+
+	definitions, err := loadSection(sessionRunner(session), section, *parentClass, []string{*parentID}, params)
+	return definitions[*parentID], err
 }
 
-func getTemplateFuncs() texttemplate.FuncMap {
-	return texttemplate.FuncMap{
-		funcMarkdown: fromMarkdown,
-		funcNilToStr: nilToStr,
+// loadSection selects the definitions of the section related to any of the parents, or every definition of the
+// section class if there are no parents, keyed by the ID of their parent. Each composite section is selected with a
+// single query for every definition, and each level of a recursive section with a single query for the level, so the
+// number of queries does not depend on the number of definitions.
+func loadSection(run cypherRunner, section TemplateSection, parentClass string, parentIDs []string,
+	params map[string]interface{}) (map[string][]SectionDefinition, error) {
+	if section.Recursive {
+		if section.recursiveRelationship() == "" {
+			return nil, fmt.Errorf(errorRecursiveRelationship, section.SectionClass.Class)
+		}
+		if !section.SectionClass.RelationshipFrom && !section.SectionClass.RelationshipTo {
+			return nil, fmt.Errorf(errorRecursiveDirection, section.SectionClass.Class)
+		}
+	}
+
+	keys := parentIDs
+	if parentClass == "" {
+		keys = []string{""}
+	}
+
+	definitions, err := selectDefinitions(run, section, parentClass, keys, params)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	ids := definitionIDs(definitions, keys, selected)
+
+	var recursion map[string][]SectionDefinition
+	if section.Recursive {
+		var recursiveIDs []string
+		if recursion, recursiveIDs, err = loadRecursion(run, section, ids, selected, params); err != nil {
+			return nil, err
+		}
+		ids = append(ids, recursiveIDs...)
 	}
+
+	for _, childSection := range section.CompositeSections {
+		var childDefinitions map[string][]SectionDefinition
+		if len(ids) > 0 {
+			childDefinitions, err = loadSection(run, childSection, section.SectionClass.Class, ids, params)
+			if err != nil {
+				log.Err(err).Msg(logErrorParsingTemplateDefinitions)
+				return nil, err
+			}
+		}
+
+		for _, d := range []map[string][]SectionDefinition{definitions, recursion} {
+			for _, sectionDefinitions := range d {
+				for _, definition := range sectionDefinitions {
+					definition.CompositeSectionDefinitions[childSection.SectionClass.Relationship] =
+						childDefinitions[definition.ID]
+				}
+			}
+		}
+	}
+
+	if section.Recursive {
+		for _, key := range keys {
+			definitions[key] = nestRecursion(definitions[key], recursion, section, 1, map[string]bool{})
+		}
+	}
+
+	return definitions, nil
 }
 
-// ParseTemplate TODO
-func ParseTemplate(dbURL, username, password, templateConf, templatePath string, writer io.Writer) error {
-	// first load the template configuration
-	templateSection, err := loadTemplateConf(templateConf)
+// selectDefinitions runs the query for the section, returning the definitions related to each of the parents keyed
+// by the ID of their parent, or every definition of the section class keyed by an empty ID if there is no parent
+func selectDefinitions(run cypherRunner, section TemplateSection, parentClass string, keys []string,
+	params map[string]interface{}) (map[string][]SectionDefinition, error) {
+	var parentIDs []string
+	if parentClass != "" {
+		parentIDs = keys
+	}
+
+	cypher, cypherParams, err := getCypherForSection(parentClass, parentIDs, section, params)
 	if err != nil {
-		log.Error().Err(err).Msg(logErrorGraphDatabaseConnectionFailed)
-		return err
+		return nil, err
 	}
 
-	// then connect to the graph database
-	driver, session, err := graph.Init(dbURL, username, password)
+	records, err := run(cypher, cypherParams)
 	if err != nil {
-		log.Error().Err(err).Msg(logErrorGraphDatabaseConnectionFailed)
-		return err
+		log.Error().Err(err).Msgf(logErrorExecutingCypher)
+		return nil, err
 	}
 
-	defer driver.Close()
-	defer session.Close()
+	definitions := map[string][]SectionDefinition{}
+	for _, record := range records {
+		parentID, definition := getSectionDefinition(section, record)
+		definitions[parentID] = append(definitions[parentID], definition)
+	}
 
-	// now recurse through the sections
-	definitions, err := recurseTemplateSection(session, *templateSection, nil, nil)
-	if err != nil {
-		log.Error().Err(err).Msg(logErrorParsingTemplateDefinitions)
-		return err
+	if parentClass != "" || hasRepeatedRows(section) {
+		for _, key := range keys {
+			definitions[key] = pageDefinitions(definitions[key], section.SectionClass)
+		}
 	}
 
-	template := texttemplate.Must(texttemplate.New(filepath.Base(templatePath)).Option("missingkey=zero").Funcs(getTemplateFuncs()).ParseFiles(templatePath))
-	return template.Execute(writer, definitions)
+	return definitions, nil
 }
 
-func contains(a []string, x string) bool {
-	for _, n := range a {
-		if x == n {
-			return true
+// definitionIDs returns the IDs of the definitions of each key which have not already been selected, in order
+func definitionIDs(definitions map[string][]SectionDefinition, keys []string, selected map[string]bool) []string {
+	var ids []string
+	for _, key := range keys {
+		for _, definition := range definitions[key] {
+			if definition.ID != "" && !selected[definition.ID] {
+				selected[definition.ID] = true
+				ids = append(ids, definition.ID)
+			}
 		}
 	}
-	return false
+	return ids
 }
 
-func recurseTemplateSection(session neo4j.Session, section TemplateSection, parentClass, parentID *string) ([]SectionDefinition, error) {
-	var res neo4j.Result
-	var err error
-	var definitions []SectionDefinition
-	var cypher string
+// recursiveRelationship returns the relationship followed by a recursive section
+func (s TemplateSection) recursiveRelationship() string {
+	if relationship := strings.TrimSpace(s.RecursiveRelationship); relationship != "" {
+		return relationship
+	}
+	return strings.TrimSpace(s.SectionClass.Relationship)
+}
 
-	if parentClass == nil || parentID == nil {
-		cypher = getCypherForSection("", "", section)
-	} else {
-		cypher = getCypherForSection(*parentClass, *parentID, section)
+// loadRecursion re-applies the recursive section to each of the definitions selected, and then to each of the
+// definitions related to them in turn, until no new definitions are selected or MaxDepth is reached; the definitions
+// are keyed by the ID of their parent, together with the IDs of the new definitions. The Where, Skip and Limit of the
+// section select only the definitions at the top of the hierarchy, so are not applied to the levels beneath them.
+func loadRecursion(run cypherRunner, section TemplateSection, ids []string, selected map[string]bool,
+	params map[string]interface{}) (map[string][]SectionDefinition, []string, error) {
+	section.SectionClass.Where, section.SectionClass.Skip, section.SectionClass.Limit = nil, 0, 0
+	section.SectionClass.Relationship = section.recursiveRelationship()
+
+	recursion := map[string][]SectionDefinition{}
+	var recursiveIDs []string
+	for depth := 1; len(ids) > 0 && (section.MaxDepth <= 0 || depth < section.MaxDepth); depth++ {
+		definitions, err := selectDefinitions(run, section, section.SectionClass.Class, ids, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			recursion[id] = definitions[id]
+		}
+		ids = definitionIDs(definitions, ids, selected)
+		recursiveIDs = append(recursiveIDs, ids...)
 	}
+	return recursion, recursiveIDs, nil
+}
 
-	res, err = graph.ExecuteCypher(session, cypher, nil)
-	if (err != nil) || (res.Err() != nil) {
-		log.Error().Err(err).Msgf(logErrorExecutingCypher)
-		return definitions, err
+// nestRecursion returns a copy of the definitions with the definitions related to each by the recursive section
+// nested beneath it, to MaxDepth. Any definition which is already an ancestor is omitted, so that cycles end.
+func nestRecursion(definitions []SectionDefinition, recursion map[string][]SectionDefinition,
+	section TemplateSection, depth int, ancestors map[string]bool) []SectionDefinition {
+	var nested []SectionDefinition
+	for _, definition := range definitions {
+		if ancestors[definition.ID] {
+			continue
+		}
+
+		compositeSectionDefinitions := make(map[string][]SectionDefinition, len(definition.CompositeSectionDefinitions)+1)
+		for relationship, children := range definition.CompositeSectionDefinitions {
+			compositeSectionDefinitions[relationship] = children
+		}
+		definition.CompositeSectionDefinitions = compositeSectionDefinitions
+
+		if section.MaxDepth <= 0 || depth < section.MaxDepth {
+			ancestors[definition.ID] = true
+			compositeSectionDefinitions[section.recursiveRelationship()] = nestRecursion(recursion[definition.ID],
+				recursion, section, depth+1, ancestors)
+			delete(ancestors, definition.ID)
+		}
+		nested = append(nested, definition)
 	}
+	return nested
+}
 
-	for res.Next() {
-		record := res.Record()
+// pageDefinitions returns the page of definitions selected by the Skip and Limit of the selector. Each distinct
+// definition counts once, together with every row returned for it with the definitions of any aggregate class.
+func pageDefinitions(definitions []SectionDefinition, selector ClassFieldSelector) []SectionDefinition {
+	if selector.Skip <= 0 && selector.Limit <= 0 {
+		return definitions
+	}
 
-		// create definition here: class+aggregate combinations are returned by the subsequent loop
-		definition := SectionDefinition{
-			Class:                       section.SectionClass.Class,
-			Fields:                      map[string]interface{}{},
-			CompositeSectionDefinitions: map[string][]SectionDefinition{},
+	var paged []SectionDefinition
+	position := map[string]int{}
+	for _, definition := range definitions {
+		p, ok := position[definition.ID]
+		if !ok {
+			p = len(position)
+			position[definition.ID] = p
 		}
-		for _, kv := range record.Values {
-			node, isNode := kv.(neo4j.Node)
-			if isNode {
-				// TODO dangerous - refactor
-				nodeClass := node.Labels[0]
-
-				if nodeClass == section.SectionClass.Class {
-					if node.Props["ID"] != nil {
-						if definitionID, ok := node.Props["ID"].(string); ok {
-							definition.ID = definitionID
-						} else {
-							log.Warn().Msg(logErrorNonStringDefinitionID)
-						}
-					} else {
-						log.Warn().Msg(logErrorNilDefinitionID)
-					}
-
-					nodeClassAlias := strings.TrimSpace(section.SectionClass.ClassAlias)
-					if nodeClassAlias == "" {
-						nodeClassAlias = section.SectionClass.Class
-					}
-
-					for _, key := range section.SectionClass.Fields {
-						if fieldTypeValid(node.Props[key]) {
-							definition.Fields[fmt.Sprintf(classFieldIdentifier, nodeClassAlias, key)] = node.Props[key]
-						}
-					}
-				} else {
-					for _, a := range section.AggregateClasses {
-						if nodeClass == a.Class {
-							for _, key := range a.Fields {
-								if fieldTypeValid(node.Props[key]) {
-									// TODO need to use the alias for non-section class
-									definition.Fields[fmt.Sprintf(classFieldIdentifier, nodeClass, key)] = node.Props[key]
-								}
-							}
-						}
-					}
-				}
+		if p >= selector.Skip && (selector.Limit <= 0 || p < selector.Skip+selector.Limit) {
+			paged = append(paged, definition)
+		}
+	}
+	return paged
+}
 
-				//recurse through any child sections
-				// TODO recursion, really?
-				for _, childSection := range section.CompositeSections {
+// getSectionDefinition returns the definition for a record returned by the Cypher for the section, together with the
+// ID of its parent
+func getSectionDefinition(section TemplateSection, record *neo4j.Record) (parentID string,
+	definition SectionDefinition) {
+	// create definition here: class+aggregate combinations are returned by the subsequent loop
+	definition = SectionDefinition{
+		Class:                       section.SectionClass.Class,
+		Fields:                      map[string]interface{}{},
+		CompositeSectionDefinitions: map[string][]SectionDefinition{},
+	}
+	aggregateClasses := map[string]ClassFieldSelector{}
+	for _, aggregateClass := range section.AggregateClasses {
+		aggregateClasses[aggregateClass.variable()] = aggregateClass
+	}
+
+	// each value is identified by the variable returned by the Cypher for the section
+	for i, kv := range record.Values {
+		if i >= len(record.Keys) || kv == nil {
+			continue
+		}
+		key := record.Keys[i]
+		node, isNode := kv.(neo4j.Node)
+		aggregateClass, isAggregate := aggregateClasses[key]
 
-					childDefinitions, err := recurseTemplateSection(session, childSection, &(section.SectionClass.Class), &definition.ID)
-					if err != nil {
-						log.Err(err).Msg(logErrorParsingTemplateDefinitions)
-						return definitions, err
-					}
-					definition.CompositeSectionDefinitions[childSection.SectionClass.Relationship] = childDefinitions
+		switch {
+		case key == parentVariable:
+			if isNode {
+				parentID, _ = node.Props["ID"].(string)
+			}
+		case !isNode:
+			// any other values are the results of aggregations, named by their key
+			definition.Fields[key] = kv
+		case key == section.SectionClass.variable():
+			if node.Props["ID"] != nil {
+				if definitionID, ok := node.Props["ID"].(string); ok {
+					definition.ID = definitionID
+				} else {
+					log.Warn().Msg(logErrorNonStringDefinitionID)
 				}
+			} else {
+				log.Warn().Msg(logErrorNilDefinitionID)
 			}
+			addSelectorFields(definition.Fields, section.SectionClass, node)
+		case isAggregate:
+			addSelectorFields(definition.Fields, aggregateClass, node)
 		}
-
-		definitions = append(definitions, definition)
 	}
 
-	return definitions, err
+	return parentID, definition
+}
+
+// addSelectorFields adds each of the fields of the selector from the node, keyed by the variable for the selector
+func addSelectorFields(fields map[string]interface{}, selector ClassFieldSelector, node neo4j.Node) {
+	for _, key := range selector.Fields {
+		if fieldTypeValid(node.Props[key]) {
+			fields[fmt.Sprintf(classFieldIdentifier, selector.variable(), key)] = node.Props[key]
+		}
+	}
 }
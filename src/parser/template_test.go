@@ -23,9 +23,115 @@ import (
 	"github.com/nextmetaphor/yaml-graph/graph"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"strings"
 	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
+// sectionGraph is an in-memory graph of providers and the services related to each, which answers the queries for
+// a Provider section with a Service composite section
+type sectionGraph struct {
+	providers []string
+	services  map[string][]string
+	queries   int
+}
+
+func newSectionGraph(providers, services int) *sectionGraph {
+	g := &sectionGraph{services: map[string][]string{}}
+	for p := 0; p < providers; p++ {
+		provider := fmt.Sprintf("provider-%d", p)
+		g.providers = append(g.providers, provider)
+		for s := 0; s < services; s++ {
+			g.services[provider] = append(g.services[provider], fmt.Sprintf("%s-service-%d", provider, s))
+		}
+	}
+	return g
+}
+
+func (g *sectionGraph) run(_ string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	g.queries++
+
+	var records []*neo4j.Record
+	parentIDs, ok := params[parentIDsParameter].([]string)
+	if !ok {
+		for _, provider := range g.providers {
+			records = append(records, &neo4j.Record{Keys: []string{"Provider"}, Values: []interface{}{
+				neo4j.Node{Labels: []string{"Provider"}, Props: map[string]interface{}{"ID": provider, "Name": provider}},
+			}})
+		}
+		return records, nil
+	}
+
+	for _, parentID := range parentIDs {
+		for _, service := range g.services[parentID] {
+			records = append(records, &neo4j.Record{Keys: []string{"Service", parentVariable}, Values: []interface{}{
+				neo4j.Node{Labels: []string{"Service"}, Props: map[string]interface{}{"ID": service, "Name": service}},
+				neo4j.Node{Labels: []string{"Provider"}, Props: map[string]interface{}{"ID": parentID}},
+			}})
+		}
+	}
+	return records, nil
+}
+
+// hierarchyGraph is an in-memory graph of capabilities, each the parent of its children, which answers the queries
+// for a recursive Capability section
+type hierarchyGraph struct {
+	roots    []string
+	children map[string][]string
+	queries  int
+	cypher   []string
+}
+
+func (g *hierarchyGraph) run(cypher string, params map[string]interface{}) ([]*neo4j.Record, error) {
+	g.queries++
+	g.cypher = append(g.cypher, cypher)
+
+	capability := func(id string) neo4j.Node {
+		return neo4j.Node{Labels: []string{"Capability"}, Props: map[string]interface{}{"ID": id}}
+	}
+
+	var records []*neo4j.Record
+	parentIDs, ok := params[parentIDsParameter].([]string)
+	if !ok {
+		for _, id := range g.roots {
+			records = append(records, &neo4j.Record{Keys: []string{"Capability"}, Values: []interface{}{capability(id)}})
+		}
+		return records, nil
+	}
+
+	for _, parentID := range parentIDs {
+		for _, id := range g.children[parentID] {
+			records = append(records, &neo4j.Record{Keys: []string{"Capability", parentVariable},
+				Values: []interface{}{capability(id), capability(parentID)}})
+		}
+	}
+	return records, nil
+}
+
+// hierarchy describes the IDs of the definitions nested beneath each definition by the relationship
+func hierarchy(definitions []SectionDefinition, relationship string) string {
+	var ids []string
+	for _, definition := range definitions {
+		id := definition.ID
+		if children, ok := definition.CompositeSectionDefinitions[relationship]; ok {
+			id += "(" + hierarchy(children, relationship) + ")"
+		}
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func providerServicesSection(skip, limit int) TemplateSection {
+	return TemplateSection{
+		SectionClass: ClassFieldSelector{Class: "Provider", Fields: []string{"Name"}},
+		CompositeSections: []TemplateSection{{
+			SectionClass: ClassFieldSelector{Class: "Service", Fields: []string{"Name"}, Relationship: "PROVIDED_BY",
+				Skip: skip, Limit: limit},
+		}},
+	}
+}
+
 func Test_getOrderClause(t *testing.T) {
 	t.Run("SingleOrderClause", func(t *testing.T) {
 		clause := getOrderClause(
@@ -89,7 +195,7 @@ func Test_getOrderClause(t *testing.T) {
 
 func Test_getCypherForSelector(t *testing.T) {
 	t.Run("ParentClass", func(t *testing.T) {
-		cypher := getCypherForSection("parent", "parentID",
+		cypher, params, err := getCypherForSection("parent", []string{"parentID"},
 			TemplateSection{
 				SectionClass: ClassFieldSelector{
 					Class:        "child",
@@ -99,13 +205,15 @@ func Test_getCypherForSelector(t *testing.T) {
 				},
 				AggregateClasses:  nil,
 				CompositeSections: nil,
-			})
+			}, nil)
 
-		assert.Equal(t, "match (child:child)-[:inherits_from]-(parent:parent {ID:\"parentID\"}) return child "+
-			"order by child.field1,child.field2", cypher)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"parentIDs": []string{"parentID"}}, params)
+		assert.Equal(t, "match (child:child)-[:inherits_from]-(parentNode:parent) where parentNode.ID IN $parentIDs "+
+			"return child,parentNode order by child.field1,child.field2", cypher)
 	})
 	t.Run("NoParentClass", func(t *testing.T) {
-		cypher := getCypherForSection("", "",
+		cypher, params, err := getCypherForSection("", nil,
 			TemplateSection{
 				SectionClass: ClassFieldSelector{
 					Class:        "myclass",
@@ -115,10 +223,420 @@ func Test_getCypherForSelector(t *testing.T) {
 				},
 				AggregateClasses:  nil,
 				CompositeSections: nil,
-			})
+			}, nil)
 
+		assert.Nil(t, err)
+		assert.Empty(t, params)
 		assert.Equal(t, "match (myclass:myclass) return myclass order by myclass.field1,myclass.field2", cypher)
 	})
+	t.Run("WhereLimitSkip", func(t *testing.T) {
+		cypher, params, err := getCypherForSection("", nil,
+			TemplateSection{
+				SectionClass: ClassFieldSelector{
+					Class:       "Service",
+					OrderFields: []string{"Cost", "Name"},
+					Descending:  true,
+					Where: []WherePredicate{
+						{Field: "Cost", Operator: ">=", Value: 10},
+						{Field: "Provider", Param: "provider"},
+						{Field: "Region", Operator: "IN", Value: []interface{}{"uk", "eu"}},
+						{Field: "Name", Operator: "contains", Param: "name"},
+					},
+					Skip:  5,
+					Limit: 10,
+				},
+			}, map[string]interface{}{"provider": "azure", "name": "VM", "unused": true})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "match (Service:Service) where Service.Cost >= $whereValue0 and Service.Provider = $provider "+
+			"and Service.Region IN $whereValue2 and Service.Name CONTAINS $name return Service "+
+			"order by Service.Cost desc,Service.Name desc skip 5 limit 10", cypher)
+		assert.Equal(t, map[string]interface{}{"whereValue0": 10, "provider": "azure",
+			"whereValue2": []interface{}{"uk", "eu"}, "name": "VM"}, params)
+	})
+	t.Run("WhereParentClassAlias", func(t *testing.T) {
+		cypher, params, err := getCypherForSection("Provider", []string{"azure", "aws"},
+			TemplateSection{
+				SectionClass: ClassFieldSelector{
+					Class:        "Service",
+					ClassAlias:   "s",
+					Relationship: "HOSTED_BY",
+					Where:        []WherePredicate{{Field: "Cost", Operator: "!=", Value: 0}},
+				},
+				AggregateClasses: []ClassFieldSelector{{Class: "Category", Relationship: "TYPE_OF"}},
+			}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "match (s:Service)-[:HOSTED_BY]-(parentNode:Provider) where parentNode.ID IN $parentIDs "+
+			"and s.Cost <> $whereValue0 optional match (s:Service)-[:TYPE_OF]-(Category:Category) "+
+			"return s,parentNode,Category", cypher)
+		assert.Equal(t, map[string]interface{}{"whereValue0": 0, "parentIDs": []string{"azure", "aws"}}, params)
+	})
+	t.Run("Aggregations", func(t *testing.T) {
+		cypher, _, err := getCypherForSection("", nil,
+			TemplateSection{
+				SectionClass: ClassFieldSelector{Class: "Service", OrderFields: []string{"Name"}},
+				AggregateClasses: []ClassFieldSelector{
+					{Class: "Category", Relationship: "TYPE_OF", Aggregations: []Aggregation{
+						{Function: "collect", Field: "Name", Distinct: true, As: "Names"},
+						{Function: "count"},
+					}},
+					{Class: "Provider", Relationship: "PROVIDED_BY"},
+					{Class: "Capability", Relationship: "HAS", Aggregations: []Aggregation{
+						{Function: "SUM", Field: "Cost"},
+					}},
+				},
+			}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "match (Service:Service) "+
+			"optional match (Service:Service)-[:TYPE_OF]-(Category:Category) "+
+			"with Service,collect(distinct Category.Name) as `Category.Names`,count(Category) as `Category.count` "+
+			"optional match (Service:Service)-[:PROVIDED_BY]-(Provider:Provider) "+
+			"optional match (Service:Service)-[:HAS]-(Capability:Capability) "+
+			"with Service,`Category.Names`,`Category.count`,Provider,sum(Capability.Cost) as `Capability.sumCost` "+
+			"return Service,`Category.Names`,`Category.count`,Provider,`Capability.sumCost` "+
+			"order by Service.Name", cypher)
+	})
+	t.Run("AggregateClassAliases", func(t *testing.T) {
+		cypher, _, err := getCypherForSection("", nil,
+			TemplateSection{
+				SectionClass: ClassFieldSelector{Class: "Service", ClassAlias: "s", OrderFields: []string{"Name"}},
+				AggregateClasses: []ClassFieldSelector{
+					{Class: "Service", ClassAlias: "dependency", Relationship: "DEPENDS_ON", RelationshipFrom: true},
+					{Class: "Service", ClassAlias: "dependant", Relationship: "DEPENDS_ON", RelationshipTo: true,
+						Aggregations: []Aggregation{{Function: "collect", Field: "Name"}}},
+				},
+			}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "match (s:Service) "+
+			"optional match (s:Service)-[:DEPENDS_ON]->(dependency:Service) "+
+			"optional match (s:Service)<-[:DEPENDS_ON]-(dependant:Service) "+
+			"with s,dependency,collect(dependant.Name) as `dependant.collectName` "+
+			"return s,dependency,`dependant.collectName` order by s.Name", cypher)
+	})
+	t.Run("DuplicateVariables", func(t *testing.T) {
+		for _, aggregateClasses := range [][]ClassFieldSelector{
+			{{Class: "Service", Relationship: "DEPENDS_ON"}},
+			{{Class: "Category", Relationship: "TYPE_OF"}, {Class: "Category", Relationship: "PART_OF"}},
+			{{Class: "Category", ClassAlias: "parentNode", Relationship: "TYPE_OF"}},
+		} {
+			_, _, err := getCypherForSection("", nil, TemplateSection{
+				SectionClass:     ClassFieldSelector{Class: "Service"},
+				AggregateClasses: aggregateClasses,
+			}, nil)
+			assert.ErrorContains(t, err, "more than once in a section; give each a different ClassAlias")
+		}
+	})
+	t.Run("AggregateWhereAndOrder", func(t *testing.T) {
+		cypher, cypherParams, err := getCypherForSection("", nil, TemplateSection{
+			SectionClass: ClassFieldSelector{Class: "Service", OrderFields: []string{"Name"},
+				Where: []WherePredicate{{Field: "Cost", Operator: ">", Value: 10}}},
+			AggregateClasses: []ClassFieldSelector{
+				{Class: "Category", Relationship: "TYPE_OF", OrderFields: []string{"Name"}, Descending: true,
+					Where: []WherePredicate{{Field: "Name", Operator: "!=", Param: "category"}}},
+				{Class: "Capability", Relationship: "HAS", Where: []WherePredicate{{Field: "Level", Value: 1}},
+					Aggregations: []Aggregation{{Function: "count"}}},
+			},
+		}, map[string]interface{}{"category": "compute"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "match (Service:Service) where Service.Cost > $whereValue0 "+
+			"optional match (Service:Service)-[:TYPE_OF]-(Category:Category) where Category.Name <> $category "+
+			"optional match (Service:Service)-[:HAS]-(Capability:Capability) where Capability.Level = $whereValue2 "+
+			"with Service,Category,count(Capability) as `Capability.count` "+
+			"return Service,Category,`Capability.count` order by Service.Name,Category.Name desc", cypher)
+		assert.Equal(t, map[string]interface{}{"whereValue0": 10, "category": "compute", "whereValue2": 1},
+			cypherParams)
+	})
+	t.Run("AggregateSelectionErrors", func(t *testing.T) {
+		for _, tc := range []struct {
+			aggregateClass ClassFieldSelector
+			err            string
+		}{
+			{ClassFieldSelector{Class: "Category", Skip: 1},
+				"aggregate class [Category] cannot have Skip or Limit; these apply only to the SectionClass"},
+			{ClassFieldSelector{Class: "Category", Limit: 1},
+				"aggregate class [Category] cannot have Skip or Limit; these apply only to the SectionClass"},
+			{ClassFieldSelector{Class: "Category", OrderFields: []string{"Name"},
+				Aggregations: []Aggregation{{Function: "count"}}},
+				"aggregate class [Category] cannot have OrderFields as well as Aggregations"},
+		} {
+			tc.aggregateClass.Relationship = "TYPE_OF"
+			_, _, err := getCypherForSection("", nil, TemplateSection{
+				SectionClass:     ClassFieldSelector{Class: "Service"},
+				AggregateClasses: []ClassFieldSelector{tc.aggregateClass},
+			}, nil)
+			assert.EqualError(t, err, tc.err)
+		}
+	})
+	t.Run("RepeatedRowsPagedAfterQuery", func(t *testing.T) {
+		cypher, _, err := getCypherForSection("", nil, TemplateSection{
+			SectionClass:     ClassFieldSelector{Class: "Service", Skip: 1, Limit: 2},
+			AggregateClasses: []ClassFieldSelector{{Class: "Category", Relationship: "TYPE_OF"}},
+		}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "match (Service:Service) optional match (Service:Service)-[:TYPE_OF]-(Category:Category) "+
+			"return Service,Category", cypher)
+	})
+	t.Run("AggregationErrors", func(t *testing.T) {
+		for _, tc := range []struct {
+			aggregation Aggregation
+			err         string
+		}{
+			{Aggregation{Function: "median", Field: "Cost"},
+				"invalid aggregation function [median] for class [Category]; must be one of count, collect, min, max, sum or avg"},
+			{Aggregation{Function: "collect"}, "aggregation function [collect] for class [Category] requires a Field"},
+		} {
+			_, _, err := getCypherForSection("", nil, TemplateSection{
+				SectionClass: ClassFieldSelector{Class: "Service"},
+				AggregateClasses: []ClassFieldSelector{{Class: "Category", Relationship: "TYPE_OF",
+					Aggregations: []Aggregation{tc.aggregation}}},
+			}, nil)
+			assert.EqualError(t, err, tc.err)
+		}
+	})
+	t.Run("WhereErrors", func(t *testing.T) {
+		for _, tc := range []struct {
+			where []WherePredicate
+			err   string
+		}{
+			{[]WherePredicate{{Field: "Cost", Operator: "~"}},
+				"invalid operator [~] for field [Cost]; must be one of =, !=, <, <=, >, >=, in or contains"},
+			{[]WherePredicate{{Operator: "="}}, "a Where predicate on class [Service] has no Field"},
+			{[]WherePredicate{{Field: "Provider", Param: "provider"}},
+				"parameter [provider] used by class [Service] has not been provided"},
+			{[]WherePredicate{{Field: "Provider", Param: "provider", Value: "azure"}},
+				"the Where predicate on field [Provider] must have either a Value or a Param, not both"},
+			{[]WherePredicate{{Field: "Provider"}}, "the Where predicate on field [Provider] must have a Value or a Param"},
+			{[]WherePredicate{{Field: "Provider", Operator: "in", Value: "azure"}},
+				"the Where predicate on field [Provider] with operator in must have a list Value"},
+			{[]WherePredicate{{Field: "Name", Value: "vm"}, {Field: "ID", Param: "whereValue1"}},
+				"parameter [whereValue1] is reserved for the queries generated for each section; use a different name"},
+			{[]WherePredicate{{Field: "ID", Param: "parentIDs"}},
+				"parameter [parentIDs] is reserved for the queries generated for each section; use a different name"},
+		} {
+			_, _, err := getCypherForSection("", nil, TemplateSection{
+				SectionClass: ClassFieldSelector{Class: "Service", Where: tc.where}}, nil)
+			assert.EqualError(t, err, tc.err)
+		}
+	})
+}
+
+func Test_validateParams(t *testing.T) {
+	assert.Nil(t, validateParams(nil))
+	assert.Nil(t, validateParams(map[string]interface{}{"provider": "azure", "whereValue": "x", "parentID": "y"}))
+	assert.EqualError(t, validateParams(map[string]interface{}{"provider": "azure", "whereValue0": "x"}),
+		"parameter [whereValue0] is reserved for the queries generated for each section; use a different name")
+	assert.EqualError(t, validateParams(map[string]interface{}{"parentIDs": "x"}),
+		"parameter [parentIDs] is reserved for the queries generated for each section; use a different name")
+}
+
+func Test_loadSection(t *testing.T) {
+	t.Run("SingleQueryPerSection", func(t *testing.T) {
+		g := newSectionGraph(3, 2)
+		g.services["provider-1"] = nil
+
+		definitions, err := loadSection(g.run, providerServicesSection(0, 0), "", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, g.queries)
+
+		providers := definitions[""]
+		assert.Len(t, providers, 3)
+		assert.Equal(t, SectionDefinition{
+			Class:  "Provider",
+			ID:     "provider-0",
+			Fields: map[string]interface{}{"Provider.Name": "provider-0"},
+			CompositeSectionDefinitions: map[string][]SectionDefinition{
+				"PROVIDED_BY": {
+					{
+						Class:                       "Service",
+						ID:                          "provider-0-service-0",
+						Fields:                      map[string]interface{}{"Service.Name": "provider-0-service-0"},
+						CompositeSectionDefinitions: map[string][]SectionDefinition{},
+					},
+					{
+						Class:                       "Service",
+						ID:                          "provider-0-service-1",
+						Fields:                      map[string]interface{}{"Service.Name": "provider-0-service-1"},
+						CompositeSectionDefinitions: map[string][]SectionDefinition{},
+					},
+				},
+			},
+		}, providers[0])
+		assert.Equal(t, map[string][]SectionDefinition{"PROVIDED_BY": nil}, providers[1].CompositeSectionDefinitions)
+		assert.Len(t, providers[2].CompositeSectionDefinitions["PROVIDED_BY"], 2)
+	})
+	t.Run("PagedForEachParent", func(t *testing.T) {
+		g := newSectionGraph(2, 4)
+
+		definitions, err := loadSection(g.run, providerServicesSection(1, 2), "", nil, nil)
+		assert.Nil(t, err)
+		for _, provider := range definitions[""] {
+			services := provider.CompositeSectionDefinitions["PROVIDED_BY"]
+			assert.Len(t, services, 2)
+			assert.Equal(t, provider.ID+"-service-1", services[0].ID)
+			assert.Equal(t, provider.ID+"-service-2", services[1].ID)
+		}
+	})
+	t.Run("NoDefinitions", func(t *testing.T) {
+		g := newSectionGraph(0, 0)
+
+		definitions, err := loadSection(g.run, providerServicesSection(0, 0), "", nil, nil)
+		assert.Nil(t, err)
+		assert.Empty(t, definitions[""])
+		assert.Equal(t, 1, g.queries)
+	})
+}
+
+func Test_pageDefinitions(t *testing.T) {
+	var definitions []SectionDefinition
+	for _, id := range []string{"a", "a", "b", "c", "c", "d"} {
+		definitions = append(definitions, SectionDefinition{ID: id})
+	}
+	ids := func(definitions []SectionDefinition) (ids []string) {
+		for _, definition := range definitions {
+			ids = append(ids, definition.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"b", "c", "c"}, ids(pageDefinitions(definitions, ClassFieldSelector{Skip: 1, Limit: 2})))
+	assert.Equal(t, []string{"a", "a"}, ids(pageDefinitions(definitions, ClassFieldSelector{Limit: 1})))
+	assert.Equal(t, []string{"c", "c", "d"}, ids(pageDefinitions(definitions, ClassFieldSelector{Skip: 2})))
+	assert.Empty(t, pageDefinitions(definitions, ClassFieldSelector{Skip: 4}))
+	assert.Len(t, pageDefinitions(definitions, ClassFieldSelector{}), 6)
+}
+
+func Test_getSectionDefinition(t *testing.T) {
+	section := TemplateSection{
+		SectionClass: ClassFieldSelector{Class: "Service", ClassAlias: "s", Fields: []string{"Name"}},
+		AggregateClasses: []ClassFieldSelector{
+			{Class: "Service", ClassAlias: "dependency", Fields: []string{"Name"}, Relationship: "DEPENDS_ON"},
+			{Class: "Category", Fields: []string{"Name"}, Relationship: "TYPE_OF"},
+		},
+	}
+
+	parentID, definition := getSectionDefinition(section, &neo4j.Record{
+		Keys: []string{"s", parentVariable, "dependency", "Category", "Category.count"},
+		Values: []interface{}{
+			neo4j.Node{Labels: []string{"Resource", "Service"}, Props: map[string]interface{}{"ID": "vm", "Name": "VM"}},
+			neo4j.Node{Labels: []string{"Provider"}, Props: map[string]interface{}{"ID": "azure"}},
+			neo4j.Node{Labels: []string{"Service"}, Props: map[string]interface{}{"ID": "disk", "Name": "Disk"}},
+			nil,
+			int64(2),
+		},
+	})
+
+	assert.Equal(t, "azure", parentID)
+	assert.Equal(t, SectionDefinition{
+		Class: "Service",
+		ID:    "vm",
+		Fields: map[string]interface{}{
+			"s.Name":          "VM",
+			"dependency.Name": "Disk",
+			"Category.count":  int64(2),
+		},
+		CompositeSectionDefinitions: map[string][]SectionDefinition{},
+	}, definition)
+}
+
+func Test_loadSection_recursive(t *testing.T) {
+	newGraph := func() *hierarchyGraph {
+		return &hierarchyGraph{roots: []string{"a"}, children: map[string][]string{
+			"a": {"b", "c"},
+			"b": {"d"},
+			"c": {"d"},
+			"d": {"a"},
+		}}
+	}
+	section := TemplateSection{
+		SectionClass: ClassFieldSelector{Class: "Capability", Relationship: "PARENT_OF", RelationshipTo: true},
+		Recursive:    true,
+	}
+
+	t.Run("Unlimited", func(t *testing.T) {
+		g := newGraph()
+		definitions, err := loadSection(g.run, section, "", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a(b(d()),c(d()))", hierarchy(definitions[""], "PARENT_OF"))
+		assert.Equal(t, 4, g.queries)
+	})
+	t.Run("MaxDepth", func(t *testing.T) {
+		g := newGraph()
+		section.MaxDepth = 2
+		definitions, err := loadSection(g.run, section, "", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a(b,c)", hierarchy(definitions[""], "PARENT_OF"))
+		assert.Equal(t, 2, g.queries)
+	})
+	t.Run("WhereSkipLimitAtTop", func(t *testing.T) {
+		g := newGraph()
+		definitions, err := loadSection(g.run, TemplateSection{
+			SectionClass: ClassFieldSelector{Class: "Capability", Relationship: "PARENT_OF", RelationshipTo: true,
+				Where: []WherePredicate{{Field: "Level", Value: 1}}, Limit: 1},
+			Recursive: true,
+			MaxDepth:  3,
+		}, "", nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a(b(d),c(d))", hierarchy(definitions[""], "PARENT_OF"))
+		assert.Equal(t, []string{
+			"match (Capability:Capability) where Capability.Level = $whereValue0 return Capability limit 1",
+			"match (Capability:Capability)-[:PARENT_OF]->(parentNode:Capability) where parentNode.ID IN $parentIDs " +
+				"return Capability,parentNode",
+			"match (Capability:Capability)-[:PARENT_OF]->(parentNode:Capability) where parentNode.ID IN $parentIDs " +
+				"return Capability,parentNode",
+		}, g.cypher)
+	})
+	t.Run("RecursiveRelationship", func(t *testing.T) {
+		g := newGraph()
+		g.children["domain"] = []string{"a"}
+		definitions, err := loadSection(g.run, TemplateSection{
+			SectionClass: ClassFieldSelector{Class: "Capability", Relationship: "PART_OF",
+				RelationshipTo: true},
+			Recursive:             true,
+			MaxDepth:              2,
+			RecursiveRelationship: "PARENT_OF",
+		}, "Domain", []string{"domain"}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "a(b,c)", hierarchy(definitions["domain"], "PARENT_OF"))
+		assert.Equal(t, []string{
+			"match (Capability:Capability)-[:PART_OF]->(parentNode:Domain) where parentNode.ID IN $parentIDs " +
+				"return Capability,parentNode",
+			"match (Capability:Capability)-[:PARENT_OF]->(parentNode:Capability) where parentNode.ID IN $parentIDs " +
+				"return Capability,parentNode",
+		}, g.cypher)
+	})
+	t.Run("MissingRelationship", func(t *testing.T) {
+		_, err := loadSection(newGraph().run, TemplateSection{
+			SectionClass: ClassFieldSelector{Class: "Capability", RelationshipTo: true},
+			Recursive:    true,
+		}, "", nil, nil)
+		assert.EqualError(t, err,
+			"recursive section for class [Capability] requires a Relationship or RecursiveRelationship")
+	})
+	t.Run("MissingDirection", func(t *testing.T) {
+		_, err := loadSection(newGraph().run, TemplateSection{
+			SectionClass: ClassFieldSelector{Class: "Capability", Relationship: "PARENT_OF"},
+			Recursive:    true,
+		}, "", nil, nil)
+		assert.EqualError(t, err,
+			"recursive section for class [Capability] requires RelationshipFrom or RelationshipTo")
+	})
+}
+
+func Benchmark_loadSection(b *testing.B) {
+	section := providerServicesSection(0, 0)
+	g := newSectionGraph(500, 20)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := loadSection(g.run, section, "", nil, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(g.queries)/float64(b.N), "queries/op")
 }
 
 func Test_loadTemplateConf(t *testing.T) {
@@ -220,6 +738,24 @@ func Test_loadTemplateConf(t *testing.T) {
 			}},
 		}, tc)
 	})
+	t.Run("Where_Valid", func(t *testing.T) {
+		tc, err := loadTemplateConf("_test/loadTemplateConf/TemplateSection_where_valid.yaml")
+		assert.Nil(t, err)
+		assert.Equal(t, &TemplateSection{
+			SectionClass: ClassFieldSelector{
+				Class:  "Service",
+				Fields: []string{"Name"},
+				Where: []WherePredicate{
+					{Field: "Cost", Operator: ">=", Value: 10},
+					{Field: "Provider", Param: "provider"},
+				},
+				OrderFields: []string{"Cost"},
+				Descending:  true,
+				Skip:        5,
+				Limit:       10,
+			},
+		}, tc)
+	})
 }
 
 func Test_recurseTemplateSection(t *testing.T) {
@@ -237,7 +773,7 @@ func Test_recurseTemplateSection(t *testing.T) {
 		defer driver.Close()
 		defer session.Close()
 
-		definitions, err := recurseTemplateSection(session, *tc, nil, nil)
+		definitions, err := recurseTemplateSection(session, *tc, nil, nil, nil)
 		assert.Nil(t, err)
 		assert.Equal(t, []SectionDefinition{
 			{
@@ -310,7 +846,7 @@ func Test_recurseTemplateSection(t *testing.T) {
 		defer driver.Close()
 		defer session.Close()
 
-		definitions, err := recurseTemplateSection(session, *tc, nil, nil)
+		definitions, err := recurseTemplateSection(session, *tc, nil, nil, nil)
 		assert.Nil(t, err)
 
 		fmt.Println(definitions)
@@ -470,7 +1006,7 @@ func Test_recurseTemplateSection(t *testing.T) {
 		defer driver.Close()
 		defer session.Close()
 
-		definitions, err := recurseTemplateSection(session, *tc, nil, nil)
+		definitions, err := recurseTemplateSection(session, *tc, nil, nil, nil)
 		assert.Nil(t, err)
 
 		fmt.Println(definitions)
@@ -679,7 +1215,7 @@ func Test_recurseTemplateSection(t *testing.T) {
 		defer driver.Close()
 		defer session.Close()
 
-		definitions, err := recurseTemplateSection(session, *tc, nil, nil)
+		definitions, err := recurseTemplateSection(session, *tc, nil, nil, nil)
 		assert.Nil(t, err)
 
 		fmt.Println(definitions)
@@ -0,0 +1,489 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/nextmetaphor/yaml-graph/expression"
+	"github.com/nextmetaphor/yaml-graph/graph"
+	"github.com/yuin/goldmark"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	funcMarkdown     = "markdown"
+	funcNilToStr     = "nilToStr"
+	funcLower        = "lower"
+	funcUpper        = "upper"
+	funcTitle        = "title"
+	funcSlug         = "slug"
+	funcTruncate     = "truncate"
+	funcReplace      = "replace"
+	funcSortBy       = "sortBy"
+	funcGroupBy      = "groupBy"
+	funcUniq         = "uniq"
+	funcFirst        = "first"
+	funcLast         = "last"
+	funcJoin         = "join"
+	funcFormatNumber = "formatNumber"
+	funcFormatDate   = "formatDate"
+	funcToJSON       = "toJSON"
+	funcToYAML       = "toYAML"
+	funcDefault      = "default"
+
+	truncatedSuffix = "..."
+
+	templateKeyClass        = "Class"
+	templateKeyID           = "ID"
+	templateKeyRelationship = "Relationship"
+	templateKeyFields       = "Fields"
+
+	lookupCypher           = "match (n:`%s` {ID:$ID}) return n"
+	refsCypher             = "match (n:`%s` {ID:$ID})-[r%s]->(m) return type(r), m order by type(r), m.ID"
+	incomingCypher         = "match (n:`%s` {ID:$ID})<-[r%s]-(m) return type(r), m order by type(r), m.ID"
+	relationshipTypeCypher = ":`%s`"
+
+	errorNotAList                = "%s expects a list, not [%T]"
+	errorNotADate                = "cannot format [%v] as a date"
+	errorNotANumber              = "cannot format [%v] as a number"
+	errorGraphFunctionsDisabled  = "%s is not available without a graph database"
+	errorInvalidTemplateFunction = "invalid arguments for template function [%s]"
+)
+
+type (
+	// TemplateGroup holds the items of a list which share the same value of a field, as returned by groupBy
+	TemplateGroup struct {
+		Key   interface{}
+		Items []interface{}
+	}
+
+	// templateResolver resolves definitions, and the definitions related to them, for the graph template functions
+	templateResolver interface {
+		lookup(class, id string) (map[string]interface{}, error)
+		refs(class, id, relationship string, incoming bool) ([]map[string]interface{}, error)
+	}
+
+	// graphResolver resolves definitions from the graph database; the DefinitionFormat is optional, and determines
+	// the class of definitions which are also labelled with the classes their class extends
+	graphResolver struct {
+		session neo4j.Session
+		df      *DefinitionFormat
+	}
+)
+
+var (
+	// dateLayouts are tried in turn when formatting a date held as a string
+	dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+func fromMarkdown(inputString string) htmltemplate.HTML {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(inputString), &buf); err != nil {
+		panic(err)
+	}
+
+	return htmltemplate.HTML(buf.String())
+}
+
+func nilToStr(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// title capitalises the first letter of each word
+func title(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) || runes[i-1] == '-' || runes[i-1] == '_' {
+			runes[i] = unicode.ToTitle(r)
+		}
+	}
+	return string(runes)
+}
+
+// truncate shortens the value to at most length characters, followed by an ellipsis if it was shortened
+func truncate(length int, v interface{}) string {
+	s := []rune(expression.ToString(v))
+	if length < 0 || len(s) <= length {
+		return string(s)
+	}
+	return string(s[:length]) + truncatedSuffix
+}
+
+func replace(old, new string, v interface{}) string {
+	return strings.ReplaceAll(expression.ToString(v), old, new)
+}
+
+// templateList converts any slice into a []interface{}
+func templateList(name string, v interface{}) ([]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if l, ok := v.([]interface{}); ok {
+		return l, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf(errorNotAList, name, v)
+	}
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		l[i] = rv.Index(i).Interface()
+	}
+	return l, nil
+}
+
+// fieldValue returns the named field of a SectionDefinition, map or struct. For a SectionDefinition the name is
+// either Class, ID or a key of its Fields, such as Service.Name.
+func fieldValue(item interface{}, name string) interface{} {
+	switch t := item.(type) {
+	case SectionDefinition:
+		return fieldValue(&t, name)
+	case *SectionDefinition:
+		switch name {
+		case templateKeyClass:
+			return t.Class
+		case templateKeyID:
+			return t.ID
+		}
+		return t.Fields[name]
+	case map[string]interface{}:
+		return t[name]
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(item))
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			if v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key())); v.IsValid() {
+				return v.Interface()
+			}
+		}
+	case reflect.Struct:
+		if f := rv.FieldByName(name); f.IsValid() && f.CanInterface() {
+			return f.Interface()
+		}
+	}
+	return nil
+}
+
+// compareValues orders numbers and strings by value, and anything else by its string representation
+func compareValues(a, b interface{}) int {
+	if c, ok := expression.Compare(a, b); ok {
+		return c
+	}
+	return strings.Compare(expression.ToString(a), expression.ToString(b))
+}
+
+func sortBy(field string, v interface{}) ([]interface{}, error) {
+	l, err := templateList(funcSortBy, v)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]interface{}{}, l...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareValues(fieldValue(sorted[i], field), fieldValue(sorted[j], field)) < 0
+	})
+	return sorted, nil
+}
+
+// groupBy groups the items by the value of the field, in the order each value is first found
+func groupBy(field string, v interface{}) ([]TemplateGroup, error) {
+	l, err := templateList(funcGroupBy, v)
+	if err != nil {
+		return nil, err
+	}
+	var groups []TemplateGroup
+	for _, item := range l {
+		key := fieldValue(item, field)
+		found := false
+		for i := range groups {
+			if expression.Equal(groups[i].Key, key) {
+				groups[i].Items = append(groups[i].Items, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, TemplateGroup{Key: key, Items: []interface{}{item}})
+		}
+	}
+	return groups, nil
+}
+
+func uniq(v interface{}) ([]interface{}, error) {
+	l, err := templateList(funcUniq, v)
+	if err != nil {
+		return nil, err
+	}
+	var result []interface{}
+	for _, item := range l {
+		found := false
+		for _, r := range result {
+			if expression.Equal(r, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func first(v interface{}) (interface{}, error) {
+	l, err := templateList(funcFirst, v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+func last(v interface{}) (interface{}, error) {
+	l, err := templateList(funcLast, v)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+func join(sep string, v interface{}) (string, error) {
+	l, err := templateList(funcJoin, v)
+	if err != nil {
+		return "", err
+	}
+	s := make([]string, len(l))
+	for i, item := range l {
+		s[i] = expression.ToString(item)
+	}
+	return strings.Join(s, sep), nil
+}
+
+// formatNumber formats the number with the given number of decimal places and commas separating thousands
+func formatNumber(decimals int, v interface{}) (string, error) {
+	var f float64
+	switch t := v.(type) {
+	case int:
+		f = float64(t)
+	case int64:
+		f = float64(t)
+	case float64:
+		f = t
+	case string:
+		var err error
+		if f, err = strconv.ParseFloat(t, 64); err != nil {
+			return "", fmt.Errorf(errorNotANumber, v)
+		}
+	default:
+		return "", fmt.Errorf(errorNotANumber, v)
+	}
+
+	s := strconv.FormatFloat(f, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if hasFraction {
+		return sign + whole + "." + fraction, nil
+	}
+	return sign + whole, nil
+}
+
+// formatDate formats a time, a graph database date or a string holding a date, using a Go time layout
+func formatDate(layout string, v interface{}) (string, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout), nil
+	case interface{ Time() time.Time }:
+		return t.Time().Format(layout), nil
+	case string:
+		for _, l := range dateLayouts {
+			if d, err := time.Parse(l, t); err == nil {
+				return d.Format(layout), nil
+			}
+		}
+	}
+	return "", fmt.Errorf(errorNotADate, v)
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func toYAML(v interface{}) (string, error) {
+	b, err := yaml.Marshal(v)
+	return strings.TrimSuffix(string(b), "\n"), err
+}
+
+// defaultValue returns the value, or the default if the value is nil, false, zero or empty
+func defaultValue(def, v interface{}) interface{} {
+	if !expression.Truthy(v) {
+		return def
+	}
+	if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.Len() == 0 {
+		return def
+	}
+	return v
+}
+
+// nodeValue returns the class, ID and fields of a graph database node
+// concreteClass returns the label of the class of a definition which is also labelled with the classes its class
+// extends, being the label with the most base classes, or the first label if there is no DefinitionFormat
+func concreteClass(df *DefinitionFormat, labels []string) string {
+	class := ""
+	for _, label := range labels {
+		if class == "" || len(df.BaseClasses(label)) > len(df.BaseClasses(class)) {
+			class = label
+		}
+	}
+	return class
+}
+
+func (r graphResolver) nodeValue(node neo4j.Node) map[string]interface{} {
+	v := map[string]interface{}{templateKeyFields: node.Props, templateKeyID: node.Props[templateKeyID]}
+	if len(node.Labels) > 0 {
+		v[templateKeyClass] = concreteClass(r.df, node.Labels)
+	}
+	return v
+}
+
+func quoteLabel(label string) string {
+	return strings.ReplaceAll(label, "`", "``")
+}
+
+func (r graphResolver) lookup(class, id string) (map[string]interface{}, error) {
+	res, err := graph.ExecuteCypher(r.session, fmt.Sprintf(lookupCypher, quoteLabel(class)),
+		map[string]interface{}{templateKeyID: id})
+	if err != nil {
+		return nil, err
+	}
+	if !res.Next() {
+		return nil, res.Err()
+	}
+	node, ok := res.Record().Values[0].(neo4j.Node)
+	if !ok {
+		return nil, nil
+	}
+	v := r.nodeValue(node)
+	if r.df == nil {
+		v[templateKeyClass] = class
+	}
+	return v, nil
+}
+
+func (r graphResolver) refs(class, id, relationship string, incoming bool) ([]map[string]interface{}, error) {
+	relationshipType := ""
+	if relationship != "" {
+		relationshipType = fmt.Sprintf(relationshipTypeCypher, quoteLabel(relationship))
+	}
+	cypher := refsCypher
+	if incoming {
+		cypher = incomingCypher
+	}
+	cypher = fmt.Sprintf(cypher, quoteLabel(class), relationshipType)
+	res, err := graph.ExecuteCypher(r.session, cypher, map[string]interface{}{templateKeyID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]interface{}{}
+	for res.Next() {
+		values := res.Record().Values
+		node, ok := values[1].(neo4j.Node)
+		if !ok {
+			continue
+		}
+		v := r.nodeValue(node)
+		v[templateKeyRelationship] = values[0]
+		result = append(result, v)
+	}
+	return result, res.Err()
+}
+
+// relatedFunc returns the graph function name, which returns the definitions related to a definition in one direction,
+// optionally restricted to a single relationship
+func relatedFunc(resolver templateResolver, name string,
+	incoming bool) func(class, id string, relationship ...string) ([]map[string]interface{}, error) {
+	return func(class, id string, relationship ...string) ([]map[string]interface{}, error) {
+		if resolver == nil {
+			return nil, fmt.Errorf(errorGraphFunctionsDisabled, name)
+		}
+		if len(relationship) > 1 {
+			return nil, fmt.Errorf(errorInvalidTemplateFunction, name)
+		}
+		return resolver.refs(class, id, strings.Join(relationship, ""), incoming)
+	}
+}
+
+// getTemplateFuncs returns the functions available to report templates; the graph functions lookup, refs and
+// incoming return an error if no resolver is provided
+func getTemplateFuncs(resolver templateResolver) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		funcMarkdown: fromMarkdown,
+		funcNilToStr: nilToStr,
+
+		funcLower:    func(v interface{}) string { return strings.ToLower(expression.ToString(v)) },
+		funcUpper:    func(v interface{}) string { return strings.ToUpper(expression.ToString(v)) },
+		funcTitle:    func(v interface{}) string { return title(expression.ToString(v)) },
+		funcSlug:     func(v interface{}) string { return expression.Slug(expression.ToString(v)) },
+		funcTruncate: truncate,
+		funcReplace:  replace,
+
+		funcSortBy:  sortBy,
+		funcGroupBy: groupBy,
+		funcUniq:    uniq,
+		funcFirst:   first,
+		funcLast:    last,
+		funcJoin:    join,
+
+		funcFormatNumber: formatNumber,
+		funcFormatDate:   formatDate,
+		funcToJSON:       toJSON,
+		funcToYAML:       toYAML,
+		funcDefault:      defaultValue,
+
+		funcLookup: func(class, id string) (map[string]interface{}, error) {
+			if resolver == nil {
+				return nil, fmt.Errorf(errorGraphFunctionsDisabled, funcLookup)
+			}
+			return resolver.lookup(class, id)
+		},
+		// as with computed fields, refs follows the relationships of a definition and incoming those to it
+		funcRefs:     relatedFunc(resolver, funcRefs, false),
+		funcIncoming: relatedFunc(resolver, funcIncoming, true),
+	}
+}
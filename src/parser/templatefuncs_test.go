@@ -0,0 +1,143 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testResolver holds definitions keyed by class and ID; those with templateKeyIncoming set are returned by incoming
+// rather than refs
+type testResolver map[string]map[string]interface{}
+
+const templateKeyIncoming = "Incoming"
+
+func (r testResolver) lookup(class, id string) (map[string]interface{}, error) {
+	return r[class+"/"+id], nil
+}
+
+func (r testResolver) refs(class, id, relationship string, incoming bool) ([]map[string]interface{}, error) {
+	if class == "Missing" {
+		return nil, fmt.Errorf("cannot find [%s]", id)
+	}
+	result := []map[string]interface{}{}
+	for _, v := range r {
+		if v[templateKeyIncoming] == incoming && (relationship == "" || v[templateKeyRelationship] == relationship) {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func executeTemplate(t *testing.T, resolver templateResolver, source string, data interface{}) (string, error) {
+	tmpl, err := texttemplate.New("test").Funcs(getTemplateFuncs(resolver)).Parse(source)
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	return buf.String(), err
+}
+
+func Test_templateFuncs(t *testing.T) {
+	definitions := []SectionDefinition{
+		{Class: "Service", ID: "vm", Fields: map[string]interface{}{"Service.Name": "Virtual Machine",
+			"Service.Category": "compute", "Service.Cost": int64(1500)}},
+		{Class: "Service", ID: "s3", Fields: map[string]interface{}{"Service.Name": "Object Storage",
+			"Service.Category": "storage", "Service.Cost": 2.5}},
+		{Class: "Service", ID: "fn", Fields: map[string]interface{}{"Service.Name": "Functions",
+			"Service.Category": "compute", "Service.Cost": 10}},
+	}
+	resolver := testResolver{"Provider/azure": {templateKeyClass: "Provider", templateKeyID: "azure",
+		templateKeyRelationship: "HOSTED_BY", templateKeyFields: map[string]interface{}{"Name": "Azure"},
+		templateKeyIncoming: false},
+		"Team/ops": {templateKeyClass: "Team", templateKeyID: "ops", templateKeyRelationship: "OPERATES",
+			templateKeyIncoming: true}}
+
+	for _, tc := range []struct {
+		name     string
+		source   string
+		data     interface{}
+		expected string
+	}{
+		{"lower", `{{ lower "Virtual Machine" }}`, nil, "virtual machine"},
+		{"upper", `{{ upper "vm" }}`, nil, "VM"},
+		{"title", `{{ title "virtual machine-scale_set" }}`, nil, "Virtual Machine-Scale_Set"},
+		{"slug", `{{ slug "Virtual Machine (Spot)" }}`, nil, "virtual-machine-spot"},
+		{"truncate", `{{ "Virtual Machine" | truncate 7 }}|{{ "VM" | truncate 7 }}`, nil, "Virtual...|VM"},
+		{"replace", `{{ "a-b-c" | replace "-" "." }}`, nil, "a.b.c"},
+		{"sortBy", `{{ range sortBy "Service.Name" . }}{{ .ID }} {{ end }}`, definitions, "fn s3 vm "},
+		{"sortByNumber", `{{ range sortBy "Service.Cost" . }}{{ .ID }} {{ end }}`, definitions, "s3 fn vm "},
+		{"sortByID", `{{ range sortBy "ID" . }}{{ .ID }} {{ end }}`, definitions, "fn s3 vm "},
+		{"groupBy", `{{ range groupBy "Service.Category" . }}{{ .Key }}:{{ len .Items }} {{ end }}`, definitions,
+			"compute:2 storage:1 "},
+		{"uniq", `{{ uniq . | join "," }}`, []interface{}{"a", "b", "a", int64(1), 1}, "a,b,1"},
+		{"first", `{{ (first .).ID }}`, definitions, "vm"},
+		{"last", `{{ (last .).ID }}`, definitions, "fn"},
+		{"join", `{{ join ", " . }}`, []string{"a", "b"}, "a, b"},
+		{"formatNumber", `{{ formatNumber 2 1234567.891 }} {{ formatNumber 0 -1234 }} {{ formatNumber 1 "12.34" }}`,
+			nil, "1,234,567.89 -1,234 12.3"},
+		{"formatDate", `{{ formatDate "02 Jan 2006" "2023-04-05" }} {{ formatDate "2006" . }}`,
+			time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "05 Apr 2023 2020"},
+		{"toJSON", `{{ toJSON . }}`, map[string]interface{}{"b": 1, "a": []string{"x"}}, `{"a":["x"],"b":1}`},
+		{"toYAML", `{{ toYAML . }}`, map[string]interface{}{"a": []string{"x"}}, "a:\n    - x"},
+		{"default", `{{ default "none" .a }} {{ default "none" .b }} {{ default "none" .c }}`,
+			map[string]interface{}{"a": "set", "c": []string{}}, "set none none"},
+		{"nilToStr", `[{{ nilToStr .a }}]`, map[string]interface{}{"a": nil}, "[]"},
+		{"lookup", `{{ (lookup "Provider" "azure").Fields.Name }}`, nil, "Azure"},
+		{"refs", `{{ range refs "Service" "vm" "HOSTED_BY" }}{{ .Relationship }} {{ .Class }}/{{ .ID }}{{ end }}`,
+			nil, "HOSTED_BY Provider/azure"},
+		{"refsRelationship", `{{ len (refs "Service" "vm" "TYPE_OF") }}`, nil, "0"},
+		{"incoming", `{{ range incoming "Service" "vm" }}{{ .Relationship }} {{ .Class }}/{{ .ID }}{{ end }}`,
+			nil, "OPERATES Team/ops"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := executeTemplate(t, resolver, tc.source, tc.data)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, output)
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, source := range []string{
+			`{{ sortBy "Name" 1 }}`,
+			`{{ formatNumber 2 "abc" }}`,
+			`{{ formatDate "2006" "yesterday" }}`,
+			`{{ refs "Missing" "x" }}`,
+			`{{ refs "Service" "vm" "A" "B" }}`,
+			`{{ incoming "Service" "vm" "A" "B" }}`,
+		} {
+			_, err := executeTemplate(t, resolver, source, nil)
+			assert.NotNil(t, err, source)
+		}
+
+		_, err := executeTemplate(t, nil, `{{ lookup "Provider" "azure" }}`, nil)
+		assert.ErrorContains(t, err, "lookup is not available without a graph database")
+	})
+}
+
+func Test_concreteClass(t *testing.T) {
+	df := getInheritanceFormat()
+	assert.Equal(t, "Service", concreteClass(df, []string{"Named", "Service", "Resource"}))
+	assert.Equal(t, "Provider", concreteClass(df, []string{"Provider", "Named"}))
+	assert.Equal(t, "Named", concreteClass(nil, []string{"Named", "Provider"}))
+	assert.Equal(t, "", concreteClass(df, nil))
+}
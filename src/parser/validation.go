@@ -0,0 +1,229 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"fmt"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// SeverityError indicates a finding which causes validation to fail
+	SeverityError Severity = "error"
+	// SeverityWarning indicates a finding which is reported but does not cause validation to fail, unless the
+	// maximum number of warnings is exceeded
+	SeverityWarning Severity = "warning"
+	// SeverityInfo indicates a finding which is reported for information only
+	SeverityInfo Severity = "info"
+	// SeverityOff disables a rule entirely
+	SeverityOff Severity = "off"
+
+	// RuleAdditionalField is reported for fields which are neither mandatory, optional nor computed
+	RuleAdditionalField = "additional-field"
+	// RuleComputedFieldDefined is reported for computed fields which are also defined, so are never computed
+	RuleComputedFieldDefined = "computed-field-defined"
+	// RuleMandatoryFieldMissing is reported for mandatory fields which are missing or empty
+	RuleMandatoryFieldMissing = "mandatory-field-missing"
+	// RuleMandatoryFieldNotString is reported for mandatory fields which are not strings
+	RuleMandatoryFieldNotString = "mandatory-field-not-string"
+	// RuleUnknownClass is reported for references to a class which has no definitions
+	RuleUnknownClass = "unknown-class"
+	// RuleUnknownDefinition is reported for references to a definition which does not exist
+	RuleUnknownDefinition = "unknown-definition"
+	// RuleAbstractClass is reported for definitions of an abstract class
+	RuleAbstractClass = "abstract-class"
+	// RuleInvalidFormat is reported for errors in the definition format itself, e.g. invalid rule expressions
+	RuleInvalidFormat = "invalid-format"
+	// RuleCycle is reported for each cycle found by the Cycles graph check
+	RuleCycle = "cycle"
+	// RuleOrphan is reported for each definition found by the Orphans graph check
+	RuleOrphan = "orphan"
+	// RuleUnreachable is reported for each definition found by the Unreachable graph check
+	RuleUnreachable = "unreachable"
+	// RuleDuplicateEdge is reported for each relationship found by the DuplicateEdges graph check
+	RuleDuplicateEdge = "duplicate-edge"
+
+	logFinding                   = "[%s] %s"
+	logDebugFindingSuppressed    = "[%s] suppressed for definition ID [%s] for class [%s]"
+	logWarnInvalidSeverity       = "invalid severity [%s] for rule [%s]"
+	errorDefinitionWarningsFound = "there were %d warning(s) found in the definition files"
+)
+
+type (
+	// Severity indicates how a validation finding should be treated
+	Severity string
+
+	// Finding is a single issue reported during validation
+	Finding struct {
+		// RuleID identifies the rule which reported the finding
+		RuleID   string
+		Severity Severity
+
+		// Class and ID identify the definition the finding relates to; both are empty for findings relating to
+		// the definition format
+		Class string
+		ID    string
+
+		Message string
+	}
+
+	// ValidationResult holds all of the findings reported during validation
+	ValidationResult struct {
+		Findings []Finding
+	}
+
+	// validator reports findings, applying any severity overrides and suppressions
+	validator struct {
+		d      Dictionary
+		df     *DefinitionFormat
+		result *ValidationResult
+	}
+)
+
+func (s Severity) valid() bool {
+	switch s {
+	case SeverityError, SeverityWarning, SeverityInfo, SeverityOff, "":
+		return true
+	}
+	return false
+}
+
+// Count returns the number of findings with the severity provided
+func (r *ValidationResult) Count(severity Severity) (count int) {
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Errors returns the number of error findings
+func (r *ValidationResult) Errors() int {
+	return r.Count(SeverityError)
+}
+
+// Warnings returns the number of warning findings
+func (r *ValidationResult) Warnings() int {
+	return r.Count(SeverityWarning)
+}
+
+// Err returns an error if there are any error findings, or more warning findings than maxWarnings; a negative
+// maxWarnings allows any number of warnings
+func (r *ValidationResult) Err(maxWarnings int) error {
+	if errorsFound := r.Errors(); errorsFound > 0 {
+		log.Error().Msg(fmt.Sprintf(errorDefinitionErrorsFound, errorsFound))
+		return fmt.Errorf(errorDefinitionErrorsFound, errorsFound)
+	}
+	if warningsFound := r.Warnings(); maxWarnings >= 0 && warningsFound > maxWarnings {
+		log.Error().Msg(fmt.Sprintf(errorDefinitionWarningsFound, warningsFound))
+		return fmt.Errorf(errorDefinitionWarningsFound, warningsFound)
+	}
+	return nil
+}
+
+func newValidator(d Dictionary, df *DefinitionFormat) *validator {
+	return &validator{d: d, df: df, result: &ValidationResult{}}
+}
+
+// severity returns the severity of the rule, taking into account any override in the definition format
+func (v *validator) severity(ruleID string, defaultSeverity Severity) Severity {
+	severity := defaultSeverity
+	if v.df != nil {
+		if override, ok := v.df.Severities[ruleID]; ok {
+			severity = override
+		}
+	}
+	if severity == "" {
+		severity = SeverityError
+	}
+	return severity
+}
+
+// suppressed indicates whether the rule has been suppressed by a comment on any of the definitions
+func (v *validator) suppressed(ruleID string, keys []DefinitionKey) bool {
+	for _, key := range keys {
+		if v.d[key.Class] == nil || v.d[key.Class][key.ID] == nil {
+			continue
+		}
+		for _, s := range v.d[key.Class][key.ID].Suppressions {
+			if s == ruleID || s == definition.SuppressAll {
+				log.Debug().Msgf(logDebugFindingSuppressed, ruleID, key.ID, key.Class)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// report records a finding against the first of the definitions provided; the finding is ignored if the rule is
+// switched off or has been suppressed by any of the definitions
+func (v *validator) report(ruleID string, defaultSeverity Severity, msg string, keys ...DefinitionKey) {
+	severity := v.severity(ruleID, defaultSeverity)
+	if severity == SeverityOff || v.suppressed(ruleID, keys) {
+		return
+	}
+
+	finding := Finding{RuleID: ruleID, Severity: severity, Message: msg}
+	if len(keys) > 0 {
+		finding.Class, finding.ID = keys[0].Class, keys[0].ID
+	}
+
+	switch severity {
+	case SeverityWarning:
+		log.Warn().Msgf(logFinding, ruleID, msg)
+	case SeverityInfo:
+		log.Info().Msgf(logFinding, ruleID, msg)
+	default:
+		log.Error().Msgf(logFinding, ruleID, msg)
+	}
+
+	v.result.Findings = append(v.result.Findings, finding)
+}
+
+// resolveReference indicates whether the class of the reference has any definitions, and whether the referenced
+// definition exists; definitions of any subclasses of the referenced class are also considered
+func (v *validator) resolveReference(ref definition.Reference) (classFound, definitionFound bool) {
+	if _, found := v.d.Resolve(v.df, DefinitionKey{Class: ref.Class, ID: ref.ID}); found {
+		return true, true
+	}
+	for _, class := range append([]string{ref.Class}, v.df.SubClasses(ref.Class)...) {
+		if v.d[class] != nil {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// validateSeverities checks that each of the severity overrides in the definition format is valid
+func (v *validator) validateSeverities() {
+	if v.df == nil {
+		return
+	}
+	for ruleID, severity := range v.df.Severities {
+		if !severity.valid() {
+			v.result.Findings = append(v.result.Findings, Finding{
+				RuleID:   RuleInvalidFormat,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf(logWarnInvalidSeverity, severity, ruleID),
+			})
+			log.Error().Msgf(logWarnInvalidSeverity, severity, ruleID)
+		}
+	}
+}
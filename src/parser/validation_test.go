@@ -0,0 +1,106 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getValidationFormat(severities map[string]Severity) *DefinitionFormat {
+	return &DefinitionFormat{
+		ClassFormat: map[string]*ClassDefinitionFormat{
+			"Provider": {MandatoryFields: map[string]ClassField{"Name": {}}},
+		},
+		Severities: severities,
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	t.Run("DefaultSeverities", func(t *testing.T) {
+		result := Validate(LoadDictionary([]string{"_test/validate"}, "yaml"), getValidationFormat(nil))
+
+		// aws suppresses additional-field and gcp suppresses everything
+		assert.Equal(t, []Finding{{
+			RuleID:   RuleAdditionalField,
+			Severity: SeverityError,
+			Class:    "Provider",
+			ID:       "azure",
+			Message:  "field [Extra] is not a valid field in definition ID [azure] for class [Provider]",
+		}}, result.Findings)
+		assert.NotNil(t, result.Err(-1))
+	})
+
+	t.Run("WarningOverride", func(t *testing.T) {
+		result := Validate(LoadDictionary([]string{"_test/validate"}, "yaml"),
+			getValidationFormat(map[string]Severity{RuleAdditionalField: SeverityWarning}))
+
+		assert.Equal(t, 0, result.Errors())
+		assert.Equal(t, 1, result.Warnings())
+		assert.Nil(t, result.Err(-1))
+		assert.Nil(t, result.Err(1))
+		assert.NotNil(t, result.Err(0))
+	})
+
+	t.Run("OffOverride", func(t *testing.T) {
+		result := Validate(LoadDictionary([]string{"_test/validate"}, "yaml"),
+			getValidationFormat(map[string]Severity{RuleAdditionalField: SeverityOff}))
+
+		assert.Empty(t, result.Findings)
+		assert.Nil(t, result.Err(0))
+	})
+
+	t.Run("InvalidOverride", func(t *testing.T) {
+		result := Validate(LoadDictionary([]string{"_test/validate"}, "yaml"),
+			getValidationFormat(map[string]Severity{RuleAdditionalField: "fatal"}))
+
+		assert.Equal(t, RuleInvalidFormat, result.Findings[0].RuleID)
+		assert.NotNil(t, result.Err(-1))
+	})
+
+	t.Run("ComputedFieldDefined", func(t *testing.T) {
+		d := LoadDictionary([]string{"_test/validate"}, "yaml")
+		df := getValidationFormat(map[string]Severity{RuleAdditionalField: SeverityOff})
+		df.ClassFormat["Provider"].ComputedFields = map[string]ComputedField{
+			"Extra": {Expression: `lower(Name)`},
+			"Slug":  {Expression: `slug(Name)`},
+		}
+		assert.Nil(t, ComputeFields(d, df))
+
+		// the computed Slug is not reported, whereas Extra is defined so is never computed
+		assert.Equal(t, []Finding{{
+			RuleID:   RuleComputedFieldDefined,
+			Severity: SeverityError,
+			Class:    "Provider",
+			ID:       "aws",
+			Message:  "computed field [Extra] is defined in definition ID [aws] for class [Provider], so is never computed",
+		}, {
+			RuleID:   RuleComputedFieldDefined,
+			Severity: SeverityError,
+			Class:    "Provider",
+			ID:       "azure",
+			Message:  "computed field [Extra] is defined in definition ID [azure] for class [Provider], so is never computed",
+		}}, Validate(d, df).Findings)
+	})
+
+	t.Run("NilFormat", func(t *testing.T) {
+		result := Validate(LoadDictionary([]string{"_test/validate"}, "yaml"), nil)
+
+		assert.Empty(t, result.Findings)
+	})
+}
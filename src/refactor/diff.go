@@ -0,0 +1,106 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package refactor
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext = 3
+
+	diffFileHeader = "--- a/%s\n+++ b/%s\n"
+	diffHunkHeader = "@@ -%d,%d +%d,%d @@\n"
+	diffMove       = "rename from %s\nrename to %s\n"
+)
+
+// Diff returns the change as a unified diff. As edits only ever replace values within a line, the original and
+// updated content always have the same number of lines, so lines can be compared one-to-one.
+func (c FileChange) Diff() string {
+	var sb strings.Builder
+	for _, m := range c.Moves {
+		sb.WriteString(fmt.Sprintf(diffMove, m.From, m.To))
+	}
+
+	original := splitLines(c.Original)
+	updated := splitLines(c.Updated)
+	if len(original) != len(updated) {
+		// not expected, but fall back to replacing the whole file
+		sb.WriteString(fmt.Sprintf(diffFileHeader, c.Path, c.Path))
+		sb.WriteString(fmt.Sprintf(diffHunkHeader, 1, len(original), 1, len(updated)))
+		for _, l := range original {
+			sb.WriteString("-" + l + "\n")
+		}
+		for _, l := range updated {
+			sb.WriteString("+" + l + "\n")
+		}
+		return sb.String()
+	}
+
+	var changed []int
+	for i := range original {
+		if original[i] != updated[i] {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf(diffFileHeader, c.Path, c.Path))
+	for i := 0; i < len(changed); {
+		// group changed lines whose context overlaps into a single hunk
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContext {
+			j++
+		}
+		start := max(changed[i]-diffContext, 0)
+		end := min(changed[j]+diffContext+1, len(original))
+
+		sb.WriteString(fmt.Sprintf(diffHunkHeader, start+1, end-start, start+1, end-start))
+		for l := start; l < end; l++ {
+			if original[l] == updated[l] {
+				sb.WriteString(" " + original[l] + "\n")
+			} else {
+				sb.WriteString("-" + original[l] + "\n")
+				sb.WriteString("+" + updated[l] + "\n")
+			}
+		}
+		i = j + 1
+	}
+
+	return sb.String()
+}
+
+func splitLines(b []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package refactor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diff(t *testing.T) {
+	c := FileChange{
+		Path:     "service.yaml",
+		Original: []byte("1\n2\n3\n4\nold\n6\n7\n8\n9\n10\n11\n12\n13\n14\nold\n16\n"),
+		Updated:  []byte("1\n2\n3\n4\nnew\n6\n7\n8\n9\n10\n11\n12\n13\n14\nnew\n16\n"),
+		Moves:    []Move{{From: "docs/old.md", To: "docs/new.md"}},
+	}
+
+	assert.Equal(t, `rename from docs/old.md
+rename to docs/new.md
+--- a/service.yaml
++++ b/service.yaml
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-old
++new
+ 6
+ 7
+ 8
+@@ -12,5 +12,5 @@
+ 12
+ 13
+ 14
+-old
++new
+ 16
+`, c.Diff())
+
+	t.Run("MergedHunks", func(t *testing.T) {
+		c := FileChange{Path: "f", Original: []byte("a\nb\nc\nd\n"), Updated: []byte("x\nb\nc\ny\n")}
+		assert.Equal(t, "--- a/f\n+++ b/f\n@@ -1,4 +1,4 @@\n-a\n+x\n b\n c\n-d\n+y\n", c.Diff())
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		assert.Equal(t, "", FileChange{Path: "f", Original: []byte("a\n"), Updated: []byte("a\n")}.Diff())
+	})
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package refactor implements changes which span many definition files, such as renaming a definition or a class.
+// Files are located and parsed using yaml.Node, but only the affected scalars are rewritten so that comments and
+// formatting are preserved exactly.
+package refactor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	filePerm      = 0644
+	directoryPerm = 0755
+
+	errorCannotLocateScalar = "cannot locate value [%s] at line %d, column %d"
+)
+
+type (
+	// edit replaces the bytes between start and end
+	edit struct {
+		start, end int
+		text       string
+	}
+
+	// Move renames a file referenced by a FileFields path
+	Move struct {
+		From string
+		To   string
+	}
+
+	// FileChange holds the original and updated content of a single file
+	FileChange struct {
+		Path     string
+		Original []byte
+		Updated  []byte
+		Moves    []Move
+	}
+
+	// fileEditor collects the edits to a single file
+	fileEditor struct {
+		path    string
+		content []byte
+		edits   []edit
+		moves   []Move
+
+		// lineStarts holds the byte offset of the start of each line
+		lineStarts []int
+	}
+)
+
+func newFileEditor(path string, content []byte) *fileEditor {
+	fe := &fileEditor{path: path, content: content, lineStarts: []int{0}}
+	for i, b := range content {
+		if b == '\n' {
+			fe.lineStarts = append(fe.lineStarts, i+1)
+		}
+	}
+	return fe
+}
+
+// offset converts the 1-based line and (rune) column of a node to a byte offset
+func (fe *fileEditor) offset(line, column int) int {
+	if line < 1 || line > len(fe.lineStarts) {
+		return -1
+	}
+	offset := fe.lineStarts[line-1]
+	for c := 1; c < column && offset < len(fe.content); c++ {
+		_, size := utf8.DecodeRune(fe.content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// scalarEnd returns the offset immediately after the scalar node starting at offset
+func (fe *fileEditor) scalarEnd(n *yaml.Node, start int) int {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(fe.content); i++ {
+			if fe.content[i] == '\\' {
+				i++
+			} else if fe.content[i] == '"' {
+				return i + 1
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(fe.content); i++ {
+			if fe.content[i] == '\'' {
+				if i+1 < len(fe.content) && fe.content[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	default:
+		if strings.HasPrefix(string(fe.content[start:]), n.Value) {
+			return start + len(n.Value)
+		}
+	}
+	return -1
+}
+
+// quote returns the value in the same style as the node, quoting plain values where YAML requires it
+func quote(n *yaml.Node, value string) string {
+	switch n.Style {
+	case yaml.DoubleQuotedStyle:
+		b, _ := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: value})
+		return strings.TrimSpace(string(b))
+	case yaml.SingleQuotedStyle:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	b, _ := yaml.Marshal(value)
+	return strings.TrimSpace(string(b))
+}
+
+// replaceScalar replaces the value of the scalar node, keeping its quoting style
+func (fe *fileEditor) replaceScalar(n *yaml.Node, value string) error {
+	start := fe.offset(n.Line, n.Column)
+	end := -1
+	if start >= 0 {
+		end = fe.scalarEnd(n, start)
+	}
+	if end < 0 {
+		return fmt.Errorf(errorCannotLocateScalar, n.Value, n.Line, n.Column)
+	}
+	fe.edits = append(fe.edits, edit{start: start, end: end, text: quote(n, value)})
+	return nil
+}
+
+// change applies the edits, returning nil if there are none
+func (fe *fileEditor) change() *FileChange {
+	if len(fe.edits) == 0 && len(fe.moves) == 0 {
+		return nil
+	}
+
+	sort.Slice(fe.edits, func(i, j int) bool { return fe.edits[i].start > fe.edits[j].start })
+	updated := append([]byte{}, fe.content...)
+	for _, e := range fe.edits {
+		updated = append(updated[:e.start], append([]byte(e.text), updated[e.end:]...)...)
+	}
+
+	return &FileChange{Path: fe.path, Original: fe.content, Updated: updated, Moves: fe.moves}
+}
+
+// Apply writes each of the changes, and moves any files, returning on the first error
+func Apply(changes []FileChange) error {
+	for _, c := range changes {
+		for _, m := range c.Moves {
+			if err := os.MkdirAll(filepath.Dir(m.To), directoryPerm); err != nil {
+				return err
+			}
+			if err := os.Rename(m.From, m.To); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(c.Path, c.Updated, filePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,354 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package refactor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyClass          = "Class"
+	keyID             = "ID"
+	keyDefinitions    = "Definitions"
+	keyReferences     = "References"
+	keySubDefinitions = "SubDefinitions"
+	keyFileFields     = "FileFields"
+	keyPath           = "Path"
+	keyExtends        = "Extends"
+	keyGraphChecks    = "GraphChecks"
+	keyClasses        = "Classes"
+	keyRootClasses    = "RootClasses"
+
+	logWarnSkippingFile = "skipping file [%s] due to error [%s]"
+
+	errorEmptyName          = "names cannot be empty"
+	errorSameName           = "new name is the same as the old name"
+	errorDefinitionNotFound = "cannot find definition ID [%s] for class [%s]"
+	errorDefinitionExists   = "definition ID [%s] for class [%s] already exists"
+	errorClassNotFound      = "cannot find class [%s]"
+	errorClassExists        = "class [%s] already exists"
+	errorFileExists         = "cannot move file [%s] to [%s] as it already exists"
+)
+
+type (
+	// visitor is called for each specification within a definition file, including those within SubDefinitions
+	visitor func(fe *fileEditor, spec *yaml.Node, class *yaml.Node) error
+)
+
+// walkSpecification calls the visitor for the specification node and then for each of its sub-definitions
+func walkSpecification(fe *fileEditor, spec *yaml.Node, visit visitor) error {
+	class := mappingValue(spec, keyClass)
+	if class == nil || class.Kind != yaml.ScalarNode {
+		return nil
+	}
+	if err := visit(fe, spec, class); err != nil {
+		return err
+	}
+
+	definitions := mappingValue(spec, keyDefinitions)
+	if definitions == nil || definitions.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 1; i < len(definitions.Content); i += 2 {
+		subDefinitions := mappingValue(definitions.Content[i], keySubDefinitions)
+		if subDefinitions == nil || subDefinitions.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 1; j < len(subDefinitions.Content); j += 2 {
+			if err := walkSpecification(fe, subDefinitions.Content[j], visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkReferences calls fn for each Class and ID node pair of the references in the sequence
+func walkReferences(refs *yaml.Node, fn func(class, id *yaml.Node) error) error {
+	if refs == nil || refs.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, ref := range refs.Content {
+		class, id := mappingValue(ref, keyClass), mappingValue(ref, keyID)
+		if class == nil || id == nil || class.Kind != yaml.ScalarNode || id.Kind != yaml.ScalarNode {
+			continue
+		}
+		if err := fn(class, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkAllReferences calls fn for every reference within the specification, at both specification and definition
+// level, excluding any within SubDefinitions which are visited separately
+func walkAllReferences(spec *yaml.Node, fn func(class, id *yaml.Node) error) error {
+	if err := walkReferences(mappingValue(spec, keyReferences), fn); err != nil {
+		return err
+	}
+	definitions := mappingValue(spec, keyDefinitions)
+	if definitions == nil || definitions.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 1; i < len(definitions.Content); i += 2 {
+		if err := walkReferences(mappingValue(definitions.Content[i], keyReferences), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// editFiles parses each definition file in the source directories and calls the visitor for each specification,
+// returning the resulting changes
+func editFiles(sourceDirs []string, fileExtension string, visit visitor) (changes []FileChange, err error) {
+	for _, dir := range sourceDirs {
+		walkErr := definition.ProcessFiles(dir, fileExtension, func(filePath string, _ os.FileInfo) error {
+			fe, root, err := loadFile(filePath)
+			if err != nil {
+				log.Warn().Msgf(logWarnSkippingFile, filePath, err)
+				return nil
+			}
+			if err = walkSpecification(fe, root, visit); err != nil {
+				return err
+			}
+			if c := fe.change(); c != nil {
+				changes = append(changes, *c)
+			}
+			return nil
+		})
+		if walkErr != nil {
+			return nil, walkErr
+		}
+	}
+	return changes, nil
+}
+
+func loadFile(path string) (*fileEditor, *yaml.Node, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return newFileEditor(path, content), nil, nil
+	}
+	return newFileEditor(path, content), doc.Content[0], nil
+}
+
+func validateNames(oldName, newName string) error {
+	if strings.TrimSpace(oldName) == "" || strings.TrimSpace(newName) == "" {
+		return fmt.Errorf(errorEmptyName)
+	}
+	if oldName == newName {
+		return fmt.Errorf(errorSameName)
+	}
+	return nil
+}
+
+// RenameDefinition renames the definition of the class with ID oldID to newID, updating every reference to it and
+// moving any FileFields files which are named after the definition
+func RenameDefinition(sourceDirs []string, fileExtension, class, oldID, newID string) ([]FileChange, error) {
+	if err := validateNames(oldID, newID); err != nil {
+		return nil, err
+	}
+
+	found := false
+	changes, err := editFiles(sourceDirs, fileExtension, func(fe *fileEditor, spec, classNode *yaml.Node) error {
+		err := walkAllReferences(spec, func(refClass, refID *yaml.Node) error {
+			if refClass.Value == class && refID.Value == oldID {
+				return fe.replaceScalar(refID, newID)
+			}
+			return nil
+		})
+		if err != nil || classNode.Value != class {
+			return err
+		}
+
+		definitions := mappingValue(spec, keyDefinitions)
+		if definitions == nil || definitions.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(definitions.Content); i += 2 {
+			switch definitions.Content[i].Value {
+			case newID:
+				return fmt.Errorf(errorDefinitionExists, newID, class)
+			case oldID:
+				found = true
+				if err = fe.replaceScalar(definitions.Content[i], newID); err != nil {
+					return err
+				}
+				if err = moveFileFields(fe, definitions.Content[i+1], oldID, newID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf(errorDefinitionNotFound, oldID, class)
+	}
+
+	return changes, nil
+}
+
+// moveFileFields updates any FileFields path whose file name, excluding the extension, is the old ID
+func moveFileFields(fe *fileEditor, dfn *yaml.Node, oldID, newID string) error {
+	fileFields := mappingValue(dfn, keyFileFields)
+	if fileFields == nil || fileFields.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 1; i < len(fileFields.Content); i += 2 {
+		path := mappingValue(fileFields.Content[i], keyPath)
+		if path == nil || path.Kind != yaml.ScalarNode {
+			continue
+		}
+		ext := filepath.Ext(path.Value)
+		if strings.TrimSuffix(filepath.Base(path.Value), ext) != oldID {
+			continue
+		}
+
+		newPath := filepath.Join(filepath.Dir(path.Value), newID+ext)
+		if filepath.ToSlash(path.Value) == path.Value {
+			newPath = filepath.ToSlash(newPath)
+		}
+		move := Move{
+			From: filepath.Join(filepath.Dir(fe.path), path.Value),
+			To:   filepath.Join(filepath.Dir(fe.path), newPath),
+		}
+		if _, err := os.Stat(move.To); err == nil {
+			return fmt.Errorf(errorFileExists, move.From, move.To)
+		}
+		if err := fe.replaceScalar(path, newPath); err != nil {
+			return err
+		}
+		fe.moves = append(fe.moves, move)
+	}
+	return nil
+}
+
+// RenameClass renames the class of every definition and reference from oldClass to newClass; if any definition
+// format files are provided then the class is also renamed within them
+func RenameClass(sourceDirs []string, fileExtension string, formatFiles []string, oldClass,
+	newClass string) ([]FileChange, error) {
+	if err := validateNames(oldClass, newClass); err != nil {
+		return nil, err
+	}
+
+	found := false
+	changes, err := editFiles(sourceDirs, fileExtension, func(fe *fileEditor, spec, classNode *yaml.Node) error {
+		switch classNode.Value {
+		case newClass:
+			return fmt.Errorf(errorClassExists, newClass)
+		case oldClass:
+			found = true
+			if err := fe.replaceScalar(classNode, newClass); err != nil {
+				return err
+			}
+		}
+
+		return walkAllReferences(spec, func(refClass, _ *yaml.Node) error {
+			if refClass.Value == oldClass {
+				return fe.replaceScalar(refClass, newClass)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, formatFile := range formatFiles {
+		c, formatFound, err := renameFormatClass(formatFile, oldClass, newClass)
+		if err != nil {
+			return nil, err
+		}
+		found = found || formatFound
+		if c != nil {
+			changes = append(changes, *c)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf(errorClassNotFound, oldClass)
+	}
+	return changes, nil
+}
+
+// renameFormatClass renames the class within a definition format file, including any classes which extend it and
+// any graph checks which are restricted to it
+func renameFormatClass(path, oldClass, newClass string) (*FileChange, bool, error) {
+	fe, root, err := loadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	found := false
+	classes := mappingValue(root, keyClass)
+	if classes != nil && classes.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(classes.Content); i += 2 {
+			switch classes.Content[i].Value {
+			case newClass:
+				return nil, false, fmt.Errorf(errorClassExists, newClass)
+			case oldClass:
+				found = true
+				if err = fe.replaceScalar(classes.Content[i], newClass); err != nil {
+					return nil, false, err
+				}
+			}
+			if extends := mappingValue(classes.Content[i+1], keyExtends); extends != nil && extends.Value == oldClass {
+				if err = fe.replaceScalar(extends, newClass); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+	}
+
+	checks := mappingValue(root, keyGraphChecks)
+	if checks != nil && checks.Kind == yaml.MappingNode {
+		for i := 1; i < len(checks.Content); i += 2 {
+			for _, key := range []string{keyClasses, keyRootClasses} {
+				list := mappingValue(checks.Content[i], key)
+				if list == nil || list.Kind != yaml.SequenceNode {
+					continue
+				}
+				for _, item := range list.Content {
+					if item.Kind == yaml.ScalarNode && item.Value == oldClass {
+						if err = fe.replaceScalar(item, newClass); err != nil {
+							return nil, false, err
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return fe.change(), found, nil
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// copyFixture copies the rename fixture into a temporary directory so that it can be modified
+func copyFixture(t *testing.T) string {
+	dir := t.TempDir()
+	err := filepath.Walk("_test/Rename", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("_test/Rename", path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), directoryPerm)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, rel), b, filePerm)
+	})
+	assert.Nil(t, err)
+	return dir
+}
+
+func readFile(t *testing.T, path string) string {
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	return string(b)
+}
+
+func Test_RenameDefinition(t *testing.T) {
+	t.Run("Provider", func(t *testing.T) {
+		dir := copyFixture(t)
+		sourceDirs := []string{filepath.Join(dir, "definition")}
+
+		changes, err := RenameDefinition(sourceDirs, "yaml", "Provider", "azure", "microsoft-azure")
+		assert.Nil(t, err)
+		assert.Len(t, changes, 2)
+
+		// nothing is written until the changes are applied
+		assert.NotContains(t, readFile(t, filepath.Join(dir, "definition/provider.yaml")), "microsoft-azure")
+		assert.Nil(t, Apply(changes))
+
+		assert.Equal(t, `# cloud providers
+Class: Provider
+Definitions:
+  # Microsoft
+  microsoft-azure:
+    Fields:
+      Name: Microsoft Azure # display name
+    FileFields:
+      Overview:
+        Path: docs/microsoft-azure.md
+    SubDefinitions:
+      PROVIDED_BY:
+        Class: Service
+        Definitions:
+          "app-service":
+            Fields:
+              Name: App Service
+  aws:
+    Fields:
+      Name: Amazon Web Services
+`, readFile(t, filepath.Join(dir, "definition/provider.yaml")))
+		assert.Equal(t, `Class: Service
+References:
+  - {Class: Provider, ID: 'microsoft-azure', Relationship: PROVIDED_BY}
+Definitions:
+  vm:
+    Fields:
+      Name: Virtual Machine
+    References:
+      - Class: Provider
+        ID: microsoft-azure   # the main provider
+        Relationship: HOSTED_BY
+      - Class: Service
+        ID: app-service
+        Relationship: RELATED_TO
+`, readFile(t, filepath.Join(dir, "definition/service.yaml")))
+
+		assert.Equal(t, "# Azure\n", readFile(t, filepath.Join(dir, "definition/docs/microsoft-azure.md")))
+		_, err = os.Stat(filepath.Join(dir, "definition/docs/azure.md"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("SubDefinition", func(t *testing.T) {
+		dir := copyFixture(t)
+		changes, err := RenameDefinition([]string{dir}, "yaml", "Service", "app-service", "web: app")
+		assert.Nil(t, err)
+		assert.Nil(t, Apply(changes))
+
+		assert.Contains(t, readFile(t, filepath.Join(dir, "definition/provider.yaml")), `          "web: app":`)
+		assert.Contains(t, readFile(t, filepath.Join(dir, "definition/service.yaml")), `        ID: 'web: app'`)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		dir := copyFixture(t)
+		_, err := RenameDefinition([]string{dir}, "yaml", "Provider", "oracle", "oci")
+		assert.EqualError(t, err, "cannot find definition ID [oracle] for class [Provider]")
+		_, err = RenameDefinition([]string{dir}, "yaml", "Provider", "azure", "aws")
+		assert.EqualError(t, err, "definition ID [aws] for class [Provider] already exists")
+		_, err = RenameDefinition([]string{dir}, "yaml", "Provider", "azure", "azure")
+		assert.NotNil(t, err)
+		_, err = RenameDefinition([]string{dir}, "yaml", "Provider", "azure", "")
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_RenameClass(t *testing.T) {
+	t.Run("WithFormat", func(t *testing.T) {
+		dir := copyFixture(t)
+		changes, err := RenameClass([]string{filepath.Join(dir, "definition")}, "yaml",
+			[]string{filepath.Join(dir, "format.yml")}, "Provider", "Vendor")
+		assert.Nil(t, err)
+		assert.Len(t, changes, 3)
+		assert.Nil(t, Apply(changes))
+
+		provider := readFile(t, filepath.Join(dir, "definition/provider.yaml"))
+		assert.Contains(t, provider, "\nClass: Vendor\n")
+		assert.Contains(t, provider, "        Class: Service\n")
+
+		service := readFile(t, filepath.Join(dir, "definition/service.yaml"))
+		assert.Contains(t, service, "{Class: Vendor, ID: 'azure'")
+		assert.Contains(t, service, "      - Class: Vendor\n        ID: azure")
+		assert.Contains(t, service, "      - Class: Service\n")
+
+		assert.Equal(t, `Class:
+  Vendor:
+    MandatoryFields:
+      Name:
+  CloudProvider:
+    Extends: Vendor
+  Service:
+    MandatoryFields:
+      Name:
+GraphChecks:
+  Unreachable:
+    RootClasses: [Vendor]
+`, readFile(t, filepath.Join(dir, "format.yml")))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		dir := copyFixture(t)
+		_, err := RenameClass([]string{dir}, "yaml", nil, "Missing", "Other")
+		assert.EqualError(t, err, "cannot find class [Missing]")
+		_, err = RenameClass([]string{dir}, "yaml", nil, "Provider", "Service")
+		assert.EqualError(t, err, "class [Service] already exists")
+	})
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package site
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const (
+	templateLayout     = "layout.html"
+	templateIndex      = "index.html"
+	templateClass      = "class.html"
+	templateDefinition = "definition.html"
+
+	templatesDir = "templates"
+	searchIndex  = "search.json"
+	parentDir    = "../"
+
+	directoryPerm = 0755
+	filePerm      = 0644
+)
+
+var (
+	//go:embed templates
+	templates embed.FS
+)
+
+type (
+	// page is the data passed to each template; Root is the relative path from the page to the root of the site
+	page struct {
+		Site       *Site
+		Class      *Class
+		Definition *Definition
+		Root       string
+	}
+
+	// links is the data passed to the links template within the definition template
+	links struct {
+		Root  string
+		Links []Link
+	}
+
+	// generator holds the templates for each type of page
+	generator struct {
+		index, class, definition *template.Template
+	}
+)
+
+var funcs = template.FuncMap{
+	"classURL": classURL,
+	"links": func(root string, l []Link) links {
+		return links{Root: root, Links: l}
+	},
+}
+
+// readTemplate returns the named template from the template directory if it contains one, otherwise the default
+func readTemplate(templateDir, name string) (string, error) {
+	if templateDir != "" {
+		b, err := os.ReadFile(filepath.Join(templateDir, name))
+		if err == nil {
+			return string(b), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	b, err := templates.ReadFile(path.Join(templatesDir, name))
+	return string(b), err
+}
+
+// newGenerator parses the layout together with each page template, any of which can be overridden by a file of the
+// same name in the template directory
+func newGenerator(templateDir string) (*generator, error) {
+	layoutSource, err := readTemplate(templateDir, templateLayout)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := template.New(templateLayout).Funcs(funcs).Parse(layoutSource)
+	if err != nil {
+		return nil, err
+	}
+
+	parse := func(name string) (*template.Template, error) {
+		source, err := readTemplate(templateDir, name)
+		if err != nil {
+			return nil, err
+		}
+		t, err := layout.Clone()
+		if err != nil {
+			return nil, err
+		}
+		return t.New(name).Parse(source)
+	}
+
+	g := &generator{}
+	if g.index, err = parse(templateIndex); err != nil {
+		return nil, err
+	}
+	if g.class, err = parse(templateClass); err != nil {
+		return nil, err
+	}
+	if g.definition, err = parse(templateDefinition); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// write renders the page with the layout to the file, relative to the site directory
+func write(t *template.Template, data page, dir, file string) error {
+	p := filepath.Join(dir, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(p), directoryPerm); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return t.ExecuteTemplate(f, templateLayout, data)
+}
+
+// Generate writes the site to the directory, using the templates in templateDir in place of the defaults
+func Generate(s *Site, dir, templateDir string) error {
+	g, err := newGenerator(templateDir)
+	if err != nil {
+		return err
+	}
+
+	if err = write(g.index, page{Site: s}, dir, indexPage); err != nil {
+		return err
+	}
+	for _, c := range s.Classes {
+		if err = write(g.class, page{Site: s, Class: c, Root: parentDir}, dir, c.URL); err != nil {
+			return err
+		}
+		for _, d := range c.Definitions {
+			if err = write(g.definition, page{Site: s, Class: c, Definition: d, Root: parentDir}, dir, d.URL); err != nil {
+				return err
+			}
+		}
+	}
+
+	b, err := json.Marshal(s.SearchIndex())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, searchIndex), b, filePerm)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package site
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readFile(t *testing.T, dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	assert.NoError(t, err)
+	return string(b)
+}
+
+func Test_Generate(t *testing.T) {
+	s := Build(getDictionary(), nil)
+
+	t.Run("Defaults", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, Generate(s, dir, ""))
+
+		index := readFile(t, dir, "index.html")
+		assert.Contains(t, index, `<a href="Provider/index.html">Provider</a></td><td>2</td>`)
+		assert.Contains(t, index, `const root = "";`)
+
+		class := readFile(t, dir, "Service/index.html")
+		assert.Contains(t, class, `<a href="../Service/vm.html">vm</a></td><td>Virtual Machine</td>`)
+		assert.Contains(t, class, `<a href="../index.html">Home</a>`)
+
+		vm := readFile(t, dir, "Service/vm.html")
+		assert.Contains(t, vm, "<title>Service: Virtual Machine</title>")
+		assert.Contains(t, vm, `<a href="../Provider/azure.html">Microsoft Azure</a>`)
+		assert.Contains(t, vm, "oracle (missing)")
+		assert.Contains(t, vm, "Since: 2010<br>")
+
+		azure := readFile(t, dir, "Provider/azure.html")
+		assert.Contains(t, azure, "<pre>- uk-south</pre>")
+		assert.Contains(t, azure, `<a href="../Service/vm.html">Virtual Machine</a>`)
+
+		var entries []SearchEntry
+		assert.NoError(t, json.Unmarshal([]byte(readFile(t, dir, "search.json")), &entries))
+		assert.Equal(t, s.SearchIndex(), entries)
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, Generate(s, dir, "_test/templates"))
+
+		assert.Contains(t, readFile(t, dir, "Service/index.html"), "<p>custom Service: 2</p>")
+		assert.Contains(t, readFile(t, dir, "Service/vm.html"), "Outgoing Relationships")
+	})
+
+	t.Run("InvalidTemplate", func(t *testing.T) {
+		templateDir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(templateDir, templateIndex), []byte("{{ .Missing"), filePerm))
+		assert.Error(t, Generate(s, t.TempDir(), templateDir))
+	})
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package site generates a static site from a Dictionary, with an index of the definitions of each class and a page
+// for each definition linking to the definitions it is related to.
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// titleField is used as the title of a definition, where present, rather than its ID
+	titleField = "Name"
+
+	pageExt   = ".html"
+	indexName = "index"
+	indexPage = indexName + pageExt
+
+	// fileNameEscape precedes the hexadecimal value of each byte escaped within a file name
+	fileNameEscape = "_%02X"
+
+	searchTextMaxLength = 500
+)
+
+type (
+	// Field is a field of a definition, formatted for display; Block is set for lists and maps, formatted as YAML
+	Field struct {
+		Name  string
+		Value string
+		Block bool
+	}
+
+	// Link is a relationship between a definition and another definition
+	Link struct {
+		Relationship string
+		Class        string
+		ID           string
+		Title        string
+
+		// URL is relative to the root of the site, and empty if the definition does not exist
+		URL    string
+		Fields []Field
+	}
+
+	// Definition is the page for a single definition
+	Definition struct {
+		Class    string
+		ID       string
+		Title    string
+		URL      string
+		Fields   []Field
+		Outgoing []Link
+		Incoming []Link
+	}
+
+	// Class is the index page for the definitions of a class
+	Class struct {
+		Name        string
+		URL         string
+		Definitions []*Definition
+	}
+
+	// Site holds every page of the site
+	Site struct {
+		Classes []*Class
+	}
+
+	// SearchEntry is a single definition within the search index
+	SearchEntry struct {
+		Class string `json:"class"`
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		URL   string `json:"url"`
+		Text  string `json:"text"`
+	}
+)
+
+// escapeByte returns the escaped form of a byte within a file name
+func escapeByte(b byte) string {
+	return fmt.Sprintf(fileNameEscape, b)
+}
+
+// fileName escapes any bytes which are not letters, digits or hyphens, so that the name is safe within a file name or
+// URL; as the escape character is itself escaped, distinct names always have distinct file names
+func fileName(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch b := name[i]; {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', b == '-':
+			sb.WriteByte(b)
+		default:
+			sb.WriteString(escapeByte(b))
+		}
+	}
+	return sb.String()
+}
+
+// classURL returns the URL of the index page of a class, relative to the root of the site
+func classURL(class string) string {
+	return fileName(class) + "/" + indexPage
+}
+
+// definitionURL returns the URL of the page of a definition, relative to the root of the site; the first byte of an ID
+// of index is escaped so that its page does not replace the index page of the class
+func definitionURL(class, id string) string {
+	name := fileName(id)
+	if name == indexName {
+		name = escapeByte(name[0]) + name[1:]
+	}
+	return fileName(class) + "/" + name + pageExt
+}
+
+// title returns the name of the definition, or its ID if it has no name
+func title(dfn *parser.DictionaryDefinition, id string) string {
+	if dfn != nil {
+		if name, ok := dfn.Fields[titleField].(string); ok && name != "" {
+			return name
+		}
+	}
+	return id
+}
+
+func formatValue(v interface{}) (string, bool) {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		b, err := yaml.Marshal(v)
+		if err == nil {
+			return strings.TrimSpace(string(b)), true
+		}
+	case nil:
+		return "", false
+	}
+	return fmt.Sprint(v), false
+}
+
+func formatFields(fields definition.Fields) []Field {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Field, 0, len(names))
+	for _, name := range names {
+		value, block := formatValue(fields[name])
+		result = append(result, Field{Name: name, Value: value, Block: block})
+	}
+	return result
+}
+
+func sortLinks(links []Link) {
+	sort.SliceStable(links, func(i, j int) bool {
+		if links[i].Relationship != links[j].Relationship {
+			return links[i].Relationship < links[j].Relationship
+		}
+		if links[i].Class != links[j].Class {
+			return links[i].Class < links[j].Class
+		}
+		return links[i].ID < links[j].ID
+	})
+}
+
+func sortedClasses(d parser.Dictionary) []string {
+	classes := make([]string, 0, len(d))
+	for class := range d {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+func sortedIDs(definitions map[string]*parser.DictionaryDefinition) []string {
+	ids := make([]string, 0, len(definitions))
+	for id := range definitions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Build returns the pages of the site for the Dictionary. The DefinitionFormat is optional, and links references to a
+// base class to the pages of the definitions of its subclasses.
+func Build(d parser.Dictionary, df *parser.DefinitionFormat) *Site {
+	s := &Site{}
+	pages := map[parser.DefinitionKey]*Definition{}
+
+	for _, class := range sortedClasses(d) {
+		c := &Class{Name: class, URL: classURL(class)}
+		for _, id := range sortedIDs(d[class]) {
+			dfn := d[class][id]
+			p := &Definition{Class: class, ID: id, Title: title(dfn, id), URL: definitionURL(class, id),
+				Fields: formatFields(dfn.Fields)}
+			c.Definitions = append(c.Definitions, p)
+			pages[parser.DefinitionKey{Class: class, ID: id}] = p
+		}
+		s.Classes = append(s.Classes, c)
+	}
+
+	for _, c := range s.Classes {
+		for _, p := range c.Definitions {
+			for _, ref := range d[c.Name][p.ID].References {
+				key, _ := d.Resolve(df, parser.DefinitionKey{Class: ref.Class, ID: ref.ID})
+				to := pages[key]
+				out := Link{Relationship: ref.Relationship, Class: ref.Class, ID: ref.ID, Title: ref.ID,
+					Fields: formatFields(ref.Fields)}
+				if to != nil {
+					out.Title, out.URL = to.Title, to.URL
+					to.Incoming = append(to.Incoming, Link{Relationship: ref.Relationship, Class: p.Class, ID: p.ID,
+						Title: p.Title, URL: p.URL, Fields: out.Fields})
+				}
+				p.Outgoing = append(p.Outgoing, out)
+			}
+		}
+	}
+
+	for _, p := range pages {
+		sortLinks(p.Outgoing)
+		sortLinks(p.Incoming)
+	}
+
+	return s
+}
+
+// SearchIndex returns an entry for each definition, whose text holds the values of its scalar fields
+func (s *Site) SearchIndex() []SearchEntry {
+	entries := []SearchEntry{}
+	for _, c := range s.Classes {
+		for _, p := range c.Definitions {
+			var values []string
+			for _, f := range p.Fields {
+				if !f.Block && f.Value != "" {
+					values = append(values, f.Value)
+				}
+			}
+			text := []rune(strings.Join(values, " "))
+			if len(text) > searchTextMaxLength {
+				text = text[:searchTextMaxLength]
+			}
+			entries = append(entries, SearchEntry{Class: p.Class, ID: p.ID, Title: p.Title, URL: p.URL,
+				Text: string(text)})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2020 Paul Tatham <paul@nextmetaphor.io>
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package site
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nextmetaphor/yaml-graph/definition"
+	"github.com/nextmetaphor/yaml-graph/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func getDictionary() parser.Dictionary {
+	return parser.Dictionary{
+		"Provider": {
+			"azure": {Fields: definition.Fields{"Name": "Microsoft Azure", "Regions": []interface{}{"uk-south"}}},
+			"aws":   {Fields: definition.Fields{"Name": "Amazon Web Services"}},
+		},
+		"Service": {
+			"vm": {Fields: definition.Fields{"Name": "Virtual Machine", "Cost": 10, "Notes": strings.Repeat("x", 600)},
+				References: []definition.Reference{
+					{Class: "Provider", ID: "azure", Relationship: "HOSTED_BY", Fields: definition.Fields{"Since": 2010}},
+					{Class: "Provider", ID: "oracle", Relationship: "HOSTED_BY"},
+				}},
+			"a/b": {},
+		},
+	}
+}
+
+func Test_fileName(t *testing.T) {
+	assert.Equal(t, "virtual-machine-20", fileName("virtual-machine-20"))
+	assert.Equal(t, "a_2Fb_20c", fileName("a/b c"))
+	assert.Equal(t, "a_5Fb_2E0", fileName("a_b.0"))
+	assert.Equal(t, "caf_C3_A9", fileName("café"))
+	assert.NotEqual(t, fileName("a b"), fileName("a_b"))
+	assert.NotEqual(t, fileName("a/b"), fileName("a_b"))
+}
+
+func Test_definitionURL(t *testing.T) {
+	assert.Equal(t, "Provider/azure.html", definitionURL("Provider", "azure"))
+	assert.Equal(t, "Provider/_69ndex.html", definitionURL("Provider", "index"))
+	assert.NotEqual(t, classURL("Provider"), definitionURL("Provider", "index"))
+	assert.Equal(t, "Provider/index-1.html", definitionURL("Provider", "index-1"))
+}
+
+func Test_Build(t *testing.T) {
+	s := Build(getDictionary(), nil)
+	assert.Len(t, s.Classes, 2)
+
+	provider := s.Classes[0]
+	assert.Equal(t, "Provider/index.html", provider.URL)
+	assert.Equal(t, "aws", provider.Definitions[0].ID)
+
+	azure := provider.Definitions[1]
+	assert.Equal(t, "Microsoft Azure", azure.Title)
+	assert.Equal(t, "Provider/azure.html", azure.URL)
+	assert.Equal(t, []Field{{Name: "Name", Value: "Microsoft Azure"}, {Name: "Regions", Value: "- uk-south", Block: true}},
+		azure.Fields)
+	assert.Equal(t, []Link{{Relationship: "HOSTED_BY", Class: "Service", ID: "vm", Title: "Virtual Machine",
+		URL: "Service/vm.html", Fields: []Field{{Name: "Since", Value: "2010"}}}}, azure.Incoming)
+
+	service := s.Classes[1]
+	assert.Equal(t, "a/b", service.Definitions[0].Title)
+	assert.Equal(t, "Service/a_2Fb.html", service.Definitions[0].URL)
+
+	vm := service.Definitions[1]
+	assert.Equal(t, []Link{
+		{Relationship: "HOSTED_BY", Class: "Provider", ID: "azure", Title: "Microsoft Azure", URL: "Provider/azure.html",
+			Fields: []Field{{Name: "Since", Value: "2010"}}},
+		{Relationship: "HOSTED_BY", Class: "Provider", ID: "oracle", Title: "oracle", Fields: []Field{}},
+	}, vm.Outgoing)
+}
+
+func Test_SearchIndex(t *testing.T) {
+	entries := Build(getDictionary(), nil).SearchIndex()
+	assert.Len(t, entries, 4)
+	assert.Equal(t, SearchEntry{Class: "Provider", ID: "azure", Title: "Microsoft Azure", URL: "Provider/azure.html",
+		Text: "Microsoft Azure"}, entries[1])
+	assert.Len(t, entries[3].Text, searchTextMaxLength)
+	assert.True(t, strings.HasPrefix(entries[3].Text, "10 Virtual Machine xxx"))
+}